@@ -37,7 +37,8 @@ func (tcli *TClient) Start(ctx context.Context) {
 
 	for i := 0; i < tcli.arg.Num; i++ {
 		cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{
-			Addr: tcli.arg.Addr,
+			Addr:      tcli.arg.Addr,
+			Reconnect: xnet.ReconnectOptions{Enable: true},
 			OnConnect: func(ctx context.Context, sock xnet.Socket) interface{} {
 				return &TcpClientState{}
 			},
@@ -7,12 +7,15 @@ import (
 	"gotu/pkg/xlog"
 	"gotu/pkg/xmsg"
 	"gotu/pkg/xnet"
+	"time"
 
 	"go.uber.org/zap"
 )
 
 var addr = flag.String("addr", ":5000", "listen addr")
 
+const shutdownTimeout = 10 * time.Second
+
 type TcpState struct {
 	sock xnet.Socket
 }
@@ -49,7 +52,13 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	defer svr.Close(ctx)
 
 	xcommon.UntilSignal(ctx)
+
+	// 优雅关闭, 超时后强制关闭
+	shutdownCtx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+	if forced, err := svr.Shutdown(shutdownCtx); err != nil {
+		xlog.Get(ctx).Warn("Svr shutdown timeout.", zap.Int("forced", len(forced)), zap.Any("err", err))
+	}
 }
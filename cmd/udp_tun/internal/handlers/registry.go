@@ -37,6 +37,8 @@ type RegistryArgs struct {
 	InLatency  uint32
 	OutLoss    uint32
 	OutLatency uint32
+	Metrics    xnet.Metrics // 代理连接指标上报(nil:不上报)
+	Batch      int          // 代理连接批量收发数据包数量(<=1: 逐个收发)
 }
 
 type Registry struct {
@@ -47,6 +49,8 @@ type Registry struct {
 	inLatency  uint32
 	outLoss    uint32
 	outLatency uint32
+	metrics    xnet.Metrics
+	batch      int
 }
 
 var reg = &Registry{}
@@ -69,6 +73,8 @@ func InitRegistry(ctx context.Context, arg RegistryArgs) (*Registry, error) {
 	reg.inLatency = arg.InLatency
 	reg.outLoss = arg.OutLoss
 	reg.outLatency = arg.OutLatency
+	reg.metrics = arg.Metrics
+	reg.batch = arg.Batch
 	return reg, nil
 }
 
@@ -91,6 +97,8 @@ func (r *Registry) OnConnect(ctx context.Context, sock xnet.Socket) interface{}
 	cli, err := xnet.NewUDPClient(ctx, xnet.UDPCliArgs{
 		Addr:    reg.proxyAddr,
 		Timeout: 10,
+		Metrics: reg.metrics,
+		Batch:   reg.batch,
 		OnConnect: func(ctx context.Context, csock xnet.Socket) interface{} {
 			xlog.Get(ctx).Sugar().Debugf("Proxy connect success, %v => %v", s.svrSock.RemoteAddr(), csock.RemoteAddr())
 			return csock
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"gotu/pkg/xcommon"
+	"gotu/pkg/xprom"
 	"sort"
 	"sync"
 )
@@ -160,3 +161,43 @@ func (sts *singleTunSnmp) values(ctx context.Context) []string {
 	strs = append(strs, xcommon.ToString(sts.base.recvClientBytes), xcommon.ToString(sts.base.recvClientPackets), xcommon.ToString(sts.base.recvProxyBytes), xcommon.ToString(sts.base.recvProxyPackets), xcommon.ToString(average_90), xcommon.ToString(average_95), xcommon.ToString(average_99), xcommon.ToString(average_100))
 	return strs
 }
+
+// prometheus指标: 活跃隧道及已关闭隧道汇总(tunnel="closed")
+func Collect(w *xprom.Writer) {
+	_tunSnmp.mu.Lock()
+	snmps := make([]*singleTunSnmp, 0, len(_tunSnmp.snmps))
+	for _, sts := range _tunSnmp.snmps {
+		snmps = append(snmps, sts)
+	}
+	_tunSnmp.mu.Unlock()
+	sort.Slice(snmps, func(i, j int) bool { return snmps[i].id < snmps[j].id })
+
+	w.Gauge("gotu_udptun_tunnels_active", "Active udp tunnels.", xprom.S(float64(len(snmps))))
+	for _, sts := range snmps {
+		collectBase(w, sts.id, sts.clone())
+	}
+	collectBase(w, "closed", _tunSnmp.total.clone())
+}
+
+func collectBase(w *xprom.Writer, id string, base *tunSnmpBase) {
+	l := xprom.L("tunnel", id)
+	w.Counter("gotu_udptun_client_bytes_total", "Bytes received from client (l2p).", xprom.S(float64(base.recvClientBytes), l))
+	w.Counter("gotu_udptun_client_packets_total", "Packets received from client (l2p).", xprom.S(float64(base.recvClientPackets), l))
+	w.Counter("gotu_udptun_proxy_bytes_total", "Bytes received from proxy (p2l).", xprom.S(float64(base.recvProxyBytes), l))
+	w.Counter("gotu_udptun_proxy_packets_total", "Packets received from proxy (p2l).", xprom.S(float64(base.recvProxyPackets), l))
+
+	// 代理间延迟(ms => s)
+	sort.Sort(base.proxyLatencys)
+	data := base.proxyLatencys.data
+	var sum int64
+	for _, delay := range data {
+		sum += delay
+	}
+	if len(data) > 0 {
+		for _, q := range []float64{0.9, 0.95, 0.99} {
+			idx := int(q * float64(len(data)-1))
+			w.Quantile("gotu_udptun_proxy_latency_seconds", "Latency between tunnel proxies.", q, float64(data[idx])/1000, l)
+		}
+	}
+	w.Summary("gotu_udptun_proxy_latency_seconds", "Latency between tunnel proxies.", float64(sum)/1000, uint64(len(data)), l)
+}
@@ -7,6 +7,7 @@ import (
 	"gotu/cmd/udp_tun/internal/handlers"
 	"gotu/pkg/xcommon"
 	"gotu/pkg/xnet"
+	"gotu/pkg/xprom"
 )
 
 var listenAddr = flag.String("listen", ":6000", "udp listen addr")
@@ -17,6 +18,8 @@ var inLoss = flag.Int("inloss", 0, "in: loss packet 0~100")
 var inLatency = flag.Int("inlatency", 0, "in: relay rand latency")
 var outLoss = flag.Int("outloss", 0, "out: loss packet 0~100")
 var outLatency = flag.Int("outlatency", 0, "out: relay rand latency")
+var batch = flag.Int("batch", 0, "udp batch io size, recvmmsg/sendmmsg on linux (<=1: disable)")
+var metricsAddr = flag.String("metrics", "", "prometheus metrics listen addr (empty: disable)")
 
 func main() {
 	flag.Parse()
@@ -31,7 +34,20 @@ func main() {
 		panic(fmt.Sprintf("connect[%v] invalid", *proxyAddr))
 	}
 
-	reg, err := handlers.InitRegistry(ctx, handlers.RegistryArgs{Addr: *proxyAddr, Mode: *mode, Header: *header, InLoss: uint32(*inLoss), InLatency: uint32(*inLatency), OutLoss: uint32(*outLoss), OutLatency: uint32(*outLatency)})
+	svrMetrics, proxyMetrics := xnet.NewMemMetrics(), xnet.NewMemMetrics()
+	if *metricsAddr != "" {
+		xprom.Register(xprom.NetCollector("listen", svrMetrics))
+		xprom.Register(xprom.NetCollector("proxy", proxyMetrics))
+		xprom.Register(xprom.ActorCollector())
+		xprom.Register(xprom.CollectorFunc(handlers.Collect))
+		metricsSvr, err := xprom.NewServer(ctx, xprom.SvrArgs{Addr: *metricsAddr})
+		if err != nil {
+			panic(err)
+		}
+		defer metricsSvr.Close(ctx)
+	}
+
+	reg, err := handlers.InitRegistry(ctx, handlers.RegistryArgs{Addr: *proxyAddr, Mode: *mode, Header: *header, InLoss: uint32(*inLoss), InLatency: uint32(*inLatency), OutLoss: uint32(*outLoss), OutLatency: uint32(*outLatency), Metrics: proxyMetrics, Batch: *batch})
 	if err != nil {
 		panic(err)
 	}
@@ -39,6 +55,8 @@ func main() {
 	svr, err := xnet.NewUDPServer(ctx, xnet.UDPSvrArgs{
 		Addr:         *listenAddr,
 		Timeout:      10,
+		Metrics:      svrMetrics,
+		Batch:        *batch,
 		OnConnect:    reg.OnConnect,
 		OnDisconnect: reg.OnDisconnect,
 		OnMsg:        reg.OnMsg,
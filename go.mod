@@ -10,6 +10,9 @@ require (
 	github.com/xtaci/kcp-go v5.4.20+incompatible
 	go.elastic.co/ecszap v1.0.1
 	go.uber.org/zap v1.24.0
+	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4
+	golang.org/x/sys v0.0.0-20220704084225-05e143d24a9e
+	google.golang.org/protobuf v1.30.0
 )
 
 require (
@@ -23,6 +26,4 @@ require (
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
 	golang.org/x/crypto v0.0.0-20201012173705-84dcc777aaee // indirect
-	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4 // indirect
-	golang.org/x/sys v0.0.0-20220704084225-05e143d24a9e // indirect
 )
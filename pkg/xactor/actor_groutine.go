@@ -22,6 +22,7 @@ type ActorGroutine struct {
 	state ActorState // 数据状态
 	box   *mailBox   // 消息分发
 	*actorHandler
+	stats actorStats // 运行统计
 
 	wg      sync.WaitGroup
 	closeCh chan struct{}
@@ -65,6 +66,7 @@ loop:
 	for {
 		select {
 		case m := <-actor.box.recvMail():
+			begin := time.Now()
 			if m.t == syncMail {
 				handler := actor.actorHandler.getSyncHandler(reflect.TypeOf(m.req))
 				if handler != nil {
@@ -83,6 +85,7 @@ loop:
 			} else {
 				xlog.Get(ctx).Warn("Mail type invalid", zap.Any("type", m.t))
 			}
+			actor.stats.add(time.Since(begin))
 		case <-ticker.C:
 		case <-actor.closeCh:
 			break loop
@@ -80,5 +80,12 @@ func TestActor(t *testing.T) {
 
 	time.Sleep(1 * time.Second)
 
+	// 运行统计
+	stats := xactor.Stats()
+	xlog.Get(ctx).Info("Actor stats", zap.Any("stats", stats))
+	if len(stats) != 1 || stats[0].Name != LogicActorName || stats[0].Handled != 2 || stats[0].MailboxDepth != 0 {
+		t.Fatalf("actor stats %+v", stats)
+	}
+
 	xactor.CloseAll(ctx)
 }
@@ -36,3 +36,12 @@ func (box *mailBox) recvMail() <-chan *mail {
 func (box *mailBox) sendMail(m *mail) {
 	box.mailCh <- m
 }
+
+// 待处理mail数量
+func (box *mailBox) len() int {
+	return len(box.mailCh)
+}
+
+func (box *mailBox) cap() int {
+	return cap(box.mailCh)
+}
@@ -0,0 +1,58 @@
+package xactor
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// actor运行统计
+type ActorStats struct {
+	Name         string
+	MailboxDepth int // 待处理mail数量
+	MailboxCap   int
+	Handled      uint64        // 已处理mail数量
+	HandleTotal  time.Duration // mail处理总耗时
+	HandleMax    time.Duration
+}
+
+type actorStats struct {
+	handled     uint64
+	handleTotal int64
+	handleMax   int64
+}
+
+func (s *actorStats) add(cost time.Duration) {
+	atomic.AddUint64(&s.handled, 1)
+	atomic.AddInt64(&s.handleTotal, int64(cost))
+	for {
+		old := atomic.LoadInt64(&s.handleMax)
+		if int64(cost) <= old || atomic.CompareAndSwapInt64(&s.handleMax, old, int64(cost)) {
+			return
+		}
+	}
+}
+
+func (actor *ActorGroutine) Stats() ActorStats {
+	return ActorStats{
+		Name:         actor.state.Name(),
+		MailboxDepth: actor.box.len(),
+		MailboxCap:   actor.box.cap(),
+		Handled:      atomic.LoadUint64(&actor.stats.handled),
+		HandleTotal:  time.Duration(atomic.LoadInt64(&actor.stats.handleTotal)),
+		HandleMax:    time.Duration(atomic.LoadInt64(&actor.stats.handleMax)),
+	}
+}
+
+// 所有actor统计(按名称排序)
+func Stats() []ActorStats {
+	mu.RLock()
+	stats := make([]ActorStats, 0, len(actors))
+	for _, actor := range actors {
+		stats = append(stats, actor.Stats())
+	}
+	mu.RUnlock()
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
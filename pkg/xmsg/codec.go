@@ -1,39 +1,31 @@
 package xmsg
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
 	"gotu/pkg/xnet"
 )
 
+const DefaultMaxFrameSize = 4 * 1024 * 1024 // 默认数据包上限(4M)
+
 var HeaderSizeof = binary.Size(Header{})
 
+// 默认编码: Seq|Cmd|Flag|Len 小端int32
+var DefaultCodec = MustNewCodec(CodecArgs{
+	Fields:       []Field{{FieldSeq, 4}, {FieldCmd, 4}, {FieldFlag, 4}, {FieldLen, 4}},
+	Order:        binary.LittleEndian,
+	MaxFrameSize: DefaultMaxFrameSize,
+})
+
 type MsgArgs struct {
 	State   interface{}
 	Header  *Header
-	Payload []byte
+	Payload []byte // 仅在回调内有效, 继续持有须xnet.Retain复制
 }
 
-// 解析数据包
+// 解析数据包(默认编码)
 func ParseMsgWarp(fn func(ctx context.Context, arg MsgArgs) error) xnet.OnHandlerOnce {
-	return xnet.OnHandlerOnce(func(ctx context.Context, state interface{}, msg []byte) (int, error) {
-		// header解析
-		if len(msg) < HeaderSizeof {
-			return 0, nil
-		}
-		header := &Header{}
-		ioReader := bytes.NewReader(msg[0:HeaderSizeof])
-		if err := binary.Read(ioReader, binary.LittleEndian, header); err != nil {
-			return 0, err
-		}
-		if len(msg) < HeaderSizeof+int(header.Len) {
-			return 0, nil
-		}
-		payload := msg[HeaderSizeof : HeaderSizeof+int(header.Len)]
-		err := fn(ctx, MsgArgs{State: state, Header: header, Payload: payload})
-		return HeaderSizeof + int(header.Len), err
-	})
+	return DefaultCodec.ParseMsgWarp(fn)
 }
 
 type PackMsgArgs struct {
@@ -43,14 +35,7 @@ type PackMsgArgs struct {
 	Payload []byte
 }
 
-// 打包数据
+// 打包数据(默认编码)
 func PackMsg(ctx context.Context, arg PackMsgArgs) ([]byte, error) {
-	header := &Header{Seq: arg.Seq, Cmd: arg.Cmd, Flag: arg.Flag, Len: int32(len(arg.Payload))}
-	ioWrite := bytes.NewBuffer(nil)
-	if err := binary.Write(ioWrite, binary.LittleEndian, header); err != nil {
-		return nil, err
-	}
-	msg := ioWrite.Bytes()
-	msg = append(msg, arg.Payload...)
-	return msg, nil
+	return DefaultCodec.PackMsg(ctx, arg)
 }
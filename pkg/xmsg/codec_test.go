@@ -0,0 +1,252 @@
+package xmsg_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"gotu/pkg/xlog"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 默认编码与原始小端int32 header一致
+func TestDefaultCodec(t *testing.T) {
+	ctx := context.Background()
+	header := &xmsg.Header{Seq: 1, Cmd: -2, Flag: 3, Len: 5}
+	buf := bytes.NewBuffer(nil)
+	if err := binary.Write(buf, binary.LittleEndian, header); err != nil {
+		panic(err)
+	}
+	buf.WriteString("hello")
+
+	msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Seq: 1, Cmd: -2, Flag: 3, Payload: []byte("hello")})
+	if err != nil {
+		panic(err)
+	}
+	if !bytes.Equal(msg, buf.Bytes()) {
+		t.Fatalf("pack %v != %v", msg, buf.Bytes())
+	}
+
+	decoded, payload, n, err := xmsg.DefaultCodec.Decode(msg)
+	if err != nil {
+		panic(err)
+	}
+	if n != len(msg) || *decoded != *header || string(payload) != "hello" {
+		t.Fatalf("decode %v %v %v", n, decoded, payload)
+	}
+}
+
+// 大端2字节长度协议: Len(2)|Cmd(2)|payload
+func TestBigEndianCodec(t *testing.T) {
+	codec := xmsg.MustNewCodec(xmsg.CodecArgs{
+		Fields:       []xmsg.Field{{Kind: xmsg.FieldLen, Width: 2}, {Kind: xmsg.FieldCmd, Width: 2}},
+		Order:        binary.BigEndian,
+		MaxFrameSize: 1024,
+	})
+	msg, err := codec.Encode(xmsg.PackMsgArgs{Cmd: 0x0102, Payload: []byte("abc")})
+	if err != nil {
+		panic(err)
+	}
+	if !bytes.Equal(msg, []byte{0x00, 0x03, 0x01, 0x02, 'a', 'b', 'c'}) {
+		t.Fatalf("encode %v", msg)
+	}
+
+	// 半包
+	for i := 0; i < len(msg); i++ {
+		if _, _, n, err := codec.Decode(msg[:i]); n != 0 || err != nil {
+			t.Fatalf("partial decode %v %v", n, err)
+		}
+	}
+	header, payload, n, err := codec.Decode(append(msg, 0xff))
+	if err != nil {
+		panic(err)
+	}
+	if n != len(msg) || header.Cmd != 0x0102 || header.Len != 3 || string(payload) != "abc" {
+		t.Fatalf("decode %v %v %v", n, header, payload)
+	}
+
+	if _, err := codec.Encode(xmsg.PackMsgArgs{Cmd: 1 << 16}); !errors.Is(err, xmsg.ErrFieldOverflow) {
+		t.Fatalf("encode overflow %v", err)
+	}
+	if _, err := codec.Encode(xmsg.PackMsgArgs{Payload: make([]byte, 1021)}); !errors.Is(err, xmsg.ErrFrameTooLarge) {
+		t.Fatalf("encode too large %v", err)
+	}
+}
+
+func TestVarintCodec(t *testing.T) {
+	codec := xmsg.MustNewCodec(xmsg.CodecArgs{
+		Fields:       []xmsg.Field{{Kind: xmsg.FieldCmd, Width: 1}, {Kind: xmsg.FieldLen, Width: xmsg.Varint}},
+		MaxFrameSize: 1024,
+	})
+	payload := bytes.Repeat([]byte{'x'}, 300)
+	msg, err := codec.Encode(xmsg.PackMsgArgs{Cmd: 7, Payload: payload})
+	if err != nil {
+		panic(err)
+	}
+	if len(msg) != 1+2+len(payload) {
+		t.Fatalf("encode len %v", len(msg))
+	}
+	header, decoded, n, err := codec.Decode(msg)
+	if err != nil {
+		panic(err)
+	}
+	if n != len(msg) || header.Cmd != 7 || !bytes.Equal(decoded, payload) {
+		t.Fatalf("decode %v %v", n, header)
+	}
+
+	// 长度声明超限立即报错, 不等待数据
+	if _, _, _, err := codec.Decode([]byte{7, 0xff, 0xff, 0x03}); !errors.Is(err, xmsg.ErrFrameTooLarge) {
+		t.Fatalf("decode too large %v", err)
+	}
+}
+
+func TestCodecArgs(t *testing.T) {
+	args := []xmsg.CodecArgs{
+		{Fields: []xmsg.Field{{Kind: xmsg.FieldLen, Width: 4}}},
+		{Fields: []xmsg.Field{{Kind: xmsg.FieldCmd, Width: 4}}, MaxFrameSize: 1024},
+		{Fields: []xmsg.Field{{Kind: xmsg.FieldLen, Width: 3}}, MaxFrameSize: 1024},
+		{Fields: []xmsg.Field{{Kind: xmsg.FieldLen, Width: 2}, {Kind: xmsg.FieldLen, Width: 2}}, MaxFrameSize: 1024},
+		{Fields: []xmsg.Field{{Kind: xmsg.FieldLen, Width: xmsg.Varint}}, LenIncludeHeader: true, MaxFrameSize: 1024},
+	}
+	for i, arg := range args {
+		if _, err := xmsg.NewCodec(arg); err == nil {
+			t.Fatalf("args %v should fail", i)
+		}
+	}
+}
+
+// 超过上限的数据包关闭连接
+func TestFrameTooLarge(t *testing.T) {
+	ctx := context.Background()
+	codec := xmsg.MustNewCodec(xmsg.CodecArgs{
+		Fields:       []xmsg.Field{{Kind: xmsg.FieldLen, Width: 2}},
+		Order:        binary.BigEndian,
+		MaxFrameSize: 64,
+	})
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{
+		Addr:         "127.0.0.1:9931",
+		OnConnect:    func(ctx context.Context, sock xnet.Socket) interface{} { return sock },
+		OnDisconnect: func(ctx context.Context, state interface{}) {},
+		OnMsg: codec.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+			msg, err := codec.PackMsg(ctx, xmsg.PackMsgArgs{Payload: arg.Payload})
+			if err != nil {
+				return err
+			}
+			return arg.State.(xnet.Socket).SendMsg(ctx, msg)
+		}),
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	conn, err := net.Dial("tcp", "127.0.0.1:9931")
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(3 * time.Second)); err != nil {
+		panic(err)
+	}
+
+	// 正常回包
+	if _, err := conn.Write([]byte{0x00, 0x02, 'o', 'k'}); err != nil {
+		panic(err)
+	}
+	resp := make([]byte, 4)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		panic(err)
+	}
+	if string(resp[2:]) != "ok" {
+		t.Fatalf("resp %v", resp)
+	}
+
+	// 声明超长数据包, 服务端应断开连接
+	if _, err := conn.Write([]byte{0xff, 0xff}); err != nil {
+		panic(err)
+	}
+	n, err := conn.Read(resp)
+	xlog.Get(ctx).Debug("Read after too large frame", zap.Int("n", n), zap.Any("err", err))
+	if n != 0 || err == nil {
+		t.Fatalf("connection not closed: %v %v", n, err)
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatalf("connection not closed: %v", err)
+	}
+}
+
+// 心跳保留cmd按字段宽度取最大值
+func TestHeartbeatCodec(t *testing.T) {
+	hb := xmsg.Heartbeat()
+	ping := hb.Ping(123)
+	header, _, _, err := xmsg.DefaultCodec.Decode(ping)
+	if err != nil {
+		panic(err)
+	}
+	if ping, pong, err := xmsg.DefaultCodec.HeartbeatCmd(); err != nil || header.Cmd != ping || ping != -1 || pong != -2 {
+		t.Fatalf("ping cmd %v reserved %v %v %v", header.Cmd, ping, pong, err)
+	}
+	kind, sendAt, n, err := hb.Parse(append(hb.Pong(123), 0xff))
+	if err != nil || kind != xnet.HeartbeatPong || sendAt != 123 || n != len(ping) {
+		t.Fatalf("parse pong %v %v %v %v", kind, sendAt, n, err)
+	}
+	msg, err := xmsg.PackMsg(context.Background(), xmsg.PackMsgArgs{Cmd: 1, Payload: []byte("data")})
+	if err != nil {
+		panic(err)
+	}
+	if kind, _, n, err := hb.Parse(msg); kind != xnet.HeartbeatNone || n != 0 || err != nil {
+		t.Fatalf("parse msg %v %v %v", kind, n, err)
+	}
+	// 非心跳帧只读取cmd, 长度校验由OnMsg解码时处理
+	tooLarge := append([]byte(nil), msg[:16]...)
+	binary.LittleEndian.PutUint32(tooLarge[12:], 1<<30)
+	if _, _, _, err := xmsg.DefaultCodec.Decode(tooLarge); err == nil {
+		t.Fatalf("decode too large frame should fail")
+	}
+	if kind, _, n, err := hb.Parse(tooLarge); kind != xnet.HeartbeatNone || n != 0 || err != nil {
+		t.Fatalf("parse too large msg %v %v %v", kind, n, err)
+	}
+
+	// cmd宽度2字节: ping=0xffff
+	codec := xmsg.MustNewCodec(xmsg.CodecArgs{
+		Fields:       []xmsg.Field{{Kind: xmsg.FieldLen, Width: 2}, {Kind: xmsg.FieldCmd, Width: 2}},
+		Order:        binary.BigEndian,
+		MaxFrameSize: 1024,
+	})
+	hb, err = codec.Heartbeat()
+	if err != nil {
+		panic(err)
+	}
+	if ping := hb.Ping(1); !bytes.Equal(ping[:4], []byte{0x00, 0x08, 0xff, 0xff}) {
+		t.Fatalf("ping %v", ping)
+	}
+	if ping, pong, err := codec.HeartbeatCmd(); err != nil || ping != 0xffff || pong != 0xfffe {
+		t.Fatalf("reserved cmd %v %v %v", ping, pong, err)
+	}
+
+	// cmd前有Varint字段: 完整解码识别
+	varint := xmsg.MustNewCodec(xmsg.CodecArgs{Fields: []xmsg.Field{{Kind: xmsg.FieldLen, Width: xmsg.Varint}, {Kind: xmsg.FieldCmd, Width: xmsg.Varint}}, MaxFrameSize: 1024})
+	hb, err = varint.Heartbeat()
+	if err != nil {
+		panic(err)
+	}
+	if kind, sendAt, n, err := hb.Parse(hb.Ping(7)); err != nil || kind != xnet.HeartbeatPing || sendAt != 7 || n != len(hb.Ping(7)) {
+		t.Fatalf("parse varint ping %v %v %v %v", kind, sendAt, n, err)
+	}
+
+	// 无cmd字段
+	noCmd := xmsg.MustNewCodec(xmsg.CodecArgs{Fields: []xmsg.Field{{Kind: xmsg.FieldLen, Width: 2}}, MaxFrameSize: 1024})
+	if _, _, err := noCmd.HeartbeatCmd(); err == nil {
+		t.Fatalf("heartbeat cmd without cmd should fail")
+	}
+	if _, err := noCmd.Heartbeat(); err == nil {
+		t.Fatalf("heartbeat without cmd should fail")
+	}
+}
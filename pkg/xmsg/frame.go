@@ -0,0 +1,294 @@
+package xmsg
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"gotu/pkg/xnet"
+)
+
+var (
+	ErrFrameTooLarge = errors.New("frame too large") // 数据包超过上限, 连接将被关闭
+	ErrFieldOverflow = errors.New("field overflow")  // 字段值超出编码宽度
+)
+
+// header字段
+type FieldKind int
+
+const (
+	FieldSeq FieldKind = iota
+	FieldCmd
+	FieldFlag
+	FieldLen
+)
+
+func (k FieldKind) String() string {
+	switch k {
+	case FieldSeq:
+		return "seq"
+	case FieldCmd:
+		return "cmd"
+	case FieldFlag:
+		return "flag"
+	case FieldLen:
+		return "len"
+	default:
+		return fmt.Sprintf("field[%d]", int(k))
+	}
+}
+
+const (
+	Varint        = -1        // 变长编码(uvarint)
+	maxVarintSize = 5         // uint32 uvarint最大字节数
+	maxFrameLimit = 1<<31 - 1 // MaxFrameSize上限
+)
+
+// header字段定义, 固定宽度字段按无符号整数编码
+type Field struct {
+	Kind  FieldKind
+	Width int // 1, 2, 4 或 Varint
+}
+
+type CodecArgs struct {
+	Fields           []Field          // header字段(按顺序编码), 必须包含FieldLen
+	Order            binary.ByteOrder // 字节序(默认LittleEndian)
+	LenIncludeHeader bool             // Len是否包含header长度(不支持Varint)
+	MaxFrameSize     int              // 单个数据包(header+payload)上限, 必填
+}
+
+// 数据包编解码
+type Codec struct {
+	fields           []Field
+	order            binary.ByteOrder
+	lenIncludeHeader bool
+	maxFrameSize     int
+	headerSize       int // 固定header长度(含Varint字段时为最小长度)
+}
+
+func NewCodec(arg CodecArgs) (*Codec, error) {
+	if arg.MaxFrameSize <= 0 || arg.MaxFrameSize > maxFrameLimit {
+		return nil, fmt.Errorf("max frame size[%d] invalid", arg.MaxFrameSize)
+	}
+	if arg.Order == nil {
+		arg.Order = binary.LittleEndian
+	}
+	c := &Codec{
+		fields:           append(make([]Field, 0, len(arg.Fields)), arg.Fields...),
+		order:            arg.Order,
+		lenIncludeHeader: arg.LenIncludeHeader,
+		maxFrameSize:     arg.MaxFrameSize,
+	}
+
+	kinds := make(map[FieldKind]bool)
+	hasVarint := false
+	for _, f := range c.fields {
+		if f.Kind < FieldSeq || f.Kind > FieldLen || kinds[f.Kind] {
+			return nil, fmt.Errorf("field %v invalid or repeated", f.Kind)
+		}
+		kinds[f.Kind] = true
+		switch f.Width {
+		case 1, 2, 4:
+			c.headerSize += f.Width
+		case Varint:
+			hasVarint = true
+			c.headerSize += 1
+		default:
+			return nil, fmt.Errorf("field %v width[%d] invalid", f.Kind, f.Width)
+		}
+	}
+	if !kinds[FieldLen] {
+		return nil, fmt.Errorf("field len is required")
+	}
+	if hasVarint && c.lenIncludeHeader {
+		return nil, fmt.Errorf("len include header not support varint field")
+	}
+	if c.headerSize > c.maxFrameSize {
+		return nil, fmt.Errorf("max frame size[%d] less than header size[%d]", c.maxFrameSize, c.headerSize)
+	}
+	return c, nil
+}
+
+func MustNewCodec(arg CodecArgs) *Codec {
+	c, err := NewCodec(arg)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// 最大数据包长度
+func (c *Codec) MaxFrameSize() int {
+	return c.maxFrameSize
+}
+
+// 最大payload长度(含Varint字段时按最小header计算)
+func (c *Codec) MaxPayloadSize() int {
+	return c.maxFrameSize - c.headerSize
+}
+
+// 解析数据包
+// n == 0: 数据不足, 等待更多数据
+// 数据包超过上限返回ErrFrameTooLarge
+func (c *Codec) Decode(msg []byte) (header *Header, payload []byte, n int, err error) {
+	header = &Header{}
+	offset := 0
+	for _, f := range c.fields {
+		var v uint32
+		if f.Width == Varint {
+			u, size := binary.Uvarint(msg[offset:])
+			if size == 0 {
+				if len(msg)-offset >= maxVarintSize {
+					return nil, nil, 0, fmt.Errorf("field %v varint overflow", f.Kind)
+				}
+				return nil, nil, 0, nil
+			}
+			if size < 0 || u > 1<<32-1 {
+				return nil, nil, 0, fmt.Errorf("field %v varint overflow", f.Kind)
+			}
+			v = uint32(u)
+			offset += size
+		} else {
+			if len(msg) < offset+f.Width {
+				return nil, nil, 0, nil
+			}
+			v = c.getUint(msg[offset:], f.Width)
+			offset += f.Width
+		}
+		c.setField(header, f.Kind, v)
+	}
+
+	// 先校验长度再等待数据, 避免缓存无限增长
+	frameSize := int64(uint32(header.Len))
+	if !c.lenIncludeHeader {
+		frameSize += int64(offset)
+	}
+	if frameSize > int64(c.maxFrameSize) {
+		return nil, nil, 0, fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, frameSize, c.maxFrameSize)
+	}
+	if frameSize < int64(offset) {
+		return nil, nil, 0, fmt.Errorf("frame size[%d] less than header size[%d]", frameSize, offset)
+	}
+	if len(msg) < int(frameSize) {
+		return nil, nil, 0, nil
+	}
+	header.Len = int32(frameSize) - int32(offset)
+	return header, msg[offset:frameSize], int(frameSize), nil
+}
+
+// 打包数据包: header + payload
+func (c *Codec) Encode(arg PackMsgArgs) ([]byte, error) {
+	header := &Header{Seq: arg.Seq, Cmd: arg.Cmd, Flag: arg.Flag, Len: int32(len(arg.Payload))}
+	headerSize := c.encodedHeaderSize(header)
+	if headerSize+len(arg.Payload) > c.maxFrameSize {
+		return nil, fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, headerSize+len(arg.Payload), c.maxFrameSize)
+	}
+	if c.lenIncludeHeader {
+		header.Len += int32(headerSize)
+	}
+
+	msg := make([]byte, 0, headerSize+len(arg.Payload))
+	for _, f := range c.fields {
+		v := c.getField(header, f.Kind)
+		if f.Width == Varint {
+			var buf [binary.MaxVarintLen32]byte
+			msg = append(msg, buf[:binary.PutUvarint(buf[:], uint64(uint32(v)))]...)
+			continue
+		}
+		if f.Width < 4 && (v < 0 || v >= 1<<(8*f.Width)) {
+			return nil, fmt.Errorf("%w: %v[%d] width[%d]", ErrFieldOverflow, f.Kind, v, f.Width)
+		}
+		msg = c.appendUint(msg, uint32(v), f.Width)
+	}
+	return append(msg, arg.Payload...), nil
+}
+
+// 解析数据包(xnet消息处理)
+func (c *Codec) ParseMsgWarp(fn func(ctx context.Context, arg MsgArgs) error) xnet.OnHandlerOnce {
+	return xnet.OnHandlerOnce(func(ctx context.Context, state interface{}, msg []byte) (int, error) {
+		header, payload, n, err := c.Decode(msg)
+		if err != nil || n == 0 {
+			return 0, err
+		}
+		err = fn(ctx, MsgArgs{State: state, Header: header, Payload: payload})
+		return n, err
+	})
+}
+
+// 打包数据
+func (c *Codec) PackMsg(ctx context.Context, arg PackMsgArgs) ([]byte, error) {
+	return c.Encode(arg)
+}
+
+func (c *Codec) encodedHeaderSize(header *Header) int {
+	size := 0
+	for _, f := range c.fields {
+		if f.Width == Varint {
+			size += uvarintSize(uint64(uint32(c.getField(header, f.Kind))))
+		} else {
+			size += f.Width
+		}
+	}
+	return size
+}
+
+func (c *Codec) getUint(b []byte, width int) uint32 {
+	switch width {
+	case 1:
+		return uint32(b[0])
+	case 2:
+		return uint32(c.order.Uint16(b))
+	default:
+		return c.order.Uint32(b)
+	}
+}
+
+func (c *Codec) appendUint(b []byte, v uint32, width int) []byte {
+	switch width {
+	case 1:
+		return append(b, byte(v))
+	case 2:
+		var buf [2]byte
+		c.order.PutUint16(buf[:], uint16(v))
+		return append(b, buf[:]...)
+	default:
+		var buf [4]byte
+		c.order.PutUint32(buf[:], v)
+		return append(b, buf[:]...)
+	}
+}
+
+func (c *Codec) setField(header *Header, kind FieldKind, v uint32) {
+	switch kind {
+	case FieldSeq:
+		header.Seq = int32(v)
+	case FieldCmd:
+		header.Cmd = int32(v)
+	case FieldFlag:
+		header.Flag = int32(v)
+	case FieldLen:
+		header.Len = int32(v)
+	}
+}
+
+func (c *Codec) getField(header *Header, kind FieldKind) int32 {
+	switch kind {
+	case FieldSeq:
+		return header.Seq
+	case FieldCmd:
+		return header.Cmd
+	case FieldFlag:
+		return header.Flag
+	default:
+		return header.Len
+	}
+}
+
+func uvarintSize(v uint64) int {
+	size := 1
+	for v >= 0x80 {
+		v >>= 7
+		size++
+	}
+	return size
+}
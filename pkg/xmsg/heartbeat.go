@@ -0,0 +1,126 @@
+package xmsg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"gotu/pkg/xnet"
+)
+
+const heartbeatPayloadSize = 8 // 发送时间(unixnano)
+
+// 心跳帧: 保留cmd为cmd字段宽度内的最大值(ping)与次大值(pong), 4字节/Varint即-1/-2
+type heartbeatCodec struct {
+	codec     *Codec
+	ping      int32
+	pong      int32
+	cmdOffset int // cmd字段固定偏移, -1: 前有Varint字段, 需完整解码
+	cmdWidth  int
+}
+
+// 心跳保留cmd(ping, pong), 取值依赖cmd字段宽度: 默认编码为-1/-2, 2字节为0xffff/0xfffe
+func (c *Codec) HeartbeatCmd() (int32, int32, error) {
+	width := 0
+	for _, f := range c.fields {
+		if f.Kind == FieldCmd {
+			width = f.Width
+		}
+	}
+	if width == 0 {
+		return 0, 0, fmt.Errorf("heartbeat requires field cmd")
+	}
+	limit := uint32(1<<32 - 1)
+	if width != Varint && width < 4 {
+		limit = 1<<(8*width) - 1
+	}
+	return int32(limit), int32(limit - 1), nil
+}
+
+// tcp心跳帧编解码(xnet.HeartbeatOptions.Codec), 业务不可使用保留cmd(HeartbeatCmd)
+func (c *Codec) Heartbeat() (xnet.HeartbeatCodec, error) {
+	ping, pong, err := c.HeartbeatCmd()
+	if err != nil {
+		return nil, err
+	}
+	h := &heartbeatCodec{codec: c, ping: ping, pong: pong}
+	for _, f := range c.fields {
+		if f.Kind == FieldCmd {
+			h.cmdWidth = f.Width
+			break
+		}
+		if f.Width == Varint {
+			h.cmdOffset = -1
+			break
+		}
+		h.cmdOffset += f.Width
+	}
+	if _, err := c.Encode(PackMsgArgs{Cmd: h.ping, Payload: make([]byte, heartbeatPayloadSize)}); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// 心跳帧编解码(默认编码)
+func Heartbeat() xnet.HeartbeatCodec {
+	hb, err := DefaultCodec.Heartbeat()
+	if err != nil {
+		panic(err)
+	}
+	return hb
+}
+
+func (h *heartbeatCodec) Ping(sendAt int64) []byte {
+	return h.encode(h.ping, sendAt)
+}
+
+func (h *heartbeatCodec) Pong(sendAt int64) []byte {
+	return h.encode(h.pong, sendAt)
+}
+
+func (h *heartbeatCodec) encode(cmd int32, sendAt int64) []byte {
+	payload := make([]byte, heartbeatPayloadSize)
+	h.codec.order.PutUint64(payload, uint64(sendAt))
+	// 字段宽度及长度已在Heartbeat中校验
+	msg, _ := h.codec.Encode(PackMsgArgs{Cmd: cmd, Payload: payload})
+	return msg
+}
+
+// 读取固定偏移处的cmd, 数据不足或无效返回false
+func (h *heartbeatCodec) peekCmd(msg []byte) (int32, bool) {
+	if len(msg) <= h.cmdOffset {
+		return 0, false
+	}
+	if h.cmdWidth == Varint {
+		u, size := binary.Uvarint(msg[h.cmdOffset:])
+		return int32(uint32(u)), size > 0 && u <= 1<<32-1
+	}
+	if len(msg) < h.cmdOffset+h.cmdWidth {
+		return 0, false
+	}
+	return int32(h.codec.getUint(msg[h.cmdOffset:], h.cmdWidth)), true
+}
+
+// cmd偏移固定时先读取cmd, 非心跳帧不解码header(由OnMsg解码及校验)
+func (h *heartbeatCodec) Parse(msg []byte) (xnet.HeartbeatKind, int64, int, error) {
+	if h.cmdOffset >= 0 {
+		if cmd, ok := h.peekCmd(msg); !ok || (cmd != h.ping && cmd != h.pong) {
+			return xnet.HeartbeatNone, 0, 0, nil
+		}
+	}
+	header, payload, n, err := h.codec.Decode(msg)
+	if err != nil || n == 0 {
+		return xnet.HeartbeatNone, 0, 0, err
+	}
+	var kind xnet.HeartbeatKind
+	switch header.Cmd {
+	case h.ping:
+		kind = xnet.HeartbeatPing
+	case h.pong:
+		kind = xnet.HeartbeatPong
+	default:
+		return xnet.HeartbeatNone, 0, 0, nil
+	}
+	if len(payload) != heartbeatPayloadSize {
+		return xnet.HeartbeatNone, 0, 0, fmt.Errorf("heartbeat payload size[%d] invalid", len(payload))
+	}
+	return kind, int64(h.codec.order.Uint64(payload)), n, nil
+}
@@ -0,0 +1,127 @@
+package xnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gotu/pkg/xlog"
+	"net"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+var ErrAccessDenied = errors.New("access denied") // 未通过ip黑白名单
+
+// 准入检查, 拒绝返回false
+func admit(ctx context.Context, onAccept OnAccept, remote net.Addr) bool {
+	if onAccept == nil {
+		return true
+	}
+	if err := onAccept(ctx, remote); err != nil {
+		xlog.Get(ctx).Debug("Connection not accepted.", zap.Any("remote", remote), zap.Any("err", err))
+		return false
+	}
+	return true
+}
+
+// ip黑白名单(CIDR或单个ip), 线程安全, 可运行时Reload
+// 命中deny拒绝; allow非空时须命中allow
+type AccessList struct {
+	rules atomic.Value // *accessRules
+}
+
+type accessRules struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+func NewAccessList(allow, deny []string) (*AccessList, error) {
+	acl := &AccessList{}
+	if err := acl.Reload(allow, deny); err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
+
+// 替换规则, 解析失败保留原规则
+func (acl *AccessList) Reload(allow, deny []string) error {
+	allowNets, err := parseCIDRs(allow)
+	if err != nil {
+		return err
+	}
+	denyNets, err := parseCIDRs(deny)
+	if err != nil {
+		return err
+	}
+	acl.rules.Store(&accessRules{allow: allowNets, deny: denyNets})
+	return nil
+}
+
+// ip是否允许
+func (acl *AccessList) Allowed(ip net.IP) bool {
+	rules := acl.rules.Load().(*accessRules)
+	if containsIP(rules.deny, ip) {
+		return false
+	}
+	return len(rules.allow) == 0 || containsIP(rules.allow, ip)
+}
+
+// 作为服务端OnAccept
+func (acl *AccessList) OnAccept(ctx context.Context, remote net.Addr) error {
+	if !acl.Allowed(addrToIP(remote)) {
+		return fmt.Errorf("%w: %v", ErrAccessDenied, remote)
+	}
+	return nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// 解析CIDR, 单个ip视为/32(ipv6为/128)
+func parseCIDRs(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip %q", s)
+			}
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q: %w", s, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// 地址对应ip, 无法解析返回nil
+func addrToIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	return net.ParseIP(host)
+}
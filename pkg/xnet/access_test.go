@@ -0,0 +1,144 @@
+package xnet_test
+
+import (
+	"context"
+	"errors"
+	"gotu/pkg/xnet"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAccessList(t *testing.T) {
+	ctx := context.Background()
+	acl, err := xnet.NewAccessList([]string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32"}, []string{"10.1.0.0/16"})
+	if err != nil {
+		panic(err)
+	}
+	cases := map[string]bool{
+		"10.2.3.4":    true,
+		"10.1.2.3":    false,
+		"192.168.1.1": true,
+		"192.168.1.2": false,
+		"2001:db8::1": true,
+		"::1":         false,
+	}
+	for ip, allowed := range cases {
+		if acl.Allowed(net.ParseIP(ip)) != allowed {
+			t.Fatalf("%v allowed %v", ip, !allowed)
+		}
+	}
+	if err := acl.OnAccept(ctx, &net.TCPAddr{IP: net.ParseIP("10.1.0.1"), Port: 80}); !errors.Is(err, xnet.ErrAccessDenied) {
+		t.Fatalf("on accept err %v", err)
+	}
+
+	// 解析失败保留原规则
+	if err := acl.Reload([]string{"10.0.0.0/33"}, nil); err == nil {
+		t.Fatalf("reload invalid cidr success")
+	}
+	if acl.Allowed(net.ParseIP("10.1.2.3")) {
+		t.Fatalf("rules changed after invalid reload")
+	}
+	if err := acl.Reload(nil, []string{"10.2.0.0/16"}); err != nil {
+		panic(err)
+	}
+	if !acl.Allowed(net.ParseIP("10.1.2.3")) || acl.Allowed(net.ParseIP("10.2.3.4")) {
+		t.Fatalf("reload not applied")
+	}
+}
+
+// 拒绝的连接不触发OnConnect, Reload后放行
+func TestTCPOnAccept(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9904"
+	acl, err := xnet.NewAccessList(nil, []string{"127.0.0.0/8"})
+	if err != nil {
+		panic(err)
+	}
+	var accepted, connected int32
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{
+		Addr: addr,
+		OnAccept: func(ctx context.Context, remote net.Addr) error {
+			err := acl.OnAccept(ctx, remote)
+			if err == nil {
+				atomic.AddInt32(&accepted, 1)
+			}
+			return err
+		},
+		OnConnect: func(ctx context.Context, sock xnet.Socket) interface{} {
+			atomic.AddInt32(&connected, 1)
+			return sock
+		},
+		OnDisconnect: onDisconnectLog,
+		OnMsg:        echoHandler(),
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	newCli := func() *xnet.TCPClient {
+		cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+		if err != nil {
+			panic(err)
+		}
+		return cli
+	}
+	cli1 := newCli()
+	defer cli1.Close(ctx)
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadInt32(&connected) != 0 {
+		t.Fatalf("denied conn connected")
+	}
+
+	if err := acl.Reload([]string{"127.0.0.1"}, nil); err != nil {
+		panic(err)
+	}
+	cli2 := newCli()
+	defer cli2.Close(ctx)
+	waitUntil(t, "connected", func() bool { return atomic.LoadInt32(&connected) == 1 && atomic.LoadInt32(&accepted) == 1 })
+}
+
+// udp未通过准入不创建session
+func TestUDPOnAccept(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9905"
+	acl, err := xnet.NewAccessList([]string{"10.0.0.0/8"}, nil)
+	if err != nil {
+		panic(err)
+	}
+	var connected int32
+	svr, err := xnet.NewUDPServer(ctx, xnet.UDPSvrArgs{
+		Addr:     addr,
+		Timeout:  10,
+		OnAccept: acl.OnAccept,
+		OnConnect: func(ctx context.Context, sock xnet.Socket) interface{} {
+			atomic.AddInt32(&connected, 1)
+			return sock
+		},
+		OnDisconnect: onDisconnectLog,
+		OnMsg:        echoHandler(),
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	cli, err := xnet.NewUDPClient(ctx, xnet.UDPCliArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	sendPayloads(ctx, cli, 1)
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadInt32(&connected) != 0 {
+		t.Fatalf("denied session connected")
+	}
+	if err := acl.Reload([]string{"127.0.0.1"}, nil); err != nil {
+		panic(err)
+	}
+	sendPayloads(ctx, cli, 1)
+	waitUntil(t, "session connected", func() bool { return atomic.LoadInt32(&connected) == 1 })
+}
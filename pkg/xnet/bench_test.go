@@ -0,0 +1,252 @@
+package xnet_test
+
+import (
+	"context"
+	"fmt"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tcp读路径: 同cmd/tcp_cli, 小包(时间戳payload)持续发送, 服务端ParseMsgWarp解析
+// copy: 改造前的读路径(基线), direct: 读缓存零拷贝
+func BenchmarkTCPReadPath(b *testing.B) {
+	b.Run("copy", func(b *testing.B) {
+		benchmarkTCPRead(b, "127.0.0.1:9928", func(ctx context.Context, addr string, onMsg xnet.OnHandlerOnce) func() {
+			l, err := newCopyReadServer(addr, onMsg)
+			if err != nil {
+				panic(err)
+			}
+			return func() { l.Close() }
+		})
+	})
+	b.Run("direct", func(b *testing.B) {
+		benchmarkTCPRead(b, "127.0.0.1:9908", func(ctx context.Context, addr string, onMsg xnet.OnHandlerOnce) func() {
+			svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: onMsg})
+			if err != nil {
+				panic(err)
+			}
+			return func() { svr.Close(ctx) }
+		})
+	})
+}
+
+func benchmarkTCPRead(b *testing.B, addr string, serve func(ctx context.Context, addr string, onMsg xnet.OnHandlerOnce) func()) {
+	ctx := context.Background()
+	var recv int64
+	closeFn := serve(ctx, addr, xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+		atomic.AddInt64(&recv, 1)
+		return nil
+	}))
+	defer closeFn()
+
+	cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{Addr: addr, WriteQueue: xnet.WriteQueueOptions{Policy: xnet.PolicyBlock},
+		OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: []byte(fmt.Sprintf("%d", time.Now().UnixMilli()))})
+	if err != nil {
+		panic(err)
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(msg)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := cli.SendMsg(ctx, msg); err != nil {
+			panic(err)
+		}
+	}
+	for atomic.LoadInt64(&recv) < int64(b.N) {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// 改造前的读路径: 每次读入池化buffer(1KB), 追加拷贝至缓存后解析, 已处理数据拷贝回池
+func newCopyReadServer(addr string, onMsg xnet.OnHandlerOnce) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go copyReadLoop(conn, onMsg)
+		}
+	}()
+	return l, nil
+}
+
+func copyReadLoop(conn net.Conn, onMsg xnet.OnHandlerOnce) {
+	defer conn.Close()
+	ctx := context.Background()
+	size := 1024
+	pool := &sync.Pool{New: func() interface{} {
+		bs := make([]byte, size)
+		return &bs
+	}}
+	var free, cache []byte
+	put := func(bs []byte) {
+		free = append(free, bs...)
+		for len(free) >= size {
+			buf := free[:size]
+			pool.Put(&buf)
+			free = free[size:]
+		}
+	}
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(time.Minute)); err != nil {
+			return
+		}
+		buf := *pool.Get().(*[]byte)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		put(buf[n:])
+		cache = append(cache, buf[:n]...)
+		for {
+			count, err := onMsg(ctx, nil, cache)
+			if err != nil {
+				return
+			}
+			if count == 0 {
+				break
+			}
+			put(cache[:count])
+			cache = cache[count:]
+		}
+	}
+}
+
+// tcp写路径: 多协程并发SendMsg同一连接, 写协程批量发送
+// single: 每条消息单独写入(基线, WriteBatchMsgs=1), writev: 默认批量写入
+func BenchmarkTCPWritePath(b *testing.B) {
+	for _, size := range []int{16, 1024} {
+		for _, mode := range []struct {
+			name      string
+			batchMsgs int
+		}{{"single", 1}, {"writev", 0}} {
+			b.Run(fmt.Sprintf("size-%d/%s", size, mode.name), func(b *testing.B) {
+				benchmarkTCPWrite(b, size, mode.batchMsgs)
+			})
+		}
+	}
+}
+
+func benchmarkTCPWrite(b *testing.B, size int, batchMsgs int) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9910"
+	var recv int64
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog,
+		OnMsg: xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+			atomic.AddInt64(&recv, 1)
+			return nil
+		})})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{Addr: addr, WriteQueue: xnet.WriteQueueOptions{Policy: xnet.PolicyBlock, MaxMsgs: 4096},
+		Options: xnet.Options{WriteBatchMsgs: batchMsgs}, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: make([]byte, size)})
+	if err != nil {
+		panic(err)
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(msg)))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := cli.SendMsg(ctx, msg); err != nil {
+				panic(err)
+			}
+		}
+	})
+	for atomic.LoadInt64(&recv) < int64(b.N) {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// udp批量收发: 客户端与服务端同时开启Batch(recvmmsg/sendmmsg), 多条流并发收发, 对比逐个收发
+func BenchmarkUDPBatch(b *testing.B) {
+	for _, batch := range []int{1, 32} {
+		b.Run(fmt.Sprintf("batch-%d", batch), func(b *testing.B) {
+			benchmarkUDPBatch(b, batch)
+		})
+	}
+}
+
+func benchmarkUDPBatch(b *testing.B, batch int) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9916"
+	svr, err := xnet.NewUDPServer(ctx, xnet.UDPSvrArgs{Addr: addr, Timeout: 10, Batch: batch, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: make([]byte, 64)})
+	if err != nil {
+		panic(err)
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(msg)))
+	runFlows(b, func() (func(n int) int, func()) {
+		recvCh := make(chan struct{}, benchUDPWindow)
+		cli, err := xnet.NewUDPClient(ctx, xnet.UDPCliArgs{Addr: addr, Timeout: 10, Batch: batch, WriteQueue: xnet.WriteQueueOptions{Policy: xnet.PolicyBlock},
+			OnConnect: onConnectSock, OnDisconnect: onDisconnectLog,
+			OnMsg: xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+				select {
+				case recvCh <- struct{}{}:
+				default:
+				}
+				return nil
+			})})
+		if err != nil {
+			panic(err)
+		}
+		timer := time.NewTimer(benchUDPTimeout)
+		roundTrip := func(n int) int {
+			for i := 0; i < n; i++ {
+				if err := cli.SendMsg(ctx, msg); err != nil {
+					panic(err)
+				}
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(benchUDPTimeout)
+			for i := 0; i < n; i++ {
+				select {
+				case <-recvCh:
+				case <-timer.C:
+					return i
+				}
+			}
+			return n
+		}
+		return roundTrip, func() { cli.Close(ctx) }
+	})
+}
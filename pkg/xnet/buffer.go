@@ -2,43 +2,126 @@ package xnet
 
 import "sync"
 
+// 批量写入帧缓存对象池(不支持writev的连接)
+var framePool = sync.Pool{
+	New: func() interface{} {
+		bs := make([]byte, 0, writeBatchBytes)
+		return &bs
+	},
+}
+
+func getFrame(size int) *[]byte {
+	frame := framePool.Get().(*[]byte)
+	if cap(*frame) < size {
+		*frame = make([]byte, 0, size)
+	}
+	return frame
+}
+
+// 超过上限的帧交由gc
+func putFrame(frame *[]byte) {
+	if cap(*frame) > maxFrameSize {
+		return
+	}
+	*frame = (*frame)[:0]
+	framePool.Put(frame)
+}
+
 type bufferManager struct {
 	pool *sync.Pool // buf对象池
+	size int        // 单个buf大小
 }
 
-func newBufferManager() *bufferManager {
+func newBufferManager(size int) *bufferManager {
 	return &bufferManager{
 		pool: &sync.Pool{
 			New: func() interface{} {
-				bs := make([]byte, readBufferSize)
+				bs := make([]byte, size)
 				return &bs
 			},
 		},
+		size: size,
 	}
 }
 
-func (mgr *bufferManager) newBufferPool() *bufferPool {
-	return &bufferPool{
-		pool:  mgr.pool,
-		cache: make([]byte, 0),
+func (mgr *bufferManager) get() []byte {
+	return *mgr.pool.Get().(*[]byte)
+}
+
+// 仅回收默认大小的buf(扩容后的buf交由gc)
+func (mgr *bufferManager) put(buf []byte) {
+	if cap(buf) != mgr.size {
+		return
 	}
+	buf = buf[:mgr.size]
+	mgr.pool.Put(&buf)
+}
+
+func (mgr *bufferManager) newReadBuffer() *readBuffer {
+	return &readBuffer{mgr: mgr}
 }
 
-// 非线程安全, 仅限单协程使用(read loop)
-type bufferPool struct {
-	pool  *sync.Pool // buf对象池
-	cache []byte
+// 读缓存, 非线程安全, 仅限单协程使用(read loop)
+// 数据直接读入连续buf, 交由OnHandlerOnce的msg为buf切片(零拷贝), 仅在回调内有效
+// 已处理数据不再回收至对象池; 剩余空间不足时前移未处理数据, 仍不足时扩容
+type readBuffer struct {
+	mgr  *bufferManager
+	buf  []byte
+	r, w int // 未处理数据buf[r:w]
 }
 
-func (bp *bufferPool) put(cache []byte) {
-	bp.cache = append(bp.cache, cache...)
-	for len(bp.cache) >= readBufferSize {
-		tempBuf := bp.cache[0:readBufferSize]
-		bp.pool.Put(&tempBuf)
-		bp.cache = bp.cache[readBufferSize:]
+// 可读入空间
+func (rb *readBuffer) space() []byte {
+	if rb.buf == nil {
+		rb.buf = rb.mgr.get()
 	}
+	// 剩余空间不足1/4时前移, 避免小块读取
+	if free := len(rb.buf) - rb.w; free < len(rb.buf)/4 || free == 0 {
+		if rb.r > 0 {
+			rb.w = copy(rb.buf, rb.buf[rb.r:rb.w])
+			rb.r = 0
+		}
+		if rb.w == len(rb.buf) {
+			buf := make([]byte, 2*len(rb.buf))
+			copy(buf, rb.buf[:rb.w])
+			rb.mgr.put(rb.buf)
+			rb.buf = buf
+		}
+	}
+	return rb.buf[rb.w:]
+}
+
+// 读入n字节
+func (rb *readBuffer) written(n int) {
+	rb.w += n
+}
+
+// 未处理数据
+func (rb *readBuffer) data() []byte {
+	if rb.buf == nil {
+		return nil
+	}
+	return rb.buf[rb.r:rb.w]
 }
 
-func (bp *bufferPool) get() []byte {
-	return *bp.pool.Get().(*[]byte)
+// 已处理n字节
+func (rb *readBuffer) consume(n int) {
+	rb.r += n
+	if rb.r < rb.w {
+		return
+	}
+	rb.r, rb.w = 0, 0
+	// 扩容超过上限的buf处理完成后换回默认大小, 避免大消息长期占用内存
+	if len(rb.buf) > maxReadBufferSize && len(rb.buf) > rb.mgr.size {
+		rb.buf = rb.mgr.get()
+	}
+}
+
+// 读循环退出时归还buf
+func (rb *readBuffer) release() {
+	if rb.buf != nil {
+		rb.mgr.put(rb.buf)
+		rb.buf = nil
+	}
+	rb.r, rb.w = 0, 0
 }
@@ -2,6 +2,7 @@ package xnet
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"time"
@@ -10,16 +11,27 @@ import (
 const (
 	tcpNetwork     = "tcp"
 	udpNetwork     = "udp"
-	readBufferSize = 1024
+	unixNetwork    = "unix"
+	readBufferSize = 1024 // 默认读取buffer大小(Options.ReadBufferSize)
 
-	writeTimeout = 10 * time.Second // 写超时时间
-	readTimeout  = 60 * time.Second // 读超时时间
+	maxReadBufferSize = 64 * 1024 // 读缓存扩容后保留上限, 超过时处理完成后释放
 
-	writeChanLimit = 200 // 写channel大小
+	writeTimeout = 10 * time.Second // 默认写超时时间
+	readTimeout  = 60 * time.Second // 默认读超时时间
 
-	kcpSocketStart = 0 // kcp socket 开启
-	kcpSocketClose = 1 // kcp socket 关闭
+	tlsHandshakeTimeout = 10 * time.Second // 默认tls握手超时时间
 
+	writeChanLimit = 200 // 写队列默认消息数量上限
+
+	writeBatchBytes = 64 * 1024  // tcp单次写入默认字节上限(Options.WriteBatchBytes)
+	writeBatchMsgs  = 1024       // tcp单次写入默认消息数上限(Options.WriteBatchMsgs, IOV_MAX)
+	maxFrameSize    = 256 * 1024 // 帧缓存回收上限, 超过时交由gc
+
+	kcpSocketStart = 0 // kcp socket 开启(KCPSocket.closeFlag)
+	kcpSocketClose = 1 // kcp socket 关闭(KCPSocket.closeFlag)
+	kcpSocketDrain = 2 // kcp socket 停止读取(KCPSocket.drainFlag, 未停止为0), 取值与closeFlag取值区分
+
+	// kcp默认参数(DefaultKCPOptions)
 	kcpAckNoDelay = true // 非延迟ack
 	kcpNoDelay    = 1    // 1:RTO=30ms,0:RTO=100ms
 	kcpInterval   = 20   // 工作间隔(越小越块,cpu越高, 10, 20, 30, 40)
@@ -30,16 +42,37 @@ const (
 	kcpFecDataShards   = 10 // fec源数据包数量
 	kcpFecParityShards = 5  // fec生成数据包数量
 
-	maxMessageSize = 1024 * 2 // Websocket请求包大小上限
+	maxMessageSize = 1024 * 2 // 默认Websocket请求包大小上限
+
+	unixFileMode     = 0o660           // 默认unix socket文件权限
+	unixProbeTimeout = 1 * time.Second // 检测unix socket文件是否有服务监听
+
+	abortWaitTimeout = 5 * time.Second // 优雅关闭超时强制关闭后, 等待连接退出上限
 
 	udpCheckDuration  = 3 * time.Second // 检查时钟
 	udpSessionTimeout = 10              // udp超时(s)
 	udpMsgChanLimit   = 1024            // msg channel 带线啊哦
 )
 
-// 消息处理
+var (
+	ErrSockClosed   = errors.New("sock already close")
+	ErrMsgOverflow  = errors.New("msg overflow")
+	ErrSlowConsumer = errors.New("slow consumer disconnected") // PolicyDisconnect: 写队列满, 连接被断开
+)
+
+// 消息处理, 返回已处理字节数(0: 数据不足, 等待后续数据)
+// msg为读缓存切片(零拷贝), 仅在回调内有效, 回调返回后继续持有须Retain复制
 type OnHandlerOnce func(ctx context.Context, state interface{}, msg []byte) (int, error)
 
+// 复制消息(或其切片, 如xmsg.MsgArgs.Payload), 用于回调返回后继续持有
+func Retain(msg []byte) []byte {
+	return append([]byte(nil), msg...)
+}
+
+// 准入检查, 在OnConnect之前调用(accept/读协程内, 不可阻塞), 返回错误拒绝连接
+// 拒绝时: tcp/kcp关闭连接, websocket返回403, udp丢弃新地址的数据包
+type OnAccept func(ctx context.Context, remote net.Addr) error
+
 // 建立链接
 type OnConnect func(ctx context.Context, sock Socket) interface{}
 
@@ -52,8 +85,14 @@ type Socket interface {
 	LocalAddr() net.Addr
 }
 
+// 原始流式连接(*net.TCPConn/*net.UnixConn)
+type streamConn interface {
+	net.Conn
+	CloseRead() error
+}
+
 // udp 消息处理
-type udpOnMsg func(context.Context, []byte, *net.UDPAddr)
+type udpOnMsg func(context.Context, *UDPSocket, []byte, *net.UDPAddr) // sock: 收到数据包的socket
 
 // udp 发送消息
 type udpSendMsg func(ctx context.Context, datagram *udpDatagram) error
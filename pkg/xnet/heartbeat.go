@@ -0,0 +1,156 @@
+package xnet
+
+import (
+	"context"
+	"errors"
+	"gotu/pkg/xlog"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultMaxMissed = 3 // 默认连续未收到pong次数上限
+
+var ErrHeartbeatCodec = errors.New("heartbeat codec is required") // tcp开启ping未配置Codec
+
+// 心跳帧类型
+type HeartbeatKind int
+
+const (
+	HeartbeatNone HeartbeatKind = iota // 非心跳帧(交由OnMsg处理)
+	HeartbeatPing
+	HeartbeatPong
+)
+
+// tcp心跳帧编解码(xmsg.Codec.Heartbeat)
+type HeartbeatCodec interface {
+	Ping(sendAt int64) []byte // ping帧, 携带发送时间(unixnano)
+	Pong(sendAt int64) []byte // pong帧, 原样带回ping发送时间
+	// 识别心跳帧, 非心跳帧或数据不足返回HeartbeatNone
+	// 每帧在OnMsg前调用, 非心跳帧应只读取cmd判断, 不做完整解码
+	Parse(msg []byte) (kind HeartbeatKind, sendAt int64, n int, err error)
+}
+
+// 心跳配置(零值: 不发送ping, 不检测空闲, 读超时为Options.ReadTimeout)
+// tcp需配置Codec, 收到ping自动回复pong(对端须使用相同Codec); websocket使用ping/pong控制帧
+type HeartbeatOptions struct {
+	Interval    time.Duration  // ping间隔, 0: 不发送ping
+	MaxMissed   int            // 连续未收到pong次数上限, 超过断开连接(默认3)
+	IdleTimeout time.Duration  // 未收到业务消息(心跳不计入)断开连接, 0: 不检测
+	ReadTimeout time.Duration  // 未收到任何数据断开连接, 默认Interval*(MaxMissed+1), 未开启ping时为Options.ReadTimeout
+	Codec       HeartbeatCodec // tcp心跳帧编解码
+}
+
+func (opt HeartbeatOptions) withDefault(readTimeout time.Duration) HeartbeatOptions {
+	if opt.MaxMissed <= 0 {
+		opt.MaxMissed = defaultMaxMissed
+	}
+	if opt.ReadTimeout <= 0 {
+		opt.ReadTimeout = readTimeout
+		if opt.Interval > 0 {
+			opt.ReadTimeout = opt.Interval * time.Duration(opt.MaxMissed+1)
+		}
+	}
+	return opt
+}
+
+// tcp开启ping须配置Codec
+func (opt HeartbeatOptions) checkTCP() error {
+	if opt.Interval > 0 && opt.Codec == nil {
+		return ErrHeartbeatCodec
+	}
+	return nil
+}
+
+// 单连接心跳状态
+type heartbeat struct {
+	opt      HeartbeatOptions
+	sock     Socket
+	closeCh  chan struct{}
+	sendPing func(sendAt int64) error
+	abort    func(reason CloseReason)
+
+	missed   int32 // 连续未收到pong次数
+	activeAt int64 // 最近业务消息时间(unixnano)
+	rtt      int64 // 最近一次往返时间(ns)
+}
+
+func newHeartbeat(opt HeartbeatOptions, readTimeout time.Duration, sock Socket, closeCh chan struct{}) *heartbeat {
+	return &heartbeat{
+		opt:      opt.withDefault(readTimeout),
+		sock:     sock,
+		closeCh:  closeCh,
+		activeAt: time.Now().UnixNano(),
+	}
+}
+
+// 是否需要检测协程
+func (hb *heartbeat) enabled() bool {
+	return hb.opt.Interval > 0 || hb.opt.IdleTimeout > 0
+}
+
+// 读超时deadline
+func (hb *heartbeat) deadline() time.Time {
+	return time.Now().Add(hb.opt.ReadTimeout)
+}
+
+// 收到业务消息
+func (hb *heartbeat) active() {
+	if hb.opt.IdleTimeout > 0 {
+		atomic.StoreInt64(&hb.activeAt, time.Now().UnixNano())
+	}
+}
+
+// 收到pong
+func (hb *heartbeat) pong(sendAt int64) {
+	atomic.StoreInt32(&hb.missed, 0)
+	if rtt := time.Now().UnixNano() - sendAt; sendAt > 0 && rtt >= 0 {
+		atomic.StoreInt64(&hb.rtt, rtt)
+	}
+}
+
+func (hb *heartbeat) RTT() time.Duration {
+	return time.Duration(atomic.LoadInt64(&hb.rtt))
+}
+
+// 定时发送ping, 检测pong丢失及空闲超时, closeCh关闭后退出
+func (hb *heartbeat) loop(ctx context.Context) {
+	var pingC, idleC <-chan time.Time
+	if hb.opt.Interval > 0 {
+		ticker := time.NewTicker(hb.opt.Interval)
+		defer ticker.Stop()
+		pingC = ticker.C
+	}
+	var idleTimer *time.Timer
+	if hb.opt.IdleTimeout > 0 {
+		idleTimer = time.NewTimer(hb.opt.IdleTimeout)
+		defer idleTimer.Stop()
+		idleC = idleTimer.C
+	}
+
+	for {
+		select {
+		case <-hb.closeCh:
+			return
+		case now := <-pingC:
+			if missed := atomic.LoadInt32(&hb.missed); int(missed) >= hb.opt.MaxMissed {
+				xlog.Get(ctx).Info("Heartbeat timeout.", zap.Any("remote", hb.sock.RemoteAddr()), zap.Int32("missed", missed))
+				hb.abort(CloseReasonHeartbeat)
+				return
+			}
+			atomic.AddInt32(&hb.missed, 1)
+			if err := hb.sendPing(now.UnixNano()); err != nil {
+				xlog.Get(ctx).Debug("Send ping failed.", zap.Any("err", err))
+			}
+		case now := <-idleC:
+			remain := hb.opt.IdleTimeout - now.Sub(time.Unix(0, atomic.LoadInt64(&hb.activeAt)))
+			if remain <= 0 {
+				xlog.Get(ctx).Info("Idle timeout.", zap.Any("remote", hb.sock.RemoteAddr()))
+				hb.abort(CloseReasonIdle)
+				return
+			}
+			idleTimer.Reset(remain)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package xnet_test
+
+import (
+	"context"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type rttSocket interface {
+	RTT() time.Duration
+}
+
+func TestTCPHeartbeat(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9983"
+	var svrMsgs int32
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{
+		Addr:         addr,
+		Heartbeat:    xnet.HeartbeatOptions{Codec: xmsg.Heartbeat()},
+		OnConnect:    onConnectSock,
+		OnDisconnect: onDisconnectLog,
+		OnMsg: xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+			atomic.AddInt32(&svrMsgs, 1)
+			return nil
+		}),
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	// 开启ping未配置Codec
+	if _, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{Addr: addr, Heartbeat: xnet.HeartbeatOptions{Interval: time.Second}}); err != xnet.ErrHeartbeatCodec {
+		t.Fatalf("codec err %v", err)
+	}
+
+	cliMetrics := xnet.NewMemMetrics()
+	cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{
+		Addr:         addr,
+		Metrics:      cliMetrics,
+		Heartbeat:    xnet.HeartbeatOptions{Interval: 50 * time.Millisecond, MaxMissed: 2, Codec: xmsg.Heartbeat()},
+		OnConnect:    onConnectSock,
+		OnDisconnect: onDisconnectLog,
+		OnMsg:        echoHandler(),
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	// 心跳帧不交由OnMsg处理, 空闲连接保持
+	waitUntil(t, "rtt", func() bool { return cli.RTT() > 0 })
+	time.Sleep(300 * time.Millisecond)
+	if n := atomic.LoadInt32(&svrMsgs); n != 0 {
+		t.Fatalf("server handled heartbeat %v", n)
+	}
+	if snap := cliMetrics.Snapshot(); snap.Closed != 0 || snap.MsgsIn != 0 {
+		t.Fatalf("client snapshot %+v", snap)
+	}
+}
+
+func TestTCPHeartbeatMissed(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9984"
+	// 只读不回复pong
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 1024)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	cliMetrics := xnet.NewMemMetrics()
+	cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{
+		Addr:         addr,
+		Metrics:      cliMetrics,
+		Heartbeat:    xnet.HeartbeatOptions{Interval: 50 * time.Millisecond, MaxMissed: 2, ReadTimeout: time.Minute, Codec: xmsg.Heartbeat()},
+		OnConnect:    onConnectSock,
+		OnDisconnect: onDisconnectLog,
+		OnMsg:        echoHandler(),
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	waitUntil(t, "heartbeat close", func() bool { return cliMetrics.Snapshot().Closed == 1 })
+	if snap := cliMetrics.Snapshot(); snap.CloseReasons[xnet.CloseReasonHeartbeat] != 1 {
+		t.Fatalf("client snapshot %+v", snap)
+	}
+}
+
+func TestTCPIdle(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9985"
+	svrMetrics := xnet.NewMemMetrics()
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{
+		Addr:         addr,
+		Metrics:      svrMetrics,
+		Heartbeat:    xnet.HeartbeatOptions{IdleTimeout: 300 * time.Millisecond, Codec: xmsg.Heartbeat()},
+		OnConnect:    onConnectSock,
+		OnDisconnect: onDisconnectLog,
+		OnMsg:        echoHandler(),
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	var wg sync.WaitGroup
+	cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{
+		Addr:         addr,
+		Heartbeat:    xnet.HeartbeatOptions{Interval: 50 * time.Millisecond, Codec: xmsg.Heartbeat()},
+		OnConnect:    onConnectSock,
+		OnDisconnect: onDisconnectLog,
+		OnMsg:        doneHandler(&wg),
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	// 业务消息刷新空闲时间
+	for i := 0; i < 4; i++ {
+		sendEcho(ctx, func(msg []byte) error { return cli.SendMsg(ctx, msg) }, &wg, 1)
+		time.Sleep(100 * time.Millisecond)
+	}
+	if snap := svrMetrics.Snapshot(); snap.Closed != 0 {
+		t.Fatalf("server snapshot %+v", snap)
+	}
+
+	// 仅心跳视为空闲
+	waitUntil(t, "idle close", func() bool { return svrMetrics.Snapshot().Closed == 1 })
+	if snap := svrMetrics.Snapshot(); snap.CloseReasons[xnet.CloseReasonIdle] != 1 {
+		t.Fatalf("server snapshot %+v", snap)
+	}
+}
+
+func TestWebsocketHeartbeat(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9986"
+	svrMetrics := xnet.NewMemMetrics()
+	sockCh := make(chan xnet.Socket, 1)
+	svr := xnet.NewWSServer(ctx, xnet.WSSvrArgs{
+		Addr:      addr,
+		Path:      "/",
+		Metrics:   svrMetrics,
+		Heartbeat: xnet.HeartbeatOptions{Interval: 50 * time.Millisecond, IdleTimeout: 500 * time.Millisecond},
+		OnConnect: func(ctx context.Context, sock xnet.Socket) interface{} {
+			sockCh <- sock
+			return sock
+		},
+		OnDisconnect: onDisconnectLog,
+		OnMsg:        echoHandler(),
+	})
+	defer svr.Close(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	// 客户端读超时小于空闲时间, 依赖服务端ping延长
+	cli, err := xnet.NewWSClient(ctx, xnet.WSCliArgs{
+		Addr:         addr,
+		Path:         "/",
+		Heartbeat:    xnet.HeartbeatOptions{ReadTimeout: 200 * time.Millisecond},
+		OnConnect:    onConnectSock,
+		OnDisconnect: onDisconnectLog,
+		OnMsg:        echoHandler(),
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	sock := (<-sockCh).(rttSocket)
+	waitUntil(t, "rtt", func() bool { return sock.RTT() > 0 })
+	waitUntil(t, "idle close", func() bool { return svrMetrics.Snapshot().Closed == 1 })
+	if snap := svrMetrics.Snapshot(); snap.CloseReasons[xnet.CloseReasonIdle] != 1 {
+		t.Fatalf("server snapshot %+v", snap)
+	}
+}
@@ -0,0 +1,146 @@
+package xnet_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"gotu/pkg/xlog"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 连接状态为socket本身(echoHandler回包使用)
+func onConnectSock(ctx context.Context, sock xnet.Socket) interface{} { return sock }
+
+func onDisconnectLog(ctx context.Context, state interface{}) {
+	xlog.Get(ctx).Debug("Disconnect")
+}
+
+// 原样回包
+func echoHandler() xnet.OnHandlerOnce {
+	return xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+		msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: arg.Payload})
+		if err != nil {
+			return err
+		}
+		return arg.State.(xnet.Socket).SendMsg(ctx, msg)
+	})
+}
+
+// 每收到一条消息wg.Done
+func doneHandler(wg *sync.WaitGroup) xnet.OnHandlerOnce {
+	return xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+		wg.Done()
+		return nil
+	})
+}
+
+// 回包payload写入recvCh
+func recvPayload(recvCh chan []byte) xnet.OnHandlerOnce {
+	return xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+		recvCh <- append([]byte(nil), arg.Payload...)
+		return nil
+	})
+}
+
+// 等待recvCh收到payload
+func expectPayload(t *testing.T, name string, recvCh chan []byte, payload []byte) {
+	select {
+	case recv := <-recvCh:
+		if !bytes.Equal(recv, payload) {
+			t.Fatalf("%v recv %v bytes", name, len(recv))
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("%v recv timeout", name)
+	}
+}
+
+// 发送count条消息并等待回包, 返回发送字节数
+func sendEcho(ctx context.Context, send func(msg []byte) error, wg *sync.WaitGroup, count int) uint64 {
+	var total uint64
+	for i := 0; i < count; i++ {
+		msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: []byte(fmt.Sprintf("metrics data %v", i))})
+		if err != nil {
+			panic(err)
+		}
+		total += uint64(len(msg))
+		wg.Add(1)
+		if err := send(msg); err != nil {
+			panic(err)
+		}
+	}
+	wg.Wait()
+	return total
+}
+
+// 发送n条"data"消息
+func sendPayloads(ctx context.Context, sock interface {
+	SendMsg(ctx context.Context, msg []byte) error
+}, n int) {
+	for i := 0; i < n; i++ {
+		msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: []byte("data")})
+		if err != nil {
+			panic(err)
+		}
+		if err := sock.SendMsg(ctx, msg); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// 轮询等待条件成立
+func waitUntil(t *testing.T, desc string, cond func() bool) {
+	for i := 0; i < 300; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("wait %v timeout", desc)
+}
+
+// udp并发流: 各自独立socket即不同源端口(SO_REUSEPORT按四元组分散至各socket)
+const (
+	benchUDPFlows   = 16                     // 并发流数量
+	benchUDPWindow  = 8                      // 单条流每轮发送消息数, 等待全部回包后进入下一轮
+	benchUDPTimeout = 100 * time.Millisecond // 等待回包超时(计为丢包)
+)
+
+// benchUDPFlows条流并发收发共b.N条消息(服务端回包), 上报丢包率(loss)
+// roundTrip发送n条消息并返回超时前收到的回包数
+func runFlows(b *testing.B, dial func() (roundTrip func(n int) int, closeFn func())) {
+	trips := make([]func(n int) int, benchUDPFlows)
+	for i := range trips {
+		roundTrip, closeFn := dial()
+		defer closeFn()
+		trips[i] = roundTrip
+	}
+
+	var next, lost int64
+	var wg sync.WaitGroup
+	b.ResetTimer()
+	for _, roundTrip := range trips {
+		wg.Add(1)
+		go func(roundTrip func(n int) int) {
+			defer wg.Done()
+			for {
+				end := atomic.AddInt64(&next, benchUDPWindow)
+				n := int64(b.N) - (end - benchUDPWindow)
+				if n <= 0 {
+					return
+				}
+				if n > benchUDPWindow {
+					n = benchUDPWindow
+				}
+				atomic.AddInt64(&lost, n-int64(roundTrip(int(n))))
+			}
+		}(roundTrip)
+	}
+	wg.Wait()
+	b.StopTimer()
+	b.ReportMetric(float64(atomic.LoadInt64(&lost))/float64(b.N), "loss")
+}
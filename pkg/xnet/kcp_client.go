@@ -7,7 +7,7 @@ import (
 )
 
 type KCPClient struct {
-	sock   *KCPSocket
+	rc     *reconnector[*KCPSocket]
 	bufMgr *bufferManager
 
 	arg *KCPClientArgs
@@ -18,44 +18,60 @@ type KCPClientArgs struct {
 	OnMsg        OnHandlerOnce
 	OnConnect    OnConnect
 	OnDisconnect OnDisconnect
-	IsInline     bool // 是否开启内置协议(握手，挥手)
+	IsInline     bool              // 是否开启内置协议(握手，挥手)
+	WriteQueue   WriteQueueOptions // 写队列策略(队列满时的处理)
+	Metrics      Metrics           // 指标上报(nil:不上报)
+	Reconnect    ReconnectOptions  // 自动重连(断线检测依赖内置协议或读超时)
+	Options      Options           // 连接参数(buffer/超时/kcp调优)
 }
 
 func NewKCPClient(ctx context.Context, arg KCPClientArgs) (*KCPClient, error) {
-	bufMgr := newBufferManager()
+	arg.Options = arg.Options.withDefault()
+	bufMgr := newBufferManager(arg.Options.ReadBufferSize)
 	cli := &KCPClient{bufMgr: bufMgr, arg: &arg}
-	if err := cli.newSocket(ctx); err != nil {
+	cli.rc = newReconnector(arg.Reconnect, cli.newSocket)
+	if err := cli.rc.start(ctx); err != nil {
 		return nil, err
 	}
 	return cli, nil
 }
 
-func (cli *KCPClient) newSocket(ctx context.Context) error {
-	conn, err := kcp.DialWithOptions(cli.arg.Addr, nil, kcpFecDataShards, kcpFecParityShards)
+func (cli *KCPClient) newSocket(ctx context.Context, onLost func(ctx context.Context)) (*KCPSocket, error) {
+	kcpOpt := cli.arg.Options.KCP
+	conn, err := kcp.DialWithOptions(cli.arg.Addr, nil, kcpOpt.DataShards, kcpOpt.ParityShards)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	cli.arg.Options.applyBuffer(ctx, conn)
 	sock, err := newKCPSocket(ctx, kcpSocketArgs{
-		conn:         conn,
-		mux:          newKCPMux(cli.arg.OnMsg, cli.arg.IsInline, false),
-		onConnect:    cli.arg.OnConnect,
-		onDisconnect: cli.arg.OnDisconnect,
-		releaseFn:    func(ctx context.Context, sock *KCPSocket) {},
-		readBufPool:  cli.bufMgr.newBufferPool(),
+		conn:      conn,
+		mux:       newKCPMux(cli.arg.OnMsg, cli.arg.IsInline, false),
+		onConnect: cli.arg.OnConnect,
+		onDisconnect: func(ctx context.Context, state interface{}) {
+			cli.arg.OnDisconnect(ctx, state)
+			onLost(ctx)
+		},
+		releaseFn:  func(ctx context.Context, sock *KCPSocket) {},
+		readBuffer: cli.bufMgr.newReadBuffer(),
+		writeQueue: cli.arg.WriteQueue,
+		metrics:    cli.arg.Metrics,
+		options:    cli.arg.Options,
 	})
-	cli.sock = sock
-	return err
+	if err != nil {
+		return nil, err
+	}
+	return sock, nil
 }
 
 func (cli *KCPClient) Reconnect(ctx context.Context) error {
-	cli.sock.Close(ctx)
-	return cli.newSocket(ctx)
+	return cli.rc.reconnect(ctx)
 }
 
 func (cli *KCPClient) Close(ctx context.Context) {
-	cli.sock.Close(ctx)
+	cli.rc.close(ctx)
 }
 
+// 自动重连开启时, 断线期间按配置缓存
 func (cli *KCPClient) SendMsg(ctx context.Context, msg []byte) error {
-	return cli.sock.SendMsg(ctx, msg)
+	return cli.rc.send(ctx, msg)
 }
@@ -2,7 +2,9 @@ package xnet
 
 import (
 	"context"
+	"net"
 	"sync"
+	"time"
 
 	"gotu/pkg/xlog"
 
@@ -15,40 +17,62 @@ type KCPServerArgs struct {
 	OnMsg        OnHandlerOnce
 	OnConnect    OnConnect
 	OnDisconnect OnDisconnect
-	IsInline     bool // 是否开启内置协议(握手，挥手)
+	OnAccept     OnAccept          // 准入检查(AccessList.OnAccept), nil: 不检查
+	IsInline     bool              // 是否开启内置协议(握手，挥手)
+	WriteQueue   WriteQueueOptions // 写队列策略(队列满时的处理)
+	Metrics      Metrics           // 指标上报(nil:不上报)
+	Options      Options           // 连接参数(buffer/超时/kcp调优)
+	Limit        LimitOptions      // 连接数及速率限制
 }
 
 type KCPServer struct {
 	wg       sync.WaitGroup
 	listener *kcp.Listener // 监听器
 	closeCh  chan struct{}
+	stopOnce sync.Once
 
 	bufMgr       *bufferManager
 	onMsg        OnHandlerOnce
 	onConnect    OnConnect
 	onDisconnect OnDisconnect
+	onAccept     OnAccept
 	isInline     bool
-
-	mu      sync.Mutex
-	sockets map[*KCPSocket]bool
+	writeQueue   WriteQueueOptions
+	queueCounter *writeQueueCounter
+	metrics      Metrics
+	options      Options
+	limiter      *connLimiter
+
+	mu       sync.Mutex
+	sockets  map[*KCPSocket]bool
+	closed   bool           // 关闭后拒绝新连接
+	acceptWg sync.WaitGroup // 建立中的连接(握手)
 }
 
 func NewKCPServer(ctx context.Context, arg KCPServerArgs) (*KCPServer, error) {
-	listener, err := kcp.ListenWithOptions(arg.Addr, nil, kcpFecDataShards, kcpFecParityShards)
+	options := arg.Options.withDefault()
+	listener, err := kcp.ListenWithOptions(arg.Addr, nil, options.KCP.DataShards, options.KCP.ParityShards)
 	if err != nil {
 		return nil, err
 	}
+	options.applyBuffer(ctx, listener)
 	xlog.Get(ctx).Info("KCP server start listen success.", zap.String("addr", arg.Addr))
 
 	svr := &KCPServer{
 		listener:     listener,
 		closeCh:      make(chan struct{}),
 		sockets:      map[*KCPSocket]bool{},
-		bufMgr:       newBufferManager(),
+		bufMgr:       newBufferManager(options.ReadBufferSize),
 		onMsg:        arg.OnMsg,
 		onConnect:    arg.OnConnect,
 		onDisconnect: arg.OnDisconnect,
+		onAccept:     arg.OnAccept,
 		isInline:     arg.IsInline,
+		writeQueue:   arg.WriteQueue,
+		queueCounter: &writeQueueCounter{},
+		metrics:      arg.Metrics,
+		options:      options,
+		limiter:      newConnLimiter(arg.Limit),
 	}
 
 	svr.wg.Add(1)
@@ -64,6 +88,9 @@ func (svr *KCPServer) accept(ctx context.Context) {
 		// 监听关闭检测
 		select {
 		case <-svr.closeCh:
+			if conn != nil {
+				conn.Close()
+			}
 			xlog.Get(ctx).Debug("KCP listener close.")
 			return
 		default:
@@ -73,35 +100,110 @@ func (svr *KCPServer) accept(ctx context.Context) {
 			xlog.Get(ctx).Warn("Accept kcp failed.", zap.Any("err", err))
 			continue
 		}
+		if !svr.beginAccept() {
+			conn.Close()
+			return
+		}
+		if !admit(ctx, svr.onAccept, conn.RemoteAddr()) || !svr.limiter.acquire(ctx, conn.RemoteAddr()) {
+			conn.Close()
+			svr.acceptWg.Done()
+			continue
+		}
 		ks, err := newKCPSocket(ctx, kcpSocketArgs{
 			conn:         conn,
-			readBufPool:  svr.bufMgr.newBufferPool(),
+			readBuffer:   svr.bufMgr.newReadBuffer(),
 			mux:          newKCPMux(svr.onMsg, svr.isInline, true),
 			onConnect:    svr.onConnect,
 			onDisconnect: svr.onDisconnect,
 			releaseFn:    svr.deleteSocket,
+			writeQueue:   svr.writeQueue,
+			queueCounter: svr.queueCounter,
+			metrics:      svr.metrics,
+			options:      svr.options,
+			rate:         svr.limiter.newRate(conn.RemoteAddr()),
 		})
 		if err != nil {
 			xlog.Get(ctx).Warn("New kcp socket failed.", zap.Any("err", err))
-			continue
+		} else {
+			svr.addSocket(ctx, ks)
 		}
-		svr.addSocket(ctx, ks)
+		svr.acceptWg.Done()
 	}
 }
 
-func (svr *KCPServer) Close(ctx context.Context) {
+// 开始建立连接(关闭后返回false)
+func (svr *KCPServer) beginAccept() bool {
 	svr.mu.Lock()
 	defer svr.mu.Unlock()
-	for xs := range svr.sockets {
-		xs.Close(ctx)
+	if svr.closed {
+		return false
 	}
+	svr.acceptWg.Add(1)
+	return true
+}
+
+// 停止接收新连接
+// 监听器与已建立的连接共用udp conn, 仅停止accept, 连接关闭后再关闭监听器
+func (svr *KCPServer) stopAccept() {
+	svr.stopOnce.Do(func() {
+		close(svr.closeCh)
+		_ = svr.listener.SetReadDeadline(time.Now())
+
+		svr.mu.Lock()
+		svr.closed = true
+		svr.mu.Unlock()
+	})
+	// 等待建立中的连接加入sockets
+	svr.acceptWg.Wait()
+}
 
-	close(svr.closeCh)
+// 关闭监听器
+func (svr *KCPServer) closeListener() {
 	svr.listener.Close()
 	svr.wg.Wait()
+}
+
+func (svr *KCPServer) Close(ctx context.Context) {
+	svr.stopAccept()
+
+	svr.mu.Lock()
+	for xs := range svr.sockets {
+		xs.Close(ctx)
+	}
+	svr.mu.Unlock()
+
+	svr.closeListener()
 	xlog.Get(ctx).Debug("KCP server close success.")
 }
 
+// 优雅关闭: 停止accept, 等待处理中的消息完成并刷新写缓存后关闭连接
+// ctx超时后强制关闭剩余连接, 返回被强制关闭的连接地址
+func (svr *KCPServer) Shutdown(ctx context.Context) ([]net.Addr, error) {
+	svr.stopAccept()
+
+	svr.mu.Lock()
+	socks := make([]drainSocket, 0, len(svr.sockets))
+	for xs := range svr.sockets {
+		socks = append(socks, xs)
+	}
+	svr.mu.Unlock()
+
+	forced, err := drainSockets(ctx, socks)
+	svr.closeListener()
+	xlog.Get(ctx).Info("KCP server shutdown.", zap.Int("sockets", len(socks)), zap.Int("forced", len(forced)))
+	return forced, err
+}
+
+// 写队列计数(所有连接汇总)
+func (svr *KCPServer) WriteQueueStats() WriteQueueStats {
+	return svr.queueCounter.snapshot()
+}
+
+// 连接数及拒绝计数
+func (svr *KCPServer) LimitStats() LimitStats {
+	return svr.limiter.stats()
+}
+
 func (svr *KCPServer) addSocket(ctx context.Context, sock *KCPSocket) {
 	svr.mu.Lock()
 	defer svr.mu.Unlock()
@@ -116,6 +218,7 @@ func (svr *KCPServer) deleteSocket(ctx context.Context, sock *KCPSocket) {
 	svr.mu.Lock()
 	defer svr.mu.Unlock()
 	delete(svr.sockets, sock)
+	svr.limiter.release(sock.RemoteAddr())
 
 	xlog.Get(ctx).Debug("Del sockets", zap.Any("count", len(svr.sockets)))
 }
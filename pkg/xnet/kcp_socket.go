@@ -2,11 +2,11 @@ package xnet
 
 import (
 	"context"
-	"fmt"
 	"gotu/pkg/xcommon"
 	"gotu/pkg/xlog"
 	"io"
 	"net"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -21,44 +21,59 @@ type kcpSocketArgs struct {
 	onDisconnect OnDisconnect
 	mux          *kcpMux
 	releaseFn    func(ctx context.Context, sock *KCPSocket)
+	writeQueue   WriteQueueOptions
+	queueCounter *writeQueueCounter
+	metrics      Metrics
+	options      Options      // 已填充默认值
+	rate         *rateLimiter // 速率限制(nil:不限制)
 
-	readBufPool *bufferPool
+	readBuffer *readBuffer
 }
 
 type KCPSocket struct {
 	conn         *kcp.UDPSession
-	readBufPool  *bufferPool
+	readBuffer   *readBuffer // 读缓存(零拷贝交由mux)
 	onConnect    OnConnect
 	onDisconnect OnDisconnect
 	releaseFn    func(ctx context.Context, sock *KCPSocket)
-	readCaches   []byte
-	writeCh      chan []byte // 写消息缓存
+	writeQueue   *writeQueue[[]byte] // 写消息缓存
 	mux          *kcpMux
+	reporter     *socketReporter
+	readTimeout  time.Duration
+	rate         *rateLimiter
+	writeTimeout time.Duration
 
 	wg xcommon.WaitGroup
 
 	closeFlag int32         // 关闭标识
 	closeCh   chan struct{} // 关闭Channel
+	drainFlag int32         // 优雅关闭标识(停止读取)
+	drainOnce sync.Once
 }
 
 func newKCPSocket(ctx context.Context, arg kcpSocketArgs) (*KCPSocket, error) {
 	arg.conn.SetStreamMode(true)
 	arg.conn.SetWriteDelay(false)
-	arg.conn.SetACKNoDelay(kcpAckNoDelay)
-	arg.conn.SetNoDelay(kcpNoDelay, kcpInterval, kcpResend, kcpNC)
+	kcpOpt := arg.options.KCP
+	arg.conn.SetACKNoDelay(kcpOpt.AckNoDelay)
+	arg.conn.SetNoDelay(kcpOpt.NoDelay, kcpOpt.Interval, kcpOpt.Resend, kcpOpt.NC)
 
 	sock := &KCPSocket{
 		conn:         arg.conn,
-		readBufPool:  arg.readBufPool,
+		readBuffer:   arg.readBuffer,
 		onConnect:    arg.onConnect,
 		onDisconnect: arg.onDisconnect,
 		releaseFn:    arg.releaseFn,
-		readCaches:   make([]byte, 0),
-		writeCh:      make(chan []byte, writeChanLimit),
 		mux:          arg.mux,
 		closeCh:      make(chan struct{}),
 		closeFlag:    kcpSocketStart,
+		readTimeout:  arg.options.ReadTimeout,
+		rate:         arg.rate,
+		writeTimeout: arg.options.WriteTimeout,
 	}
+	sock.writeQueue = newWriteQueue(arg.writeQueue, arg.queueCounter, writeChanLimit, msgSize, sock.closeCh)
+	sock.reporter = newSocketReporter(arg.metrics, sock, 2)
+	sock.writeQueue.depthFn = sock.reporter.queueDepth
 
 	sock.wg.Add(2)
 	go sock.readLoop(ctx)
@@ -79,23 +94,38 @@ func (sock *KCPSocket) readLoop(ctx context.Context) {
 		if readErr != nil {
 			xlog.Get(ctx).Warn("Read loop exit with error.", zap.Any("err", readErr))
 		}
+		sock.reporter.setReadErr(readErr)
 		sock.onDisconnect(ctx, state)
 		sock.releaseFn(ctx, sock)
 		sock.closeOnce()
+		sock.readBuffer.release()
+		sock.reporter.loopDone()
 	}()
 
 	defer sock.wg.Done(ctx)
 
 	for {
 		// timeout
-		if err := sock.conn.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
+		if err := sock.conn.SetReadDeadline(time.Now().Add(sock.readTimeout)); err != nil {
 			readErr = err
 			break
 		}
+		// 优雅关闭(须在设置deadline之后检测), 处理中的消息已完成
+		isDrain := atomic.LoadInt32(&sock.drainFlag) == kcpSocketDrain
+		if isDrain {
+			if !sock.mux.isInline {
+				break
+			}
+			// 内置协议: 发起挥手, 挥手完成后读循环退出
+			sock.drainOnce.Do(func() { go sock.Close(ctx) })
+		}
 
-		// 分配buf, 读取数据
-		bytes := sock.readBufPool.get()
-		n, err := sock.conn.Read(bytes)
+		// 直接读入读缓存, 未处理数据之后
+		n, err := sock.conn.Read(sock.readBuffer.space())
+		// drain设置的读超时仅用于唤醒读循环
+		if e, ok := err.(net.Error); ok && e.Timeout() && !isDrain && atomic.LoadInt32(&sock.drainFlag) == kcpSocketDrain {
+			continue
+		}
 		if atomic.LoadInt32(&sock.closeFlag) == kcpSocketClose || err != nil {
 			if err != nil && errors.Cause(err) != io.ErrClosedPipe && !errors.Is(err, net.ErrClosed) {
 				readErr = err
@@ -103,15 +133,22 @@ func (sock *KCPSocket) readLoop(ctx context.Context) {
 			break
 		}
 
-		sock.readBufPool.put(bytes[n:])
-		sock.readCaches = append(sock.readCaches, bytes[0:n]...)
+		sock.readBuffer.written(n)
+
+		sock.reporter.bytesIn(n)
+		if !sock.rate.allowBytes(ctx, n) {
+			sock.reporter.setCloseReason(CloseReasonRateLimit)
+			return
+		}
 
 		isKeepCache := false
 		for !isKeepCache {
-			reqCount, err := sock.mux.onMsg(ctx, sock, state, sock.readCaches)
+			begin := time.Now()
+			reqCount, err := sock.mux.onMsg(ctx, sock, state, sock.readBuffer.data())
 			if err != nil {
 				if err != io.EOF {
 					readErr = err
+					sock.reporter.setCloseReason(CloseReasonHandlerError)
 				}
 				return
 			}
@@ -119,8 +156,12 @@ func (sock *KCPSocket) readLoop(ctx context.Context) {
 				// 缓存数据无法处理(长度不够)
 				isKeepCache = true
 			} else {
-				sock.readBufPool.put(sock.readCaches[0:reqCount])
-				sock.readCaches = sock.readCaches[reqCount:]
+				sock.reporter.msgHandled(begin)
+				sock.readBuffer.consume(reqCount)
+				if !sock.rate.allowMsg(ctx, 1) {
+					sock.reporter.setCloseReason(CloseReasonRateLimit)
+					return
+				}
 			}
 		}
 	}
@@ -128,7 +169,7 @@ func (sock *KCPSocket) readLoop(ctx context.Context) {
 
 func (sock *KCPSocket) write(msg []byte) error {
 	for {
-		if err := sock.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		if err := sock.conn.SetWriteDeadline(time.Now().Add(sock.writeTimeout)); err != nil {
 			return err
 		}
 		n, err := sock.conn.Write(msg)
@@ -148,44 +189,45 @@ func (sock *KCPSocket) writeLoop(ctx context.Context) {
 	defer func() {
 		if writeErr != nil {
 			xlog.Get(ctx).Warn("Write loop exit with error", zap.Any("err", writeErr))
+			sock.reporter.setCloseReason(CloseReasonWriteError)
 		}
 		// 写入全部数据后关闭 udpsession
 		_ = sock.conn.Close()
+		sock.reporter.loopDone()
 	}()
 
 	defer sock.wg.Done(ctx)
 
-	waitMsg := func() ([]byte, bool) {
+	waitMsg := func() ([]byte, int, bool) {
 		// 阻塞等待数据
 		var msg []byte
 		ret := false
 		select {
-		case data := <-sock.writeCh:
-			msg = append(msg, data...)
+		case <-sock.writeQueue.wait():
 		case <-sock.closeCh:
 			ret = true
 		}
-		// 非阻塞获取数据
-	loop:
-		for {
-			select {
-			case data := <-sock.writeCh:
-				msg = append(msg, data...)
-			default:
-				break loop
-			}
+		// 非阻塞获取全部数据
+		datas := sock.writeQueue.popAll()
+		for _, data := range datas {
+			msg = append(msg, data...)
 		}
-		return msg, ret
+		return msg, len(datas), ret
 	}
 
 	isClosed := false
 	for !isClosed {
 		var msg []byte
-		msg, isClosed = waitMsg()
+		var count int
+		msg, count, isClosed = waitMsg()
+		if len(msg) == 0 {
+			continue
+		}
 		if err := sock.write(msg); err != nil {
 			writeErr = err
 			break
 		}
+		sock.reporter.bytesOut(len(msg), count)
 	}
 }
 
@@ -200,17 +242,21 @@ func (sock *KCPSocket) send(ctx context.Context, inline bool, payload []byte) er
 	if err != nil {
 		return err
 	}
-	select {
-	case sock.writeCh <- msg:
-		return nil
-	case <-sock.closeCh:
-		return fmt.Errorf("sock already close")
-	default:
-		return fmt.Errorf("msg overflow")
+	// 内置协议消息不受写队列上限限制
+	if inline {
+		return sock.writeQueue.forcePush(msg)
+	}
+	err = sock.writeQueue.push(ctx, msg)
+	if errors.Is(err, ErrSlowConsumer) {
+		xlog.Get(ctx).Warn("Disconnect slow consumer.", zap.Any("remote", sock.RemoteAddr()))
+		sock.reporter.setCloseReason(CloseReasonSlowConsumer)
+		sock.abort(ctx)
 	}
+	return err
 }
 
 func (sock *KCPSocket) Close(ctx context.Context) {
+	sock.reporter.setCloseReason(CloseReasonLocal)
 	sock.mux.close(ctx, sock)
 	sock.closeForce(ctx)
 }
@@ -229,6 +275,22 @@ func (sock *KCPSocket) closeOnce() {
 	}
 }
 
+// 停止读取, 处理中的消息完成后刷新写缓存并关闭
+func (sock *KCPSocket) drain(ctx context.Context) {
+	sock.reporter.setCloseReason(CloseReasonShutdown)
+	atomic.StoreInt32(&sock.drainFlag, kcpSocketDrain)
+	_ = sock.conn.SetReadDeadline(time.Now())
+}
+
+// 强制关闭, 丢弃未发送数据
+func (sock *KCPSocket) abort(ctx context.Context) {
+	_ = sock.conn.Close()
+}
+
+func (sock *KCPSocket) wait() {
+	sock.wg.Wait()
+}
+
 func (sock *KCPSocket) RemoteAddr() net.Addr {
 	return sock.conn.RemoteAddr()
 }
@@ -0,0 +1,220 @@
+package xnet
+
+import (
+	"context"
+	"gotu/pkg/xlog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 拒绝原因
+type RejectReason string
+
+const (
+	RejectMaxConns      RejectReason = "max_conns"        // 超过总连接数
+	RejectMaxConnsPerIP RejectReason = "max_conns_per_ip" // 超过单ip连接数
+	RejectMsgRate       RejectReason = "msg_rate"         // 超过单连接消息速率
+	RejectByteRate      RejectReason = "byte_rate"        // 超过单连接字节速率
+)
+
+// 服务端连接数及速率限制(零值: 不限制)
+// 超过连接数: tcp/kcp关闭新连接, websocket返回503, udp丢弃新地址的数据包
+// 超过速率: tcp/kcp/websocket断开连接(CloseReasonRateLimit), udp丢弃数据包
+type LimitOptions struct {
+	MaxConns      int     // 总连接数上限
+	MaxConnsPerIP int     // 单ip连接数上限
+	MsgRate       float64 // 单连接每秒消息数
+	MsgBurst      int     // 消息突发上限(默认max(MsgRate, 1))
+	ByteRate      float64 // 单连接每秒字节数
+	ByteBurst     int     // 字节突发上限(默认max(ByteRate, 1))
+
+	OnReject func(ctx context.Context, addr net.Addr, reason RejectReason) // 拒绝回调(accept/读协程内, 不可阻塞)
+}
+
+// 限制计数
+type LimitStats struct {
+	Conns           int    // 当前连接数
+	RejectMaxConns  uint64 // 超过总连接数拒绝次数
+	RejectPerIP     uint64 // 超过单ip连接数拒绝次数
+	MsgRateLimited  uint64 // 超过消息速率次数
+	ByteRateLimited uint64 // 超过字节速率次数
+}
+
+// 服务端连接限制(线程安全)
+type connLimiter struct {
+	opt LimitOptions
+
+	mu    sync.Mutex
+	conns int
+	perIP map[string]int
+
+	rejectMaxConns  uint64
+	rejectPerIP     uint64
+	msgRateLimited  uint64
+	byteRateLimited uint64
+}
+
+func newConnLimiter(opt LimitOptions) *connLimiter {
+	if opt.MsgRate > 0 && opt.MsgBurst <= 0 {
+		opt.MsgBurst = burstOf(opt.MsgRate)
+	}
+	if opt.ByteRate > 0 && opt.ByteBurst <= 0 {
+		opt.ByteBurst = burstOf(opt.ByteRate)
+	}
+	return &connLimiter{opt: opt, perIP: make(map[string]int)}
+}
+
+func burstOf(rate float64) int {
+	if rate < 1 {
+		return 1
+	}
+	return int(rate)
+}
+
+// 新连接占用名额, 超过上限返回false(已计数并回调)
+func (l *connLimiter) acquire(ctx context.Context, addr net.Addr) bool {
+	ip := addrIP(addr)
+	l.mu.Lock()
+	reason := RejectReason("")
+	switch {
+	case l.opt.MaxConns > 0 && l.conns >= l.opt.MaxConns:
+		reason = RejectMaxConns
+	case l.opt.MaxConnsPerIP > 0 && l.perIP[ip] >= l.opt.MaxConnsPerIP:
+		reason = RejectMaxConnsPerIP
+	default:
+		l.conns++
+		l.perIP[ip]++
+	}
+	l.mu.Unlock()
+
+	if reason != "" {
+		l.reject(ctx, addr, reason)
+		return false
+	}
+	return true
+}
+
+// 连接关闭归还名额(与acquire成对调用)
+func (l *connLimiter) release(addr net.Addr) {
+	ip := addrIP(addr)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.conns--
+	if l.perIP[ip]--; l.perIP[ip] <= 0 {
+		delete(l.perIP, ip)
+	}
+}
+
+func (l *connLimiter) reject(ctx context.Context, addr net.Addr, reason RejectReason) {
+	switch reason {
+	case RejectMaxConns:
+		atomic.AddUint64(&l.rejectMaxConns, 1)
+	case RejectMaxConnsPerIP:
+		atomic.AddUint64(&l.rejectPerIP, 1)
+	case RejectMsgRate:
+		atomic.AddUint64(&l.msgRateLimited, 1)
+	case RejectByteRate:
+		atomic.AddUint64(&l.byteRateLimited, 1)
+	}
+	xlog.Get(ctx).Debug("Connection limited.", zap.Any("remote", addr), zap.String("reason", string(reason)))
+	if l.opt.OnReject != nil {
+		l.opt.OnReject(ctx, addr, reason)
+	}
+}
+
+// 单连接速率限制, 未配置速率返回nil
+func (l *connLimiter) newRate(addr net.Addr) *rateLimiter {
+	if l.opt.MsgRate <= 0 && l.opt.ByteRate <= 0 {
+		return nil
+	}
+	now := time.Now()
+	return &rateLimiter{
+		limiter: l,
+		addr:    addr,
+		msg:     newTokenBucket(l.opt.MsgRate, l.opt.MsgBurst, now),
+		bytes:   newTokenBucket(l.opt.ByteRate, l.opt.ByteBurst, now),
+	}
+}
+
+func (l *connLimiter) stats() LimitStats {
+	l.mu.Lock()
+	conns := l.conns
+	l.mu.Unlock()
+	return LimitStats{
+		Conns:           conns,
+		RejectMaxConns:  atomic.LoadUint64(&l.rejectMaxConns),
+		RejectPerIP:     atomic.LoadUint64(&l.rejectPerIP),
+		MsgRateLimited:  atomic.LoadUint64(&l.msgRateLimited),
+		ByteRateLimited: atomic.LoadUint64(&l.byteRateLimited),
+	}
+}
+
+// 单连接速率(仅读协程调用, 非线程安全), nil不限制
+type rateLimiter struct {
+	limiter *connLimiter
+	addr    net.Addr
+	msg     *tokenBucket
+	bytes   *tokenBucket
+}
+
+// 收到n条消息
+func (r *rateLimiter) allowMsg(ctx context.Context, n int) bool {
+	if r == nil || r.msg.take(n, time.Now()) {
+		return true
+	}
+	r.limiter.reject(ctx, r.addr, RejectMsgRate)
+	return false
+}
+
+// 收到n字节
+func (r *rateLimiter) allowBytes(ctx context.Context, n int) bool {
+	if r == nil || r.bytes.take(n, time.Now()) {
+		return true
+	}
+	r.limiter.reject(ctx, r.addr, RejectByteRate)
+	return false
+}
+
+// 令牌桶, 剩余令牌不少于1即放行(允许透支), 透支部分由后续补充抵扣
+type tokenBucket struct {
+	rate   float64 // 每秒补充
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// rate<=0返回nil(不限制)
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+func (b *tokenBucket) take(n int, now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens -= float64(n)
+	return true
+}
+
+// 地址对应ip(计数key), 无法解析时使用原地址
+func addrIP(addr net.Addr) string {
+	if ip := addrToIP(addr); ip != nil {
+		return ip.String()
+	}
+	return addr.String()
+}
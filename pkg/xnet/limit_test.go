@@ -0,0 +1,142 @@
+package xnet_test
+
+import (
+	"context"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 单ip连接数上限, 连接关闭后归还名额
+func TestTCPConnLimit(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9900"
+	rejectCh := make(chan xnet.RejectReason, 1)
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler(),
+		Limit: xnet.LimitOptions{MaxConns: 2, MaxConnsPerIP: 1, OnReject: func(ctx context.Context, addr net.Addr, reason xnet.RejectReason) { rejectCh <- reason }}})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	newCli := func() *xnet.TCPClient {
+		cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+		if err != nil {
+			panic(err)
+		}
+		return cli
+	}
+	cli1 := newCli()
+	waitUntil(t, "first conn", func() bool { return svr.LimitStats().Conns == 1 })
+
+	cli2 := newCli()
+	defer cli2.Close(ctx)
+	select {
+	case reason := <-rejectCh:
+		if reason != xnet.RejectMaxConnsPerIP {
+			t.Fatalf("reject reason %v", reason)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("reject timeout")
+	}
+	if stats := svr.LimitStats(); stats.Conns != 1 || stats.RejectPerIP != 1 {
+		t.Fatalf("stats %+v", stats)
+	}
+
+	cli1.Close(ctx)
+	waitUntil(t, "release", func() bool { return svr.LimitStats().Conns == 0 })
+	cli3 := newCli()
+	defer cli3.Close(ctx)
+	waitUntil(t, "reconnect", func() bool { return svr.LimitStats().Conns == 1 })
+}
+
+// 超过消息速率断开连接
+func TestTCPMsgRateLimit(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9901"
+	svrMetrics := xnet.NewMemMetrics()
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{Addr: addr, Metrics: svrMetrics, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler(),
+		Limit: xnet.LimitOptions{MsgRate: 1, MsgBurst: 3}})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	sendPayloads(ctx, cli, 10)
+	waitUntil(t, "rate limit close", func() bool { return svrMetrics.Snapshot().Closed == 1 })
+	if snap := svrMetrics.Snapshot(); snap.CloseReasons[xnet.CloseReasonRateLimit] != 1 {
+		t.Fatalf("server snapshot %+v", snap)
+	}
+	if stats := svr.LimitStats(); stats.MsgRateLimited != 1 || stats.Conns != 0 {
+		t.Fatalf("stats %+v", stats)
+	}
+}
+
+// udp超过连接数及速率丢弃数据包
+func TestUDPLimit(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9902"
+	var recv int32
+	svr, err := xnet.NewUDPServer(ctx, xnet.UDPSvrArgs{Addr: addr, Timeout: 10, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog,
+		OnMsg: xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+			atomic.AddInt32(&recv, 1)
+			return nil
+		}),
+		Limit: xnet.LimitOptions{MaxConns: 1, MsgRate: 1, MsgBurst: 2}})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	newCli := func() *xnet.UDPClient {
+		cli, err := xnet.NewUDPClient(ctx, xnet.UDPCliArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+		if err != nil {
+			panic(err)
+		}
+		return cli
+	}
+	cli1 := newCli()
+	defer cli1.Close(ctx)
+	sendPayloads(ctx, cli1, 5)
+	waitUntil(t, "rate limited", func() bool { return svr.LimitStats().MsgRateLimited == 3 })
+
+	cli2 := newCli()
+	defer cli2.Close(ctx)
+	sendPayloads(ctx, cli2, 1)
+	waitUntil(t, "max conns", func() bool { return svr.LimitStats().RejectMaxConns == 1 })
+	if n := atomic.LoadInt32(&recv); n != 2 {
+		t.Fatalf("recv %v", n)
+	}
+}
+
+// websocket超过连接数返回503
+func TestWebsocketConnLimit(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9903"
+	svr := xnet.NewWSServer(ctx, xnet.WSSvrArgs{Addr: addr, Path: "/", OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler(),
+		Limit: xnet.LimitOptions{MaxConns: 1}})
+	defer svr.Close(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	cli, err := xnet.NewWSClient(ctx, xnet.WSCliArgs{Addr: addr, Path: "/", OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	if _, err := xnet.NewWSClient(ctx, xnet.WSCliArgs{Addr: addr, Path: "/", OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()}); err == nil {
+		t.Fatalf("dial over limit success")
+	}
+	if stats := svr.LimitStats(); stats.Conns != 1 || stats.RejectMaxConns != 1 {
+		t.Fatalf("stats %+v", stats)
+	}
+}
@@ -0,0 +1,111 @@
+package xnet
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// 连接关闭原因
+type CloseReason string
+
+const (
+	CloseReasonRemote       CloseReason = "remote"        // 对端关闭
+	CloseReasonLocal        CloseReason = "local"         // 本端调用Close
+	CloseReasonShutdown     CloseReason = "shutdown"      // 优雅关闭
+	CloseReasonTimeout      CloseReason = "timeout"       // 读超时/会话超时
+	CloseReasonReadError    CloseReason = "read_error"    // 读错误
+	CloseReasonWriteError   CloseReason = "write_error"   // 写错误
+	CloseReasonHandlerError CloseReason = "handler_error" // 消息处理返回错误
+	CloseReasonSlowConsumer CloseReason = "slow_consumer" // 写队列满被断开
+	CloseReasonHeartbeat    CloseReason = "heartbeat"     // 连续未收到pong
+	CloseReasonIdle         CloseReason = "idle"          // 空闲超时(无业务消息)
+	CloseReasonRateLimit    CloseReason = "rate_limit"    // 超过速率限制
+	CloseReasonRetransmit   CloseReason = "retransmit"    // udp可靠消息重传超过上限
+)
+
+// 连接指标上报(实现须线程安全)
+// sock为具体连接对象(*TCPSocket, *KCPSocket, *Websocket, *UDPSession)
+type Metrics interface {
+	SocketOpen(sock Socket)                      // 建立连接
+	SocketClose(sock Socket, reason CloseReason) // 关闭连接(读写循环均已退出)
+	BytesIn(sock Socket, n int)                  // 读取字节数
+	BytesOut(sock Socket, n int, msgs int)       // 写入字节数/消息数
+	MsgHandled(sock Socket, cost time.Duration)  // 处理一条消息及耗时
+	QueueDepth(sock Socket, depth int)           // 写队列长度(udp连接共用写队列, 不上报)
+}
+
+// 默认不上报
+type nopMetrics struct{}
+
+func (nopMetrics) SocketOpen(sock Socket)                      {}
+func (nopMetrics) SocketClose(sock Socket, reason CloseReason) {}
+func (nopMetrics) BytesIn(sock Socket, n int)                  {}
+func (nopMetrics) BytesOut(sock Socket, n int, msgs int)       {}
+func (nopMetrics) MsgHandled(sock Socket, cost time.Duration)  {}
+func (nopMetrics) QueueDepth(sock Socket, depth int)           {}
+
+func metricsOrNop(m Metrics) Metrics {
+	if m == nil {
+		return nopMetrics{}
+	}
+	return m
+}
+
+// 单连接指标上报
+type socketReporter struct {
+	Metrics
+	sock   Socket
+	loops  int32        // 未退出的循环数量
+	reason atomic.Value // 关闭原因(首次设置生效)
+}
+
+func newSocketReporter(m Metrics, sock Socket, loops int32) *socketReporter {
+	r := &socketReporter{Metrics: metricsOrNop(m), sock: sock, loops: loops}
+	r.SocketOpen(sock)
+	return r
+}
+
+func (r *socketReporter) setCloseReason(reason CloseReason) {
+	r.reason.CompareAndSwap(nil, reason)
+}
+
+// 读循环错误对应的关闭原因
+func (r *socketReporter) setReadErr(err error) {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		r.setCloseReason(CloseReasonRemote)
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		r.setCloseReason(CloseReasonTimeout)
+	default:
+		r.setCloseReason(CloseReasonReadError)
+	}
+}
+
+// 循环退出, 全部退出后上报关闭
+func (r *socketReporter) loopDone() {
+	if atomic.AddInt32(&r.loops, -1) != 0 {
+		return
+	}
+	r.setCloseReason(CloseReasonRemote)
+	r.SocketClose(r.sock, r.reason.Load().(CloseReason))
+}
+
+func (r *socketReporter) bytesIn(n int) {
+	r.BytesIn(r.sock, n)
+}
+
+func (r *socketReporter) bytesOut(n int, msgs int) {
+	r.BytesOut(r.sock, n, msgs)
+}
+
+func (r *socketReporter) msgHandled(begin time.Time) {
+	r.MsgHandled(r.sock, time.Since(begin))
+}
+
+func (r *socketReporter) queueDepth(depth int) {
+	r.QueueDepth(r.sock, depth)
+}
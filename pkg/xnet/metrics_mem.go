@@ -0,0 +1,216 @@
+package xnet
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 单连接指标快照
+type SocketStats struct {
+	Remote        string
+	Local         string
+	OpenAt        time.Time
+	BytesIn       uint64
+	BytesOut      uint64
+	MsgsIn        uint64 // 已处理消息数
+	MsgsOut       uint64
+	QueueDepth    int64 // 当前写队列长度
+	MaxQueueDepth int64
+	HandlerTotal  time.Duration // 消息处理总耗时
+	HandlerMax    time.Duration
+}
+
+// 平均处理耗时
+func (s SocketStats) HandlerAvg() time.Duration {
+	if s.MsgsIn == 0 {
+		return 0
+	}
+	return s.HandlerTotal / time.Duration(s.MsgsIn)
+}
+
+// 汇总指标快照(包含已关闭连接)
+type MetricsSnapshot struct {
+	Opened       uint64 // 累计建立连接数
+	Closed       uint64 // 累计关闭连接数
+	BytesIn      uint64
+	BytesOut     uint64
+	MsgsIn       uint64
+	MsgsOut      uint64
+	QueueDepth   int64 // 当前写队列总长度
+	HandlerTotal time.Duration
+	HandlerMax   time.Duration
+	CloseReasons map[CloseReason]uint64
+	Sockets      []SocketStats // 活跃连接
+}
+
+// 平均处理耗时
+func (s MetricsSnapshot) HandlerAvg() time.Duration {
+	if s.MsgsIn == 0 {
+		return 0
+	}
+	return s.HandlerTotal / time.Duration(s.MsgsIn)
+}
+
+type memCounters struct {
+	bytesIn      uint64
+	bytesOut     uint64
+	msgsIn       uint64
+	msgsOut      uint64
+	queueDepth   int64
+	handlerTotal int64
+	handlerMax   int64
+}
+
+func (c *memCounters) addHandler(cost time.Duration) {
+	atomic.AddUint64(&c.msgsIn, 1)
+	atomic.AddInt64(&c.handlerTotal, int64(cost))
+	storeMax(&c.handlerMax, int64(cost))
+}
+
+func storeMax(addr *int64, v int64) {
+	for {
+		old := atomic.LoadInt64(addr)
+		if v <= old || atomic.CompareAndSwapInt64(addr, old, v) {
+			return
+		}
+	}
+}
+
+type memSocket struct {
+	memCounters
+	sock          Socket
+	openAt        time.Time
+	maxQueueDepth int64
+}
+
+func (s *memSocket) stats() SocketStats {
+	return SocketStats{
+		Remote:        s.sock.RemoteAddr().String(),
+		Local:         s.sock.LocalAddr().String(),
+		OpenAt:        s.openAt,
+		BytesIn:       atomic.LoadUint64(&s.bytesIn),
+		BytesOut:      atomic.LoadUint64(&s.bytesOut),
+		MsgsIn:        atomic.LoadUint64(&s.msgsIn),
+		MsgsOut:       atomic.LoadUint64(&s.msgsOut),
+		QueueDepth:    atomic.LoadInt64(&s.queueDepth),
+		MaxQueueDepth: atomic.LoadInt64(&s.maxQueueDepth),
+		HandlerTotal:  time.Duration(atomic.LoadInt64(&s.handlerTotal)),
+		HandlerMax:    time.Duration(atomic.LoadInt64(&s.handlerMax)),
+	}
+}
+
+// 内存指标: 记录活跃连接指标与汇总指标
+// 可在多个server/client间共用
+type MemMetrics struct {
+	total  memCounters
+	opened uint64
+	closed uint64
+
+	mu      sync.RWMutex
+	sockets map[Socket]*memSocket
+	reasons map[CloseReason]uint64
+}
+
+func NewMemMetrics() *MemMetrics {
+	return &MemMetrics{
+		sockets: make(map[Socket]*memSocket),
+		reasons: make(map[CloseReason]uint64),
+	}
+}
+
+func (m *MemMetrics) get(sock Socket) *memSocket {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sockets[sock]
+}
+
+func (m *MemMetrics) SocketOpen(sock Socket) {
+	atomic.AddUint64(&m.opened, 1)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sockets[sock] = &memSocket{sock: sock, openAt: time.Now()}
+}
+
+func (m *MemMetrics) SocketClose(sock Socket, reason CloseReason) {
+	atomic.AddUint64(&m.closed, 1)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.sockets[sock]; ok {
+		// 未发送的写队列不再计入
+		atomic.AddInt64(&m.total.queueDepth, -atomic.LoadInt64(&s.queueDepth))
+		delete(m.sockets, sock)
+	}
+	m.reasons[reason]++
+}
+
+func (m *MemMetrics) BytesIn(sock Socket, n int) {
+	atomic.AddUint64(&m.total.bytesIn, uint64(n))
+	if s := m.get(sock); s != nil {
+		atomic.AddUint64(&s.bytesIn, uint64(n))
+	}
+}
+
+func (m *MemMetrics) BytesOut(sock Socket, n int, msgs int) {
+	atomic.AddUint64(&m.total.bytesOut, uint64(n))
+	atomic.AddUint64(&m.total.msgsOut, uint64(msgs))
+	if s := m.get(sock); s != nil {
+		atomic.AddUint64(&s.bytesOut, uint64(n))
+		atomic.AddUint64(&s.msgsOut, uint64(msgs))
+	}
+}
+
+func (m *MemMetrics) MsgHandled(sock Socket, cost time.Duration) {
+	m.total.addHandler(cost)
+	if s := m.get(sock); s != nil {
+		s.addHandler(cost)
+	}
+}
+
+func (m *MemMetrics) QueueDepth(sock Socket, depth int) {
+	s := m.get(sock)
+	if s == nil {
+		return
+	}
+	old := atomic.SwapInt64(&s.queueDepth, int64(depth))
+	storeMax(&s.maxQueueDepth, int64(depth))
+	atomic.AddInt64(&m.total.queueDepth, int64(depth)-old)
+}
+
+// 单连接快照(连接关闭后返回false)
+func (m *MemMetrics) SocketStats(sock Socket) (SocketStats, bool) {
+	s := m.get(sock)
+	if s == nil {
+		return SocketStats{}, false
+	}
+	return s.stats(), true
+}
+
+// 汇总快照
+func (m *MemMetrics) Snapshot() MetricsSnapshot {
+	snap := MetricsSnapshot{
+		Opened:       atomic.LoadUint64(&m.opened),
+		Closed:       atomic.LoadUint64(&m.closed),
+		BytesIn:      atomic.LoadUint64(&m.total.bytesIn),
+		BytesOut:     atomic.LoadUint64(&m.total.bytesOut),
+		MsgsIn:       atomic.LoadUint64(&m.total.msgsIn),
+		MsgsOut:      atomic.LoadUint64(&m.total.msgsOut),
+		QueueDepth:   atomic.LoadInt64(&m.total.queueDepth),
+		HandlerTotal: time.Duration(atomic.LoadInt64(&m.total.handlerTotal)),
+		HandlerMax:   time.Duration(atomic.LoadInt64(&m.total.handlerMax)),
+		CloseReasons: make(map[CloseReason]uint64),
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for reason, n := range m.reasons {
+		snap.CloseReasons[reason] = n
+	}
+	snap.Sockets = make([]SocketStats, 0, len(m.sockets))
+	for _, s := range m.sockets {
+		snap.Sockets = append(snap.Sockets, s.stats())
+	}
+	return snap
+}
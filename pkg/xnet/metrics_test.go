@@ -0,0 +1,88 @@
+package xnet_test
+
+import (
+	"context"
+	"gotu/pkg/xlog"
+	"gotu/pkg/xnet"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func checkTraffic(t *testing.T, name string, snap xnet.MetricsSnapshot, count uint64, bytes uint64) {
+	xlog.Get(context.Background()).Debug("Metrics snapshot", zap.String("name", name), zap.Any("snapshot", snap))
+	if snap.Opened != 1 || snap.MsgsIn != count || snap.MsgsOut != count || snap.BytesIn != bytes || snap.BytesOut != bytes {
+		t.Fatalf("%v snapshot %+v", name, snap)
+	}
+	if snap.QueueDepth != 0 || snap.HandlerMax <= 0 || snap.HandlerAvg() > snap.HandlerMax {
+		t.Fatalf("%v snapshot %+v", name, snap)
+	}
+}
+
+func TestTCPMetrics(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9951"
+	svrMetrics, cliMetrics := xnet.NewMemMetrics(), xnet.NewMemMetrics()
+
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{Addr: addr, Metrics: svrMetrics, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	var wg sync.WaitGroup
+	cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{Addr: addr, Metrics: cliMetrics, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: doneHandler(&wg)})
+	if err != nil {
+		panic(err)
+	}
+	bytes := sendEcho(ctx, func(msg []byte) error { return cli.SendMsg(ctx, msg) }, &wg, 10)
+
+	// 回包写入后计数
+	waitUntil(t, "server bytes out", func() bool { return svrMetrics.Snapshot().BytesOut == bytes })
+	snap := svrMetrics.Snapshot()
+	checkTraffic(t, "server", snap, 10, bytes)
+	if len(snap.Sockets) != 1 || snap.Sockets[0].MsgsIn != 10 || snap.Sockets[0].BytesOut != bytes || snap.Sockets[0].Remote == "" {
+		t.Fatalf("server sockets %+v", snap.Sockets)
+	}
+	checkTraffic(t, "client", cliMetrics.Snapshot(), 10, bytes)
+
+	// 客户端主动关闭
+	cli.Close(ctx)
+	waitUntil(t, "server close", func() bool { return svrMetrics.Snapshot().Closed == 1 })
+	if snap := svrMetrics.Snapshot(); snap.CloseReasons[xnet.CloseReasonRemote] != 1 || len(snap.Sockets) != 0 {
+		t.Fatalf("server snapshot %+v", snap)
+	}
+	if snap := cliMetrics.Snapshot(); snap.Closed != 1 || snap.CloseReasons[xnet.CloseReasonLocal] != 1 {
+		t.Fatalf("client snapshot %+v", snap)
+	}
+}
+
+func TestUDPMetrics(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9952"
+	svrMetrics, cliMetrics := xnet.NewMemMetrics(), xnet.NewMemMetrics()
+
+	svr, err := xnet.NewUDPServer(ctx, xnet.UDPSvrArgs{Addr: addr, Timeout: 10, Metrics: svrMetrics, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+
+	var wg sync.WaitGroup
+	cli, err := xnet.NewUDPClient(ctx, xnet.UDPCliArgs{Addr: addr, Timeout: 10, Metrics: cliMetrics, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: doneHandler(&wg)})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+	bytes := sendEcho(ctx, func(msg []byte) error { return cli.SendMsg(ctx, msg) }, &wg, 10)
+
+	waitUntil(t, "server bytes out", func() bool { return svrMetrics.Snapshot().BytesOut == bytes })
+	checkTraffic(t, "server", svrMetrics.Snapshot(), 10, bytes)
+	checkTraffic(t, "client", cliMetrics.Snapshot(), 10, bytes)
+
+	// 服务端关闭session
+	svr.Close(ctx)
+	if snap := svrMetrics.Snapshot(); snap.Closed != 1 || snap.CloseReasons[xnet.CloseReasonLocal] != 1 || len(snap.Sockets) != 0 {
+		t.Fatalf("server snapshot %+v", snap)
+	}
+}
@@ -0,0 +1,110 @@
+package xnet
+
+import (
+	"context"
+	"gotu/pkg/xlog"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 连接参数(零值使用默认值), 各服务/客户端独立配置
+type Options struct {
+	ReadBufferSize      int           // 单次读取buffer大小(默认1024), udp为单个数据包上限
+	SockReadBuffer      int           // 系统接收缓冲区SO_RCVBUF, 0: 系统默认
+	SockWriteBuffer     int           // 系统发送缓冲区SO_SNDBUF, 0: 系统默认
+	Nagle               bool          // tcp/websocket开启Nagle算法(默认关闭, 即NoDelay)
+	ReadTimeout         time.Duration // 读超时(默认60s), tcp/websocket可由HeartbeatOptions.ReadTimeout覆盖
+	WriteTimeout        time.Duration // 写超时(默认10s)
+	TLSHandshakeTimeout time.Duration // tls握手超时(默认10s)
+	MaxMessageSize      int64         // websocket单条消息上限(默认2K)
+	WriteBatchBytes     int           // tcp/unix单次批量写入(writev)字节上限(默认64K), 单条消息超过时单独写入
+	WriteBatchMsgs      int           // tcp/unix单次批量写入消息数上限(默认1024)
+	KCP                 *KCPOptions   // kcp调优参数, nil: DefaultKCPOptions()
+}
+
+// kcp调优参数
+type KCPOptions struct {
+	AckNoDelay   bool // 非延迟ack
+	NoDelay      int  // 1:RTO=30ms,0:RTO=100ms
+	Interval     int  // 工作间隔ms(越小越快,cpu越高, 10, 20, 30, 40)
+	Resend       int  // 快速重传(0关闭)
+	NC           int  // 是否关闭拥塞算法(0:开启,1:关闭)
+	DataShards   int  // fec源数据包数量
+	ParityShards int  // fec生成数据包数量
+}
+
+// 默认kcp参数, 自定义时在此基础上修改
+func DefaultKCPOptions() KCPOptions {
+	return KCPOptions{
+		AckNoDelay:   kcpAckNoDelay,
+		NoDelay:      kcpNoDelay,
+		Interval:     kcpInterval,
+		Resend:       kcpResend,
+		NC:           kcpNC,
+		DataShards:   kcpFecDataShards,
+		ParityShards: kcpFecParityShards,
+	}
+}
+
+func (opt Options) withDefault() Options {
+	if opt.ReadBufferSize <= 0 {
+		opt.ReadBufferSize = readBufferSize
+	}
+	if opt.ReadTimeout <= 0 {
+		opt.ReadTimeout = readTimeout
+	}
+	if opt.WriteTimeout <= 0 {
+		opt.WriteTimeout = writeTimeout
+	}
+	if opt.TLSHandshakeTimeout <= 0 {
+		opt.TLSHandshakeTimeout = tlsHandshakeTimeout
+	}
+	if opt.MaxMessageSize <= 0 {
+		opt.MaxMessageSize = maxMessageSize
+	}
+	if opt.WriteBatchBytes <= 0 {
+		opt.WriteBatchBytes = writeBatchBytes
+	}
+	if opt.WriteBatchMsgs <= 0 {
+		opt.WriteBatchMsgs = writeBatchMsgs
+	}
+	if opt.KCP == nil {
+		kcpOpt := DefaultKCPOptions()
+		opt.KCP = &kcpOpt
+	}
+	return opt
+}
+
+// 系统缓冲区设置
+type sockBufferSetter interface {
+	SetReadBuffer(bytes int) error
+	SetWriteBuffer(bytes int) error
+}
+
+// 设置系统缓冲区, 失败仅记录日志
+func (opt Options) applyBuffer(ctx context.Context, s sockBufferSetter) {
+	if opt.SockReadBuffer > 0 {
+		if err := s.SetReadBuffer(opt.SockReadBuffer); err != nil {
+			xlog.Get(ctx).Warn("Set read buffer failed.", zap.Any("err", err))
+		}
+	}
+	if opt.SockWriteBuffer > 0 {
+		if err := s.SetWriteBuffer(opt.SockWriteBuffer); err != nil {
+			xlog.Get(ctx).Warn("Set write buffer failed.", zap.Any("err", err))
+		}
+	}
+}
+
+// tcp/unix连接参数(NoDelay仅tcp)
+func (opt Options) applyConn(ctx context.Context, conn net.Conn) {
+	if tcpConn, ok := conn.(*net.TCPConn); ok && opt.Nagle {
+		if err := tcpConn.SetNoDelay(false); err != nil {
+			xlog.Get(ctx).Warn("Set no delay failed.", zap.Any("err", err))
+		}
+	}
+	if s, ok := conn.(sockBufferSetter); ok {
+		opt.applyBuffer(ctx, s)
+	}
+}
@@ -0,0 +1,85 @@
+//go:build linux
+
+package xnet_test
+
+import (
+	"context"
+	"gotu/pkg/xnet"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/sys/unix"
+)
+
+// 查找本进程中本地地址为local, 对端地址为peer的tcp socket(-1: 未找到)
+func findSockFd(local, peer *net.TCPAddr) int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		panic(err)
+	}
+	match := func(sa unix.Sockaddr, addr *net.TCPAddr) bool {
+		in4, ok := sa.(*unix.SockaddrInet4)
+		return ok && in4.Port == addr.Port && net.IP(in4.Addr[:]).Equal(addr.IP)
+	}
+	for _, entry := range entries {
+		fd, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		sa, err := unix.Getsockname(fd)
+		if err != nil || !match(sa, local) {
+			continue
+		}
+		if pa, err := unix.Getpeername(fd); err == nil && match(pa, peer) {
+			return fd
+		}
+	}
+	return -1
+}
+
+// 开启PROXY protocol时websocket连接同样应用Nagle及socket缓冲区选项
+func TestWebsocketProxyOptions(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9933"
+	options := xnet.Options{SockReadBuffer: 8 * 1024, SockWriteBuffer: 32 * 1024, Nagle: true}
+	remoteCh := make(chan string, 1)
+	svr := xnet.NewWSServer(ctx, xnet.WSSvrArgs{Addr: addr, Path: "/", Options: options, Proxy: xnet.ProxyOptions{ProxyProtocol: true},
+		OnConnect: onConnectRemote(remoteCh), OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	defer svr.Close(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+	if _, err := conn.Write([]byte("PROXY TCP4 5.6.7.8 127.0.0.1 5678 9933\r\n")); err != nil {
+		panic(err)
+	}
+	ws, _, err := websocket.NewClient(conn, &url.URL{Scheme: "ws", Host: addr, Path: "/"}, nil, 1024, 1024)
+	if err != nil {
+		panic(err)
+	}
+	defer ws.Close()
+	expectRemote(t, remoteCh, "5.6.7.8:5678")
+
+	fd := findSockFd(conn.RemoteAddr().(*net.TCPAddr), conn.LocalAddr().(*net.TCPAddr))
+	if fd < 0 {
+		t.Fatalf("server socket not found")
+	}
+	get := func(level, opt int) int {
+		v, err := unix.GetsockoptInt(fd, level, opt)
+		if err != nil {
+			panic(err)
+		}
+		return v
+	}
+	// linux返回设置值的2倍
+	if rcv, snd, nodelay := get(unix.SOL_SOCKET, unix.SO_RCVBUF), get(unix.SOL_SOCKET, unix.SO_SNDBUF), get(unix.IPPROTO_TCP, unix.TCP_NODELAY); rcv != 2*options.SockReadBuffer || snd != 2*options.SockWriteBuffer || nodelay != 0 {
+		t.Fatalf("rcvbuf %v sndbuf %v nodelay %v", rcv, snd, nodelay)
+	}
+}
@@ -0,0 +1,123 @@
+package xnet_test
+
+import (
+	"bytes"
+	"context"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 读取buffer小于数据包, 开启Nagle
+func TestTCPOptions(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9989"
+	options := xnet.Options{ReadBufferSize: 16, SockReadBuffer: 64 * 1024, SockWriteBuffer: 64 * 1024, Nagle: true, WriteTimeout: time.Second}
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{Addr: addr, Options: options, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	recvCh := make(chan []byte, 1)
+	cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{Addr: addr, Options: options, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: recvPayload(recvCh)})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	payload := bytes.Repeat([]byte("0123456789"), 100)
+	msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: payload})
+	if err != nil {
+		panic(err)
+	}
+	if err := cli.SendMsg(ctx, msg); err != nil {
+		panic(err)
+	}
+	expectPayload(t, "tcp", recvCh, payload)
+}
+
+// 超过消息上限断开连接
+func TestWebsocketMaxMessageSize(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9990"
+	svrMetrics := xnet.NewMemMetrics()
+	svr := xnet.NewWSServer(ctx, xnet.WSSvrArgs{Addr: addr, Path: "/", Metrics: svrMetrics, Options: xnet.Options{MaxMessageSize: 64},
+		OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	defer svr.Close(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	recvCh := make(chan []byte, 1)
+	cli, err := xnet.NewWSClient(ctx, xnet.WSCliArgs{Addr: addr, Path: "/", OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: recvPayload(recvCh)})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	for _, size := range []int{32, 128} {
+		msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: make([]byte, size)})
+		if err != nil {
+			panic(err)
+		}
+		if err := cli.SendMsg(ctx, msg); err != nil {
+			panic(err)
+		}
+		if size == 32 {
+			expectPayload(t, "ws", recvCh, make([]byte, size))
+		}
+	}
+	waitUntil(t, "ws close", func() bool { return svrMetrics.Snapshot().Closed == 1 })
+	if snap := svrMetrics.Snapshot(); snap.CloseReasons[xnet.CloseReasonReadError] != 1 {
+		t.Fatalf("server snapshot %+v", snap)
+	}
+}
+
+// 自定义kcp参数(关闭fec)
+func TestKCPOptions(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9991"
+	kcpOpt := xnet.DefaultKCPOptions()
+	kcpOpt.Interval, kcpOpt.DataShards, kcpOpt.ParityShards = 10, 0, 0
+	options := xnet.Options{KCP: &kcpOpt, ReadBufferSize: 256}
+	svr, err := xnet.NewKCPServer(ctx, xnet.KCPServerArgs{Addr: addr, Options: options, IsInline: true, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	recvCh := make(chan []byte, 1)
+	cli, err := xnet.NewKCPClient(ctx, xnet.KCPClientArgs{Addr: addr, Options: options, IsInline: true, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: recvPayload(recvCh)})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			payload := bytes.Repeat([]byte{byte('a' + i)}, 1000)
+			msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: payload})
+			if err != nil {
+				panic(err)
+			}
+			if err := cli.SendMsg(ctx, msg); err != nil {
+				panic(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < 3; i++ {
+		select {
+		case recv := <-recvCh:
+			if len(recv) != 1000 {
+				t.Fatalf("kcp recv %v bytes", len(recv))
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("kcp recv timeout")
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package xnet
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	proxyV1MaxLen      = 107             // v1头最大长度(含\r\n)
+	proxyV2HeaderLen   = 16              // v2固定头长度
+	proxyHeaderTimeout = 5 * time.Second // 默认读取PROXY头超时
+)
+
+var (
+	ErrProxyHeader = errors.New("invalid proxy protocol header") // PROXY头格式错误或缺失
+
+	proxyV1Prefix = []byte("PROXY ")
+	proxyV2Sig    = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// 代理场景下获取客户端真实地址(零值: 不解析)
+type ProxyOptions struct {
+	ProxyProtocol bool          // tcp/websocket解析PROXY protocol v1/v2头
+	Optional      bool          // 允许无PROXY头的连接(默认断开)
+	HeaderTimeout time.Duration // 读取PROXY头超时(默认5s)
+	ForwardedFor  bool          // websocket升级时使用X-Forwarded-For/X-Real-IP, 须配置Trusted
+	Trusted       *AccessList   // 可信代理(负载均衡)地址, 非可信来源不解析; nil: PROXY头信任所有, 请求头不信任
+}
+
+func (opt ProxyOptions) withDefault() ProxyOptions {
+	if opt.HeaderTimeout <= 0 {
+		opt.HeaderTimeout = proxyHeaderTimeout
+	}
+	return opt
+}
+
+// 来源是否为可信代理(PROXY头)
+func (opt ProxyOptions) trusted(addr net.Addr) bool {
+	return opt.Trusted == nil || opt.Trusted.Allowed(addrToIP(addr))
+}
+
+// 来源是否为可信代理(X-Forwarded-For/X-Real-IP可由客户端伪造, 未配置Trusted不信任)
+func (opt ProxyOptions) trustedForwarder(addr net.Addr) bool {
+	return opt.Trusted != nil && opt.Trusted.Allowed(addrToIP(addr))
+}
+
+// 解析PROXY头的连接, 首次Read/RemoteAddr时读取头部
+type proxyConn struct {
+	net.Conn
+	opt    ProxyOptions
+	r      *bufio.Reader
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+func newProxyConn(conn net.Conn, opt ProxyOptions) *proxyConn {
+	return &proxyConn{Conn: conn, opt: opt, remote: conn.RemoteAddr()}
+}
+
+// 原始连接(去除PROXY协议包装), 用于设置socket选项及writev
+func unwrapConn(conn net.Conn) net.Conn {
+	if c, ok := conn.(*proxyConn); ok {
+		return unwrapConn(c.Conn)
+	}
+	return conn
+}
+
+// 读取PROXY头(仅一次), 非可信来源直接使用原连接
+func (c *proxyConn) init() error {
+	c.once.Do(func() {
+		if !c.opt.trusted(c.Conn.RemoteAddr()) {
+			return
+		}
+		c.r = bufio.NewReader(c.Conn)
+		if err := c.Conn.SetReadDeadline(time.Now().Add(c.opt.HeaderTimeout)); err != nil {
+			c.err = err
+			return
+		}
+		remote, err := readProxyHeader(c.r, c.opt.Optional)
+		if err != nil {
+			c.err = err
+			return
+		}
+		if remote != nil {
+			c.remote = remote
+		}
+		c.err = c.Conn.SetReadDeadline(time.Time{})
+	})
+	return c.err
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	if err := c.init(); err != nil {
+		return 0, err
+	}
+	// 头部之后的缓存读完后直接读取连接
+	if c.r != nil && c.r.Buffered() > 0 {
+		return c.r.Read(b)
+	}
+	return c.Conn.Read(b)
+}
+
+// 客户端真实地址(LOCAL/UNKNOWN/无头部时为直连地址)
+func (c *proxyConn) RemoteAddr() net.Addr {
+	_ = c.init()
+	return c.remote
+}
+
+// 接收连接包装为proxyConn(头部在连接协程内读取, 不阻塞Accept)
+type proxyListener struct {
+	net.Listener
+	opt ProxyOptions
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newProxyConn(conn, l.opt), nil
+}
+
+// 读取PROXY头, 返回源地址(LOCAL/UNKNOWN返回nil)
+// optional: 无PROXY头时返回nil, 数据保留在r中
+func readProxyHeader(r *bufio.Reader, optional bool) (net.Addr, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case first[0] == proxyV1Prefix[0]:
+		if b, err := r.Peek(len(proxyV1Prefix)); err == nil && bytes.Equal(b, proxyV1Prefix) {
+			return readProxyV1(r)
+		}
+	case first[0] == proxyV2Sig[0]:
+		if b, err := r.Peek(len(proxyV2Sig)); err == nil && bytes.Equal(b, proxyV2Sig) {
+			return readProxyV2(r)
+		}
+	}
+	if optional {
+		return nil, nil
+	}
+	return nil, ErrProxyHeader
+}
+
+// v1: "PROXY TCP4 src dst sport dport\r\n"
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	line := make([]byte, 0, proxyV1MaxLen)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= proxyV1MaxLen {
+			return nil, fmt.Errorf("%w: v1 header too long", ErrProxyHeader)
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: v1 header without crlf", ErrProxyHeader)
+	}
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("%w: v1 %q", ErrProxyHeader, line)
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return nil, fmt.Errorf("%w: v1 source %v:%v", ErrProxyHeader, fields[2], fields[4])
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// v2: 12字节签名 + 版本/命令 + 地址族/协议 + 长度(大端) + 地址
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, proxyV2HeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if header[12]>>4 != 2 {
+		return nil, fmt.Errorf("%w: v2 version %v", ErrProxyHeader, header[12]>>4)
+	}
+	body := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	switch header[12] & 0x0f {
+	case 0x0: // LOCAL(健康检查), 使用直连地址
+		return nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, fmt.Errorf("%w: v2 command %v", ErrProxyHeader, header[12]&0x0f)
+	}
+	switch header[13] >> 4 {
+	case 0x1: // AF_INET
+		if len(body) < 12 {
+			return nil, fmt.Errorf("%w: v2 ipv4 length %v", ErrProxyHeader, len(body))
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))}, nil
+	case 0x2: // AF_INET6
+		if len(body) < 36 {
+			return nil, fmt.Errorf("%w: v2 ipv6 length %v", ErrProxyHeader, len(body))
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))}, nil
+	}
+	// AF_UNSPEC/AF_UNIX
+	return nil, nil
+}
+
+// 读取PROXY头, ctx取消时中断
+func readProxyConn(ctx context.Context, conn *proxyConn) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Conn.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	return conn.init()
+}
+
+// websocket升级请求的客户端地址
+// 直连地址为可信代理时: X-Forwarded-For从右向左取第一个非可信地址, 其次X-Real-IP
+func forwardedAddr(r *http.Request, opt ProxyOptions) net.Addr {
+	remote := remoteAddr(r)
+	if !opt.ForwardedFor || !opt.trustedForwarder(remote) {
+		return remote
+	}
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		ips := strings.Split(strings.Join(xff, ","), ",")
+		var client net.IP
+		for i := len(ips) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(ips[i]))
+			if ip == nil {
+				break
+			}
+			client = ip
+			if !opt.trustedForwarder(&net.TCPAddr{IP: ip}) {
+				break
+			}
+		}
+		if client != nil {
+			return &net.TCPAddr{IP: client}
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return &net.TCPAddr{IP: ip}
+	}
+	return remote
+}
@@ -0,0 +1,185 @@
+package xnet_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// OnConnect记录RemoteAddr
+func onConnectRemote(remoteCh chan string) xnet.OnConnect {
+	return func(ctx context.Context, sock xnet.Socket) interface{} {
+		remoteCh <- sock.RemoteAddr().String()
+		return sock
+	}
+}
+
+func expectRemote(t *testing.T, remoteCh chan string, remote string) {
+	select {
+	case addr := <-remoteCh:
+		if addr != remote {
+			t.Fatalf("remote %v, expect %v", addr, remote)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("connect timeout")
+	}
+}
+
+func proxyV2Header(src net.IP, port uint16) []byte {
+	header := []byte("\r\n\r\n\x00\r\nQUIT\n\x21\x21")
+	body := make([]byte, 36)
+	copy(body[0:16], src.To16())
+	copy(body[16:32], net.IPv6loopback)
+	binary.BigEndian.PutUint16(body[32:34], port)
+	binary.BigEndian.PutUint16(body[34:36], 9906)
+	header = append(header, 0, 0)
+	binary.BigEndian.PutUint16(header[14:16], uint16(len(body)))
+	return append(header, body...)
+}
+
+func TestTCPProxyProtocol(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9906"
+	remoteCh := make(chan string, 1)
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{Addr: addr, Proxy: xnet.ProxyOptions{ProxyProtocol: true},
+		OnConnect: onConnectRemote(remoteCh), OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: []byte("data")})
+	if err != nil {
+		panic(err)
+	}
+	cases := []struct {
+		header []byte
+		remote string
+	}{
+		{[]byte("PROXY TCP4 1.2.3.4 127.0.0.1 5678 9906\r\n"), "1.2.3.4:5678"},
+		{proxyV2Header(net.ParseIP("2001:db8::1"), 5679), "[2001:db8::1]:5679"},
+	}
+	for _, c := range cases {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			panic(err)
+		}
+		// 头部与首个消息合并发送
+		if _, err := conn.Write(append(c.header, msg...)); err != nil {
+			panic(err)
+		}
+		expectRemote(t, remoteCh, c.remote)
+		recv := make([]byte, len(msg))
+		_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		if _, err := io.ReadFull(conn, recv); err != nil || !bytes.Equal(recv, msg) {
+			t.Fatalf("echo %v %v", recv, err)
+		}
+		conn.Close()
+	}
+
+	// 缺少头部断开连接
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(msg); err != nil {
+		panic(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("read without header %v", err)
+	}
+}
+
+// PROXY头来源为可信代理, X-Forwarded-For跳过可信代理取客户端地址
+func TestWebsocketProxy(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9907"
+	trusted, err := xnet.NewAccessList([]string{"127.0.0.1", "10.0.0.0/8"}, nil)
+	if err != nil {
+		panic(err)
+	}
+	remoteCh := make(chan string, 1)
+	svr := xnet.NewWSServer(ctx, xnet.WSSvrArgs{Addr: addr, Path: "/", Proxy: xnet.ProxyOptions{ProxyProtocol: true, ForwardedFor: true, Trusted: trusted},
+		OnConnect: onConnectRemote(remoteCh), OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	defer svr.Close(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	dial := func(proxyHeader string, xff string) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			panic(err)
+		}
+		if _, err := conn.Write([]byte(proxyHeader)); err != nil {
+			panic(err)
+		}
+		header := http.Header{}
+		if xff != "" {
+			header.Set("X-Forwarded-For", xff)
+		}
+		ws, _, err := websocket.NewClient(conn, &url.URL{Scheme: "ws", Host: addr, Path: "/"}, header, 1024, 1024)
+		if err != nil {
+			panic(err)
+		}
+		t.Cleanup(func() { ws.Close() })
+	}
+
+	dial("PROXY TCP4 10.0.0.2 127.0.0.1 5678 9907\r\n", "5.6.7.8, 10.0.0.1")
+	expectRemote(t, remoteCh, "5.6.7.8:0")
+	// 非可信来源忽略X-Forwarded-For
+	dial("PROXY TCP4 9.9.9.9 127.0.0.1 5678 9907\r\n", "5.6.7.8")
+	expectRemote(t, remoteCh, "9.9.9.9:5678")
+	dial("PROXY UNKNOWN\r\n", "")
+	select {
+	case remote := <-remoteCh:
+		if host, _, _ := net.SplitHostPort(remote); host != "127.0.0.1" {
+			t.Fatalf("unknown remote %v", remote)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("connect timeout")
+	}
+}
+
+// 直连来源非可信代理(含未配置Trusted)时忽略X-Forwarded-For/X-Real-IP
+func TestWebsocketForwardedUntrusted(t *testing.T) {
+	ctx := context.Background()
+	other, err := xnet.NewAccessList([]string{"10.0.0.0/8"}, nil)
+	if err != nil {
+		panic(err)
+	}
+	for addr, trusted := range map[string]*xnet.AccessList{"127.0.0.1:9926": nil, "127.0.0.1:9927": other} {
+		remoteCh := make(chan string, 1)
+		svr := xnet.NewWSServer(ctx, xnet.WSSvrArgs{Addr: addr, Path: "/", Proxy: xnet.ProxyOptions{ForwardedFor: true, Trusted: trusted},
+			OnConnect: onConnectRemote(remoteCh), OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+		defer svr.Close(ctx)
+		time.Sleep(100 * time.Millisecond)
+
+		header := http.Header{}
+		header.Set("X-Forwarded-For", "5.6.7.8")
+		header.Set("X-Real-IP", "5.6.7.9")
+		ws, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/", header)
+		if err != nil {
+			panic(err)
+		}
+		defer ws.Close()
+		select {
+		case remote := <-remoteCh:
+			if remote != ws.LocalAddr().String() {
+				t.Fatalf("%v remote %v", addr, remote)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("%v connect timeout", addr)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package xnet
+
+import (
+	"context"
+	"errors"
+	"gotu/pkg/xlog"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMinBackoff = 100 * time.Millisecond // 默认首次重连等待
+	defaultMaxBackoff = 10 * time.Second       // 默认重连等待上限
+	defaultMultiplier = 2.0                    // 默认等待倍数
+	defaultJitter     = 0.2                    // 默认随机抖动比例
+)
+
+var ErrReconnectBufferFull = errors.New("reconnect buffer full") // 断线缓存已满
+
+// 自动重连(Enable开启, 首次连接失败直接返回错误)
+type ReconnectOptions struct {
+	Enable      bool
+	MinBackoff  time.Duration // 首次重连等待(默认100ms)
+	MaxBackoff  time.Duration // 重连等待上限(默认10s)
+	Multiplier  float64       // 等待时间倍数(默认2)
+	Jitter      float64       // 随机抖动比例[0, 1](默认0.2)
+	MaxAttempts int           // 连续重连失败上限, 超过后放弃, 0: 不限制
+	BufferMsgs  int           // 断线期间缓存SendMsg消息数量上限, 重连后按序发送, 0: 不缓存(返回ErrSockClosed)
+
+	OnDisconnected func(ctx context.Context)                         // 连接断开(开始重连)
+	OnRetryFailed  func(ctx context.Context, attempt int, err error) // 单次重连失败
+	OnReconnected  func(ctx context.Context, attempts int)           // 重连成功(断线缓存补发完成且连接未断开)
+	OnGiveUp       func(ctx context.Context, err error)              // 放弃重连(缓存消息丢弃)
+	OnFlushFailed  func(ctx context.Context, dropped int, err error) // 重连后补发缓存失败, dropped: 丢弃消息数, err: 最后一次错误
+}
+
+func (opt ReconnectOptions) withDefault() ReconnectOptions {
+	if opt.MinBackoff <= 0 {
+		opt.MinBackoff = defaultMinBackoff
+	}
+	if opt.MaxBackoff < opt.MinBackoff {
+		opt.MaxBackoff = defaultMaxBackoff
+		if opt.MaxBackoff < opt.MinBackoff {
+			opt.MaxBackoff = opt.MinBackoff
+		}
+	}
+	if opt.Multiplier < 1 {
+		opt.Multiplier = defaultMultiplier
+	}
+	if opt.Jitter <= 0 || opt.Jitter > 1 {
+		opt.Jitter = defaultJitter
+	}
+	return opt
+}
+
+// 第attempt次重连前等待时间
+func (opt ReconnectOptions) backoff(attempt int) time.Duration {
+	d := float64(opt.MinBackoff) * math.Pow(opt.Multiplier, float64(attempt-1))
+	if d > float64(opt.MaxBackoff) {
+		d = float64(opt.MaxBackoff)
+	}
+	d *= 1 + opt.Jitter*(rand.Float64()*2-1)
+	return time.Duration(d)
+}
+
+type clientSocket interface {
+	SendMsg(ctx context.Context, msg []byte) error
+	Close(ctx context.Context)
+}
+
+// 客户端连接管理: 断线检测, 退避重连, 断线缓存
+type reconnector[S clientSocket] struct {
+	opt  ReconnectOptions
+	dial func(ctx context.Context, onLost func(ctx context.Context)) (S, error) // 建立连接, 断开时回调onLost
+
+	dialMu  sync.Mutex // 串行建立连接
+	mu      sync.Mutex
+	sock    S
+	gen     uint64 // 连接代数, 旧连接断开不触发重连
+	lostGen uint64 // 最近断开的连接代数
+	down    bool   // 断线中
+	gaveUp  bool   // 已放弃重连
+	closed  bool
+	pending [][]byte // 断线缓存
+
+	lostCh    chan struct{}
+	closeCh   chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func newReconnector[S clientSocket](opt ReconnectOptions, dial func(ctx context.Context, onLost func(ctx context.Context)) (S, error)) *reconnector[S] {
+	if opt.Enable {
+		opt = opt.withDefault()
+	}
+	return &reconnector[S]{
+		opt:     opt,
+		dial:    dial,
+		lostCh:  make(chan struct{}, 1),
+		closeCh: make(chan struct{}),
+	}
+}
+
+// 首次连接, 成功后启动重连协程
+func (rc *reconnector[S]) start(ctx context.Context) error {
+	if _, err := rc.connect(ctx); err != nil {
+		return err
+	}
+	if rc.opt.Enable {
+		rc.wg.Add(1)
+		go rc.loop(ctx)
+	}
+	return nil
+}
+
+// 当前连接(断线中为已断开的连接)
+func (rc *reconnector[S]) current() S {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.sock
+}
+
+// 建立连接并补发断线缓存, live: 补发完成时连接仍有效
+func (rc *reconnector[S]) connect(ctx context.Context) (live bool, err error) {
+	rc.dialMu.Lock()
+	defer rc.dialMu.Unlock()
+
+	rc.mu.Lock()
+	if rc.closed {
+		rc.mu.Unlock()
+		return false, ErrSockClosed
+	}
+	rc.gen++
+	gen := rc.gen
+	rc.mu.Unlock()
+
+	sock, err := rc.dial(ctx, func(ctx context.Context) { rc.lost(ctx, gen) })
+	if err != nil {
+		return false, err
+	}
+
+	rc.mu.Lock()
+	if rc.closed {
+		rc.mu.Unlock()
+		go sock.Close(ctx)
+		return false, ErrSockClosed
+	}
+	rc.sock = sock
+	rc.gaveUp = false
+	rc.mu.Unlock()
+
+	return rc.flush(ctx, sock, gen), nil
+}
+
+// 锁外补发断线缓存(SendMsg可能阻塞), 补发完成前SendMsg继续缓存以保持顺序
+// 返回false: 补发期间连接断开/关闭, 剩余缓存由重连后补发
+func (rc *reconnector[S]) flush(ctx context.Context, sock S, gen uint64) bool {
+	for {
+		rc.mu.Lock()
+		// 补发期间断开(或建立后立即断开)/关闭
+		if gen != rc.gen || rc.lostGen == gen || rc.closed {
+			rc.mu.Unlock()
+			return false
+		}
+		msgs := rc.pending
+		rc.pending = nil
+		if len(msgs) == 0 {
+			rc.down = false
+			rc.mu.Unlock()
+			return true
+		}
+		rc.mu.Unlock()
+
+		dropped := 0
+		var lastErr error
+		for i, msg := range msgs {
+			err := sock.SendMsg(ctx, msg)
+			if err == nil {
+				continue
+			}
+			// 连接已断开(断开回调可能尚未执行), 未发送的消息放回缓存头部
+			if errors.Is(err, ErrSockClosed) || errors.Is(err, ErrSlowConsumer) {
+				rc.mu.Lock()
+				rc.pending = append(msgs[i:len(msgs):len(msgs)], rc.pending...)
+				rc.mu.Unlock()
+				rc.flushFailed(ctx, dropped, lastErr)
+				return false
+			}
+			dropped++
+			lastErr = err
+		}
+		rc.flushFailed(ctx, dropped, lastErr)
+	}
+}
+
+func (rc *reconnector[S]) flushFailed(ctx context.Context, dropped int, err error) {
+	if dropped == 0 {
+		return
+	}
+	xlog.Get(ctx).Warn("Send buffered msg failed.", zap.Int("dropped", dropped), zap.Any("err", err))
+	if rc.opt.OnFlushFailed != nil {
+		rc.opt.OnFlushFailed(ctx, dropped, err)
+	}
+}
+
+// 连接断开(连接回调内, 不可阻塞)
+func (rc *reconnector[S]) lost(ctx context.Context, gen uint64) {
+	rc.mu.Lock()
+	if gen != rc.gen || rc.closed {
+		rc.mu.Unlock()
+		return
+	}
+	rc.lostGen = gen
+	rc.down = true
+	rc.mu.Unlock()
+
+	if !rc.opt.Enable {
+		return
+	}
+	if rc.opt.OnDisconnected != nil {
+		rc.opt.OnDisconnected(ctx)
+	}
+	select {
+	case rc.lostCh <- struct{}{}:
+	default:
+	}
+}
+
+func (rc *reconnector[S]) loop(ctx context.Context) {
+	defer rc.wg.Done()
+	for {
+		select {
+		case <-rc.closeCh:
+			return
+		case <-rc.lostCh:
+		}
+		rc.retry(ctx)
+	}
+}
+
+// 退避重连, 直到成功/放弃/关闭
+func (rc *reconnector[S]) retry(ctx context.Context) {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if rc.opt.MaxAttempts > 0 && attempt > rc.opt.MaxAttempts {
+			rc.giveUp(ctx, err)
+			return
+		}
+		timer := time.NewTimer(rc.opt.backoff(attempt))
+		select {
+		case <-rc.closeCh:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		// 手动重连已恢复
+		if !rc.isDown() {
+			return
+		}
+		var live bool
+		if live, err = rc.connect(ctx); err == nil && live {
+			xlog.Get(ctx).Info("Reconnect success.", zap.Int("attempts", attempt))
+			if rc.opt.OnReconnected != nil {
+				rc.opt.OnReconnected(ctx, attempt)
+			}
+			return
+		}
+		// 补发期间连接断开, 按失败继续重连
+		if err == nil {
+			err = ErrSockClosed
+		}
+		xlog.Get(ctx).Debug("Reconnect failed.", zap.Int("attempt", attempt), zap.Any("err", err))
+		if rc.opt.OnRetryFailed != nil {
+			rc.opt.OnRetryFailed(ctx, attempt, err)
+		}
+	}
+}
+
+func (rc *reconnector[S]) giveUp(ctx context.Context, err error) {
+	rc.mu.Lock()
+	rc.gaveUp = true
+	rc.pending = nil
+	rc.mu.Unlock()
+
+	xlog.Get(ctx).Warn("Reconnect give up.", zap.Int("attempts", rc.opt.MaxAttempts), zap.Any("err", err))
+	if rc.opt.OnGiveUp != nil {
+		rc.opt.OnGiveUp(ctx, err)
+	}
+}
+
+func (rc *reconnector[S]) isDown() bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.down
+}
+
+// 断线中按配置缓存
+func (rc *reconnector[S]) send(ctx context.Context, msg []byte) error {
+	rc.mu.Lock()
+	if rc.closed {
+		rc.mu.Unlock()
+		return ErrSockClosed
+	}
+	if !rc.down {
+		sock := rc.sock
+		rc.mu.Unlock()
+		return sock.SendMsg(ctx, msg)
+	}
+	defer rc.mu.Unlock()
+	if !rc.opt.Enable || rc.opt.BufferMsgs <= 0 || rc.gaveUp {
+		return ErrSockClosed
+	}
+	if len(rc.pending) >= rc.opt.BufferMsgs {
+		return ErrReconnectBufferFull
+	}
+	rc.pending = append(rc.pending, msg)
+	return nil
+}
+
+// 关闭当前连接并重新连接, 失败时交由重连协程处理
+func (rc *reconnector[S]) reconnect(ctx context.Context) error {
+	rc.mu.Lock()
+	if rc.closed {
+		rc.mu.Unlock()
+		return ErrSockClosed
+	}
+	sock := rc.sock
+	rc.gen++
+	rc.down = true
+	rc.mu.Unlock()
+
+	sock.Close(ctx)
+	_, err := rc.connect(ctx)
+	if err != nil && rc.opt.Enable {
+		select {
+		case rc.lostCh <- struct{}{}:
+		default:
+		}
+	}
+	return err
+}
+
+// 停止重连并关闭连接
+func (rc *reconnector[S]) close(ctx context.Context) {
+	rc.mu.Lock()
+	rc.closed = true
+	rc.pending = nil
+	sock := rc.sock
+	rc.mu.Unlock()
+
+	rc.closeOnce.Do(func() { close(rc.closeCh) })
+	rc.wg.Wait()
+	sock.Close(ctx)
+}
@@ -0,0 +1,256 @@
+package xnet_test
+
+import (
+	"context"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTCPAutoReconnect(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9987"
+	recvCh := make(chan string, 8)
+	newSvr := func() *xnet.TCPServer {
+		svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog,
+			OnMsg: xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+				recvCh <- string(arg.Payload)
+				return nil
+			})})
+		if err != nil {
+			panic(err)
+		}
+		return svr
+	}
+	svr := newSvr()
+
+	var disconnected, retryFailed, reconnected int32
+	cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{
+		Addr:         addr,
+		OnConnect:    onConnectSock,
+		OnDisconnect: onDisconnectLog,
+		OnMsg:        echoHandler(),
+		Reconnect: xnet.ReconnectOptions{
+			Enable:         true,
+			MinBackoff:     20 * time.Millisecond,
+			MaxBackoff:     50 * time.Millisecond,
+			BufferMsgs:     2,
+			OnDisconnected: func(ctx context.Context) { atomic.AddInt32(&disconnected, 1) },
+			OnRetryFailed:  func(ctx context.Context, attempt int, err error) { atomic.AddInt32(&retryFailed, 1) },
+			OnReconnected:  func(ctx context.Context, attempts int) { atomic.AddInt32(&reconnected, 1) },
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	// 服务端断开, 断线期间缓存
+	svr.Close(ctx)
+	waitUntil(t, "disconnected", func() bool { return atomic.LoadInt32(&disconnected) == 1 })
+	for i, payload := range []string{"a", "b", "c"} {
+		msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: []byte(payload)})
+		if err != nil {
+			panic(err)
+		}
+		err = cli.SendMsg(ctx, msg)
+		if i < 2 && err != nil || i == 2 && err != xnet.ErrReconnectBufferFull {
+			t.Fatalf("send %v err %v", payload, err)
+		}
+	}
+	waitUntil(t, "retry failed", func() bool { return atomic.LoadInt32(&retryFailed) > 0 })
+
+	// 服务端恢复, 重连后按序发送缓存
+	svr = newSvr()
+	defer svr.Close(ctx)
+	waitUntil(t, "reconnected", func() bool { return atomic.LoadInt32(&reconnected) == 1 })
+	recv := make([]string, 0, 2)
+	for i := 0; i < 2; i++ {
+		select {
+		case payload := <-recvCh:
+			recv = append(recv, payload)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("recv buffered timeout %v", recv)
+		}
+	}
+	if !reflect.DeepEqual(recv, []string{"a", "b"}) {
+		t.Fatalf("recv %v", recv)
+	}
+}
+
+func TestWebsocketReconnectGiveUp(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9988"
+	svr := xnet.NewWSServer(ctx, xnet.WSSvrArgs{Addr: addr, Path: "/", OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	time.Sleep(100 * time.Millisecond)
+
+	giveUpCh := make(chan error, 1)
+	var retryFailed int32
+	cli, err := xnet.NewWSClient(ctx, xnet.WSCliArgs{
+		Addr:         addr,
+		Path:         "/",
+		OnConnect:    onConnectSock,
+		OnDisconnect: onDisconnectLog,
+		OnMsg:        echoHandler(),
+		Reconnect: xnet.ReconnectOptions{
+			Enable:        true,
+			MinBackoff:    10 * time.Millisecond,
+			MaxAttempts:   3,
+			BufferMsgs:    10,
+			OnRetryFailed: func(ctx context.Context, attempt int, err error) { atomic.AddInt32(&retryFailed, 1) },
+			OnGiveUp:      func(ctx context.Context, err error) { giveUpCh <- err },
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	svr.Close(ctx)
+	select {
+	case err := <-giveUpCh:
+		if err == nil || atomic.LoadInt32(&retryFailed) != 3 {
+			t.Fatalf("give up %v %v", err, retryFailed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("give up timeout")
+	}
+	if err := cli.SendMsg(ctx, []byte("data")); err != xnet.ErrSockClosed {
+		t.Fatalf("send after give up %v", err)
+	}
+}
+
+// 补发缓存失败(写队列满)通过OnFlushFailed上报, 其余消息正常送达
+func TestTCPReconnectFlushFailed(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9920"
+	var recv int32
+	newSvr := func() *xnet.TCPServer {
+		svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog,
+			OnMsg: xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+				atomic.AddInt32(&recv, 1)
+				return nil
+			})})
+		if err != nil {
+			panic(err)
+		}
+		return svr
+	}
+	svr := newSvr()
+
+	var disconnected, reconnected, dropped int32
+	count := 50
+	cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{
+		Addr:         addr,
+		OnConnect:    onConnectSock,
+		OnDisconnect: onDisconnectLog,
+		OnMsg:        echoHandler(),
+		WriteQueue:   xnet.WriteQueueOptions{MaxMsgs: 1},
+		Reconnect: xnet.ReconnectOptions{
+			Enable:         true,
+			MinBackoff:     20 * time.Millisecond,
+			MaxBackoff:     50 * time.Millisecond,
+			BufferMsgs:     count,
+			OnDisconnected: func(ctx context.Context) { atomic.AddInt32(&disconnected, 1) },
+			OnReconnected:  func(ctx context.Context, attempts int) { atomic.AddInt32(&reconnected, 1) },
+			OnFlushFailed: func(ctx context.Context, n int, err error) {
+				if err != xnet.ErrMsgOverflow {
+					t.Errorf("flush err %v", err)
+				}
+				atomic.AddInt32(&dropped, int32(n))
+			},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	svr.Close(ctx)
+	waitUntil(t, "disconnected", func() bool { return atomic.LoadInt32(&disconnected) == 1 })
+	msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: []byte("data")})
+	if err != nil {
+		panic(err)
+	}
+	for i := 0; i < count; i++ {
+		if err := cli.SendMsg(ctx, msg); err != nil {
+			t.Fatalf("send %v err %v", i, err)
+		}
+	}
+
+	svr = newSvr()
+	defer svr.Close(ctx)
+	waitUntil(t, "reconnected", func() bool { return atomic.LoadInt32(&reconnected) == 1 })
+	if atomic.LoadInt32(&dropped) == 0 {
+		t.Fatalf("flush failure not reported")
+	}
+	waitUntil(t, "flushed", func() bool { return atomic.LoadInt32(&recv)+atomic.LoadInt32(&dropped) == int32(count) })
+}
+
+// 补发缓存时连接断开(写队列满断开慢连接)不上报重连成功, 缓存保留并继续重连
+func TestTCPReconnectLostDuringFlush(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9934"
+	var accepted int32
+	newSvr := func() *xnet.TCPServer {
+		svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{Addr: addr, OnDisconnect: onDisconnectLog, OnMsg: echoHandler(),
+			OnConnect: func(ctx context.Context, sock xnet.Socket) interface{} {
+				atomic.AddInt32(&accepted, 1)
+				return sock
+			}})
+		if err != nil {
+			panic(err)
+		}
+		return svr
+	}
+	svr := newSvr()
+
+	var disconnected, retryFailed, reconnected int32
+	count := 100
+	cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{
+		Addr:         addr,
+		OnConnect:    onConnectSock,
+		OnDisconnect: onDisconnectLog,
+		OnMsg:        echoHandler(),
+		WriteQueue:   xnet.WriteQueueOptions{MaxMsgs: 1, Policy: xnet.PolicyDisconnect},
+		Reconnect: xnet.ReconnectOptions{
+			Enable:         true,
+			MinBackoff:     20 * time.Millisecond,
+			MaxBackoff:     50 * time.Millisecond,
+			BufferMsgs:     count,
+			OnDisconnected: func(ctx context.Context) { atomic.AddInt32(&disconnected, 1) },
+			OnRetryFailed:  func(ctx context.Context, attempt int, err error) { atomic.AddInt32(&retryFailed, 1) },
+			OnReconnected:  func(ctx context.Context, attempts int) { atomic.AddInt32(&reconnected, 1) },
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	svr.Close(ctx)
+	waitUntil(t, "disconnected", func() bool { return atomic.LoadInt32(&disconnected) == 1 })
+	msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: []byte("data")})
+	if err != nil {
+		panic(err)
+	}
+	for i := 0; i < count; i++ {
+		if err := cli.SendMsg(ctx, msg); err != nil {
+			t.Fatalf("send %v err %v", i, err)
+		}
+	}
+
+	// 每次补发均使写队列溢出断开, 重连多次均不应上报成功
+	svr = newSvr()
+	defer svr.Close(ctx)
+	waitUntil(t, "reconnect retried", func() bool { return atomic.LoadInt32(&accepted) >= 3 })
+	if n := atomic.LoadInt32(&reconnected); n != 0 {
+		t.Fatalf("reconnected %v", n)
+	}
+	if atomic.LoadInt32(&retryFailed) == 0 {
+		t.Fatalf("retry failure not reported")
+	}
+}
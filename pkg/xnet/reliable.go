@@ -0,0 +1,240 @@
+package xnet
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+// udp消息通道(游戏网络常用模型), 开启ReliableOptions后每条消息指定通道发送
+type Channel uint8
+
+const (
+	ChannelUnreliable        Channel = iota // 不可靠: 不保证到达及顺序
+	ChannelReliableUnordered                // 可靠无序: 重传直至确认, 去重后到达即交付
+	ChannelReliableOrdered                  // 可靠有序: 重传直至确认, 按发送顺序交付
+	ChannelSequenced                        // 不可靠有序: 丢弃早于已交付消息的过期数据包
+	channelCount
+)
+
+// 数据包头部: kind(1) | channel(1) | seq(4, 大端)
+const (
+	reliableHeaderSize = 6
+	reliableKindData   = 0
+	reliableKindAck    = 1
+
+	defaultReliableRTO     = 100 * time.Millisecond // 默认重传间隔
+	minReliableRTO         = time.Millisecond       // 重传间隔下限(重传检查间隔为RTO/2)
+	defaultReliableRetries = 30                     // 默认重传次数上限
+	defaultReliableWindow  = 1024                   // 默认每个可靠通道未确认消息数上限
+)
+
+var (
+	ErrReliableDisabled = errors.New("reliable channel disabled")   // 未开启Reliable时使用非Unreliable通道
+	ErrReliableWindow   = errors.New("reliable window full")        // 未确认消息数超过Window
+	ErrReliableTimeout  = errors.New("reliable retransmit timeout") // 重传次数超过MaxRetries
+	ErrReliableHeader   = errors.New("invalid reliable header")     // 数据包头部格式错误
+)
+
+// udp可靠性层配置(零值: 不开启, 数据包直接交由OnMsg), 两端须使用相同配置
+type ReliableOptions struct {
+	Enable     bool
+	RTO        time.Duration // 重传间隔(默认100ms, 最小1ms)
+	MaxRetries int           // 单条消息重传次数上限, 超过断开session(默认30)
+	Window     int           // 每个可靠通道未确认/乱序缓存消息数上限(默认1024)
+}
+
+func (opt ReliableOptions) withDefault() ReliableOptions {
+	if opt.RTO <= 0 {
+		opt.RTO = defaultReliableRTO
+	} else if opt.RTO < minReliableRTO {
+		opt.RTO = minReliableRTO
+	}
+	if opt.MaxRetries <= 0 {
+		opt.MaxRetries = defaultReliableRetries
+	}
+	if opt.Window <= 0 {
+		opt.Window = defaultReliableWindow
+	}
+	return opt
+}
+
+// 可靠性层计数
+type ReliableStats struct {
+	Retransmits uint64 // 重传次数
+	Duplicates  uint64 // 重复数据包(已交付或已缓存)
+	Stale       uint64 // Sequenced通道丢弃的过期数据包
+	Pending     int    // 未确认消息数
+}
+
+type reliablePending struct {
+	data    []byte // 含头部
+	sentAt  time.Time
+	retries int
+}
+
+// 单个通道收发状态
+type reliableChannel struct {
+	sendSeq  uint32
+	pending  map[uint32]*reliablePending // 未确认消息(可靠通道)
+	recvNext uint32                      // 可靠通道: 之前的消息均已交付; Sequenced: 可接收的最小序号
+	recvBuf  map[uint32][]byte           // 可靠通道已收到的后续消息(有序: 待交付, 无序: 已交付标记)
+}
+
+// session可靠性层, 线程安全(发送方任意协程, 接收及重传为handler协程)
+type reliable struct {
+	opt   ReliableOptions
+	mu    sync.Mutex
+	chans [channelCount]reliableChannel
+	stats ReliableStats
+}
+
+// 未开启返回nil
+func newReliable(opt ReliableOptions) *reliable {
+	if !opt.Enable {
+		return nil
+	}
+	r := &reliable{opt: opt.withDefault()}
+	for i := range r.chans {
+		r.chans[i].pending = make(map[uint32]*reliablePending)
+		r.chans[i].recvBuf = make(map[uint32][]byte)
+	}
+	return r
+}
+
+func isReliable(ch Channel) bool {
+	return ch == ChannelReliableUnordered || ch == ChannelReliableOrdered
+}
+
+// a早于b(序号回绕)
+func seqBefore(a, b uint32) bool {
+	return int32(a-b) < 0
+}
+
+func reliableHeader(kind byte, ch Channel, seq uint32, size int) []byte {
+	data := make([]byte, reliableHeaderSize, reliableHeaderSize+size)
+	data[0] = kind
+	data[1] = byte(ch)
+	binary.BigEndian.PutUint32(data[2:], seq)
+	return data
+}
+
+// 封包, 可靠通道记录为待确认
+func (r *reliable) pack(ch Channel, msg []byte, now time.Time) ([]byte, error) {
+	if ch >= channelCount {
+		return nil, ErrReliableHeader
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := &r.chans[ch]
+	if isReliable(ch) && len(c.pending) >= r.opt.Window {
+		return nil, ErrReliableWindow
+	}
+	data := append(reliableHeader(reliableKindData, ch, c.sendSeq, len(msg)), msg...)
+	if isReliable(ch) {
+		c.pending[c.sendSeq] = &reliablePending{data: data, sentAt: now}
+	}
+	c.sendSeq++
+	return data, nil
+}
+
+// 解包, 返回可交付的消息及需回复的ack(nil: 无需回复)
+func (r *reliable) unpack(data []byte) ([][]byte, []byte, error) {
+	if len(data) < reliableHeaderSize || Channel(data[1]) >= channelCount {
+		return nil, nil, ErrReliableHeader
+	}
+	kind, ch, seq, msg := data[0], Channel(data[1]), binary.BigEndian.Uint32(data[2:]), data[reliableHeaderSize:]
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := &r.chans[ch]
+	switch kind {
+	case reliableKindAck:
+		delete(c.pending, seq)
+		return nil, nil, nil
+	case reliableKindData:
+	default:
+		return nil, nil, ErrReliableHeader
+	}
+
+	switch ch {
+	case ChannelUnreliable:
+		return [][]byte{msg}, nil, nil
+	case ChannelSequenced:
+		if seqBefore(seq, c.recvNext) {
+			r.stats.Stale++
+			return nil, nil, nil
+		}
+		c.recvNext = seq + 1
+		return [][]byte{msg}, nil, nil
+	}
+
+	// 可靠通道: 超出窗口不确认(等待重传), 重复数据包重新确认(ack可能丢失)
+	if seq-c.recvNext >= uint32(r.opt.Window) && !seqBefore(seq, c.recvNext) {
+		return nil, nil, nil
+	}
+	ack := reliableHeader(reliableKindAck, ch, seq, 0)
+	if _, ok := c.recvBuf[seq]; ok || seqBefore(seq, c.recvNext) {
+		r.stats.Duplicates++
+		return nil, ack, nil
+	}
+
+	var msgs [][]byte
+	if ch == ChannelReliableUnordered {
+		msgs = append(msgs, msg)
+		msg = nil
+	}
+	c.recvBuf[seq] = msg
+	for {
+		m, ok := c.recvBuf[c.recvNext]
+		if !ok {
+			break
+		}
+		if ch == ChannelReliableOrdered {
+			msgs = append(msgs, m)
+		}
+		delete(c.recvBuf, c.recvNext)
+		c.recvNext++
+	}
+	return msgs, ack, nil
+}
+
+// 到期未确认需重传的数据包, 超过重传次数返回ErrReliableTimeout
+func (r *reliable) expired(now time.Time) ([][]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var datas [][]byte
+	for i := range r.chans {
+		for _, p := range r.chans[i].pending {
+			if now.Sub(p.sentAt) < r.opt.RTO {
+				continue
+			}
+			if p.retries >= r.opt.MaxRetries {
+				return nil, ErrReliableTimeout
+			}
+			p.retries++
+			p.sentAt = now
+			datas = append(datas, p.data)
+		}
+	}
+	r.stats.Retransmits += uint64(len(datas))
+	return datas, nil
+}
+
+func (r *reliable) snapshot() ReliableStats {
+	if r == nil {
+		return ReliableStats{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := r.stats
+	for i := range r.chans {
+		stats.Pending += len(r.chans[i].pending)
+	}
+	return stats
+}
@@ -0,0 +1,204 @@
+package xnet_test
+
+import (
+	"context"
+	"fmt"
+	"gotu/pkg/xactor"
+	"gotu/pkg/xlatency"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type lossyState struct {
+	name string
+	cli  *xnet.UDPClient
+}
+
+// 丢包代理(xlatency模拟): 双向按loss%丢弃数据包并附加随机延迟(乱序)
+func newLossyProxy(ctx context.Context, addr, target string, loss, latency uint32) (*xnet.UDPServer, error) {
+	return xnet.NewUDPServer(ctx, xnet.UDPSvrArgs{
+		Addr:    addr,
+		Timeout: 10,
+		OnConnect: func(ctx context.Context, sock xnet.Socket) interface{} {
+			name := fmt.Sprintf("lossy-%v", sock.RemoteAddr())
+			if _, err := xlatency.NewLatencyActor(ctx, xlatency.LatencyMockArgs{Name: name, InLoss: loss, InLatency: latency, OutLoss: loss, OutLatency: latency}); err != nil {
+				panic(err)
+			}
+			cli, err := xnet.NewUDPClient(ctx, xnet.UDPCliArgs{Addr: target, Timeout: 10, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog,
+				OnMsg: func(ctx context.Context, state interface{}, msg []byte) (int, error) {
+					xactor.AsyncRequest(ctx, name, &xlatency.RecvFromSvrReq{Msg: msg})
+					return 0, nil
+				}})
+			if err != nil {
+				panic(err)
+			}
+			if _, err := xactor.SyncRequest[xlatency.RegisterSendToCliReq, xlatency.RegisterSendToCliResp](ctx, name, &xlatency.RegisterSendToCliReq{
+				SendToCli: func(ctx context.Context, b []byte) { _ = sock.SendMsg(ctx, b) },
+			}); err != nil {
+				panic(err)
+			}
+			if _, err := xactor.SyncRequest[xlatency.RegisterSendToSvrReq, xlatency.RegisterSendToSvrResp](ctx, name, &xlatency.RegisterSendToSvrReq{
+				SendToSvr: func(ctx context.Context, b []byte) { _ = cli.SendMsg(ctx, b) },
+			}); err != nil {
+				panic(err)
+			}
+			return &lossyState{name: name, cli: cli}
+		},
+		OnDisconnect: func(ctx context.Context, state interface{}) {
+			s := state.(*lossyState)
+			s.cli.Close(ctx)
+			if actor, err := xactor.GetActor(s.name); err == nil {
+				actor.Close(ctx)
+			}
+		},
+		OnMsg: func(ctx context.Context, state interface{}, msg []byte) (int, error) {
+			xactor.AsyncRequest(ctx, state.(*lossyState).name, &xlatency.RecvFromCliReq{Msg: msg})
+			return 0, nil
+		},
+	})
+}
+
+// 经丢包代理发送, 可靠通道全部交付(有序通道保持顺序), sequenced通道不交付过期消息
+func TestUDPReliable(t *testing.T) {
+	ctx := context.Background()
+	addr, proxyAddr := "127.0.0.1:9918", "127.0.0.1:9917"
+	reliable := xnet.ReliableOptions{Enable: true, RTO: 30 * time.Millisecond}
+
+	var mu sync.Mutex
+	recv := make(map[string][]int)
+	svr, err := xnet.NewUDPServer(ctx, xnet.UDPSvrArgs{Addr: addr, Timeout: 10, Reliable: reliable, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog,
+		OnMsg: xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+			var name string
+			var i int
+			if _, err := fmt.Sscanf(string(arg.Payload), "%s %d", &name, &i); err != nil {
+				return err
+			}
+			mu.Lock()
+			recv[name] = append(recv[name], i)
+			mu.Unlock()
+			return nil
+		})})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	proxy, err := newLossyProxy(ctx, proxyAddr, addr, 20, 20)
+	if err != nil {
+		panic(err)
+	}
+	defer proxy.Close(ctx)
+
+	cli, err := xnet.NewUDPClient(ctx, xnet.UDPCliArgs{Addr: proxyAddr, Timeout: 10, Reliable: reliable, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	channels := map[string]xnet.Channel{"unreliable": xnet.ChannelUnreliable, "unordered": xnet.ChannelReliableUnordered, "ordered": xnet.ChannelReliableOrdered, "sequenced": xnet.ChannelSequenced}
+	count := 200
+	for i := 0; i < count; i++ {
+		for name, ch := range channels {
+			msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: []byte(fmt.Sprintf("%s %d", name, i))})
+			if err != nil {
+				panic(err)
+			}
+			if err := cli.SendChannel(ctx, ch, msg); err != nil {
+				panic(err)
+			}
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+
+	waitUntil(t, "reliable delivered", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(recv["ordered"]) >= count && len(recv["unordered"]) >= count
+	})
+	waitUntil(t, "reliable acked", func() bool { return cli.ReliableStats().Pending == 0 })
+
+	mu.Lock()
+	defer mu.Unlock()
+	unordered := append([]int(nil), recv["unordered"]...)
+	sort.Ints(unordered)
+	for i := 0; i < count; i++ {
+		if recv["ordered"][i] != i || unordered[i] != i {
+			t.Fatalf("reliable msg %v ordered %v unordered %v", i, recv["ordered"][i], unordered[i])
+		}
+	}
+	if len(recv["ordered"]) != count || len(recv["unordered"]) != count {
+		t.Fatalf("reliable duplicated ordered %v unordered %v", len(recv["ordered"]), len(recv["unordered"]))
+	}
+	for i := 1; i < len(recv["sequenced"]); i++ {
+		if recv["sequenced"][i] <= recv["sequenced"][i-1] {
+			t.Fatalf("sequenced stale msg %v after %v", recv["sequenced"][i], recv["sequenced"][i-1])
+		}
+	}
+	if cli.ReliableStats().Retransmits == 0 {
+		t.Fatalf("no retransmit unreliable %v", len(recv["unreliable"]))
+	}
+	t.Logf("unreliable %v sequenced %v stats %+v", len(recv["unreliable"]), len(recv["sequenced"]), cli.ReliableStats())
+}
+
+// 未开启Reliable仅支持不可靠通道
+func TestUDPReliableDisabled(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9919"
+	svr, err := xnet.NewUDPServer(ctx, xnet.UDPSvrArgs{Addr: addr, Timeout: 10, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	recvCh := make(chan []byte, 1)
+	cli, err := xnet.NewUDPClient(ctx, xnet.UDPCliArgs{Addr: addr, Timeout: 10, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: recvPayload(recvCh)})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: []byte("plain")})
+	if err != nil {
+		panic(err)
+	}
+	if err := cli.SendChannel(ctx, xnet.ChannelReliableOrdered, msg); err != xnet.ErrReliableDisabled {
+		t.Fatalf("send reliable err %v", err)
+	}
+	if err := cli.SendChannel(ctx, xnet.ChannelUnreliable, msg); err != nil {
+		panic(err)
+	}
+	expectPayload(t, "plain", recvCh, []byte("plain"))
+}
+
+// 极小RTO按下限处理, 不影响收发
+func TestUDPReliableTinyRTO(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9930"
+	reliable := xnet.ReliableOptions{Enable: true, RTO: 1}
+	svr, err := xnet.NewUDPServer(ctx, xnet.UDPSvrArgs{Addr: addr, Timeout: 10, Reliable: reliable, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	recvCh := make(chan []byte, 1)
+	cli, err := xnet.NewUDPClient(ctx, xnet.UDPCliArgs{Addr: addr, Timeout: 10, Reliable: reliable, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: recvPayload(recvCh)})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: []byte("tiny")})
+	if err != nil {
+		panic(err)
+	}
+	if err := cli.SendChannel(ctx, xnet.ChannelReliableOrdered, msg); err != nil {
+		panic(err)
+	}
+	expectPayload(t, "tiny", recvCh, []byte("tiny"))
+	waitUntil(t, "reliable acked", func() bool { return cli.ReliableStats().Pending == 0 })
+}
@@ -0,0 +1,54 @@
+package xnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+var ErrReusePortUnsupported = errors.New("reuseport not supported") // 当前系统不支持SO_REUSEPORT
+
+// 监听配置, n>1时开启SO_REUSEPORT
+func listenConfig(n int) net.ListenConfig {
+	if n <= 1 {
+		return net.ListenConfig{}
+	}
+	return net.ListenConfig{Control: reusePortControl}
+}
+
+// 同一地址打开n个tcp监听, 端口为0时后续监听使用首个监听分配的端口
+func listenTCP(ctx context.Context, addr string, n int) ([]*net.TCPListener, error) {
+	lc := listenConfig(n)
+	listeners := make([]*net.TCPListener, 0, n)
+	for i := 0; i < n || i == 0; i++ {
+		l, err := lc.Listen(ctx, tcpNetwork, addr)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, fmt.Errorf("listen addr[%s] failed %w", addr, err)
+		}
+		listeners = append(listeners, l.(*net.TCPListener))
+		addr = l.Addr().String()
+	}
+	return listeners, nil
+}
+
+// 同一地址打开n个udp socket, 内核按四元组分发数据包
+func listenUDP(ctx context.Context, addr string, n int) ([]*net.UDPConn, error) {
+	lc := listenConfig(n)
+	conns := make([]*net.UDPConn, 0, n)
+	for i := 0; i < n || i == 0; i++ {
+		c, err := lc.ListenPacket(ctx, udpNetwork, addr)
+		if err != nil {
+			for _, c := range conns {
+				_ = c.Close()
+			}
+			return nil, fmt.Errorf("listen addr[%s] failed %w", addr, err)
+		}
+		conns = append(conns, c.(*net.UDPConn))
+		addr = c.LocalAddr().String()
+	}
+	return conns, nil
+}
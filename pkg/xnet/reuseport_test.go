@@ -0,0 +1,131 @@
+package xnet_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPReusePort(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9912"
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{Addr: addr, Listeners: 4, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if errors.Is(err, xnet.ErrReusePortUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	for i := 0; i < 8; i++ {
+		recvCh := make(chan []byte, 1)
+		cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: recvPayload(recvCh)})
+		if err != nil {
+			panic(err)
+		}
+		payload := []byte(fmt.Sprintf("cli %v", i))
+		msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: payload})
+		if err != nil {
+			panic(err)
+		}
+		if err := cli.SendMsg(ctx, msg); err != nil {
+			panic(err)
+		}
+		expectPayload(t, string(payload), recvCh, payload)
+		cli.Close(ctx)
+	}
+}
+
+// 多个socket接收, 同一客户端的数据包始终由同一session处理并回复
+func TestUDPReusePort(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9913"
+	svr, err := xnet.NewUDPServer(ctx, xnet.UDPSvrArgs{Addr: addr, Timeout: 10, Listeners: 4, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if errors.Is(err, xnet.ErrReusePortUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	clients := 8
+	for i := 0; i < clients; i++ {
+		recvCh := make(chan []byte, 10)
+		cli, err := xnet.NewUDPClient(ctx, xnet.UDPCliArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: recvPayload(recvCh)})
+		if err != nil {
+			panic(err)
+		}
+		defer cli.Close(ctx)
+		for j := 0; j < 5; j++ {
+			payload := []byte(fmt.Sprintf("cli %v msg %v", i, j))
+			msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: payload})
+			if err != nil {
+				panic(err)
+			}
+			if err := cli.SendMsg(ctx, msg); err != nil {
+				panic(err)
+			}
+			expectPayload(t, string(payload), recvCh, payload)
+		}
+	}
+	if stats := svr.LimitStats(); stats.Conns != clients {
+		t.Fatalf("sessions %v", stats.Conns)
+	}
+}
+
+// 本地udp并发收发: 多条流同时发送并等待回包, 对比单socket与SO_REUSEPORT多socket
+func BenchmarkUDPFlood(b *testing.B) {
+	for _, listeners := range []int{1, 4} {
+		b.Run(fmt.Sprintf("listeners-%d", listeners), func(b *testing.B) {
+			benchmarkUDPFlood(b, listeners)
+		})
+	}
+}
+
+func benchmarkUDPFlood(b *testing.B, listeners int) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9914"
+	svr, err := xnet.NewUDPServer(ctx, xnet.UDPSvrArgs{Addr: addr, Timeout: 10, Listeners: listeners, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if errors.Is(err, xnet.ErrReusePortUnsupported) {
+		b.Skip(err)
+	}
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: make([]byte, 64)})
+	if err != nil {
+		panic(err)
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(msg)))
+	runFlows(b, func() (func(n int) int, func()) {
+		conn, err := net.Dial("udp", addr)
+		if err != nil {
+			panic(err)
+		}
+		buf := make([]byte, 1024)
+		roundTrip := func(n int) int {
+			for i := 0; i < n; i++ {
+				_, _ = conn.Write(msg)
+			}
+			_ = conn.SetReadDeadline(time.Now().Add(benchUDPTimeout))
+			for i := 0; i < n; i++ {
+				if _, err := conn.Read(buf); err != nil {
+					return i
+				}
+			}
+			return n
+		}
+		return roundTrip, func() { conn.Close() }
+	})
+}
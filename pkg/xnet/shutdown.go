@@ -0,0 +1,77 @@
+package xnet
+
+import (
+	"context"
+	"gotu/pkg/xlog"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 支持优雅关闭的连接
+// 关闭流程: drain(停止读取) => 处理中的消息完成 => 刷新写缓存 => 关闭连接
+type drainSocket interface {
+	RemoteAddr() net.Addr
+	drain(ctx context.Context) // 停止读取(非阻塞)
+	abort(ctx context.Context) // 强制关闭(非阻塞)
+	wait()                     // 等待连接关闭
+}
+
+// 优雅关闭全部连接, ctx超时后强制关闭剩余连接并等待其退出(上限abortWaitTimeout)
+// 返回被强制关闭的连接地址
+func drainSockets(ctx context.Context, socks []drainSocket) ([]net.Addr, error) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	closed := make(map[drainSocket]bool, len(socks))
+
+	for _, sock := range socks {
+		sock.drain(ctx)
+
+		wg.Add(1)
+		go func(sock drainSocket) {
+			defer wg.Done()
+			sock.wait()
+
+			mu.Lock()
+			closed[sock] = true
+			mu.Unlock()
+		}(sock)
+	}
+
+	doneCh := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(doneCh)
+	}()
+
+	select {
+	case <-doneCh:
+		return nil, nil
+	case <-ctx.Done():
+	}
+
+	forced := make([]net.Addr, 0)
+	mu.Lock()
+	for _, sock := range socks {
+		if !closed[sock] {
+			sock.abort(ctx)
+			forced = append(forced, sock.RemoteAddr())
+		}
+	}
+	mu.Unlock()
+
+	// 强制关闭后等待读写协程退出, 避免调用方关闭监听时连接仍在运行
+	select {
+	case <-doneCh:
+	case <-time.After(abortWaitTimeout):
+		xlog.Get(ctx).Warn("Shutdown wait aborted sockets timeout.", zap.Duration("timeout", abortWaitTimeout))
+	}
+
+	if len(forced) == 0 {
+		return nil, nil
+	}
+	xlog.Get(ctx).Warn("Shutdown force close sockets.", zap.Any("addrs", forced))
+	return forced, ctx.Err()
+}
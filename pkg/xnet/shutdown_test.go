@@ -0,0 +1,195 @@
+package xnet_test
+
+import (
+	"context"
+	"errors"
+	"gotu/pkg/xlog"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 慢处理handler: 通知开始处理, 等待release后回包
+func slowEchoHandler(startCh chan struct{}, releaseCh chan struct{}) xnet.OnHandlerOnce {
+	return xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+		startCh <- struct{}{}
+		<-releaseCh
+		msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: arg.Payload})
+		if err != nil {
+			return err
+		}
+		return arg.State.(xnet.Socket).SendMsg(ctx, msg)
+	})
+}
+
+func recvHandler(recvCh chan string) xnet.OnHandlerOnce {
+	return xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+		xlog.Get(ctx).Debug("Cli recv msg", zap.String("msg", string(arg.Payload)))
+		recvCh <- string(arg.Payload)
+		return nil
+	})
+}
+
+// 发送一条消息, 等待服务端开始处理后执行shutdown, 处理中的消息回包不丢失
+func testShutdown(t *testing.T, send func(msg []byte) error, shutdown func(ctx context.Context) ([]net.Addr, error), startCh, releaseCh chan struct{}, recvCh chan string) {
+	ctx := context.Background()
+	msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: []byte("in-flight")})
+	if err != nil {
+		panic(err)
+	}
+	if err := send(msg); err != nil {
+		panic(err)
+	}
+	<-startCh
+
+	type result struct {
+		forced []net.Addr
+		err    error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		sctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		forced, err := shutdown(sctx)
+		resultCh <- result{forced: forced, err: err}
+	}()
+
+	// shutdown等待处理中的handler
+	time.Sleep(100 * time.Millisecond)
+	close(releaseCh)
+
+	select {
+	case data := <-recvCh:
+		if data != "in-flight" {
+			t.Fatalf("recv %v", data)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("in-flight response lost")
+	}
+	r := <-resultCh
+	if r.err != nil || len(r.forced) != 0 {
+		t.Fatalf("shutdown forced %v err %v", r.forced, r.err)
+	}
+}
+
+func TestTCPShutdown(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9971"
+	startCh, releaseCh, recvCh := make(chan struct{}, 1), make(chan struct{}), make(chan string, 1)
+
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: slowEchoHandler(startCh, releaseCh)})
+	if err != nil {
+		panic(err)
+	}
+	cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: recvHandler(recvCh)})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	testShutdown(t, func(msg []byte) error { return cli.SendMsg(ctx, msg) }, svr.Shutdown, startCh, releaseCh, recvCh)
+
+	// 关闭后拒绝新连接
+	if _, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		t.Fatalf("dial after shutdown success")
+	}
+	svr.Close(ctx)
+}
+
+func TestTCPShutdownForce(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9975"
+	startCh, releaseCh, recvCh := make(chan struct{}, 1), make(chan struct{}), make(chan string, 1)
+
+	disconnectCh := make(chan struct{})
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: func(ctx context.Context, state interface{}) { close(disconnectCh) },
+		OnMsg: slowEchoHandler(startCh, releaseCh)})
+	if err != nil {
+		panic(err)
+	}
+	cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: recvHandler(recvCh)})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: []byte("blocked")})
+	if err != nil {
+		panic(err)
+	}
+	if err := cli.SendMsg(ctx, msg); err != nil {
+		panic(err)
+	}
+	<-startCh
+
+	// handler阻塞超过deadline, 连接被强制关闭, 返回前等待连接退出
+	sctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	time.AfterFunc(400*time.Millisecond, func() { close(releaseCh) })
+	forced, err := svr.Shutdown(sctx)
+	if !errors.Is(err, context.DeadlineExceeded) || len(forced) != 1 {
+		t.Fatalf("shutdown forced %v err %v", forced, err)
+	}
+	select {
+	case <-disconnectCh:
+	default:
+		t.Fatalf("shutdown returned before forced socket exit")
+	}
+}
+
+func TestKCPShutdown(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9974"
+	startCh, releaseCh, recvCh := make(chan struct{}, 1), make(chan struct{}), make(chan string, 1)
+
+	svr, err := xnet.NewKCPServer(ctx, xnet.KCPServerArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: slowEchoHandler(startCh, releaseCh), IsInline: true})
+	if err != nil {
+		panic(err)
+	}
+	cli, err := xnet.NewKCPClient(ctx, xnet.KCPClientArgs{Addr: addr, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: recvHandler(recvCh), IsInline: true})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	testShutdown(t, func(msg []byte) error { return cli.SendMsg(ctx, msg) }, svr.Shutdown, startCh, releaseCh, recvCh)
+}
+
+func TestWebsocketShutdown(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9972"
+	startCh, releaseCh, recvCh := make(chan struct{}, 1), make(chan struct{}), make(chan string, 1)
+
+	svr := xnet.NewWSServer(ctx, xnet.WSSvrArgs{Addr: addr, Path: "/", OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: slowEchoHandler(startCh, releaseCh)})
+	time.Sleep(100 * time.Millisecond)
+
+	cli, err := xnet.NewWSClient(ctx, xnet.WSCliArgs{Addr: addr, Path: "/", OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: recvHandler(recvCh)})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	testShutdown(t, func(msg []byte) error { return cli.SendMsg(ctx, msg) }, svr.Shutdown, startCh, releaseCh, recvCh)
+}
+
+func TestUDPShutdown(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9973"
+	startCh, releaseCh, recvCh := make(chan struct{}, 1), make(chan struct{}), make(chan string, 1)
+
+	svr, err := xnet.NewUDPServer(ctx, xnet.UDPSvrArgs{Addr: addr, Timeout: 10, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: slowEchoHandler(startCh, releaseCh)})
+	if err != nil {
+		panic(err)
+	}
+	cli, err := xnet.NewUDPClient(ctx, xnet.UDPCliArgs{Addr: addr, Timeout: 10, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: recvHandler(recvCh)})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	testShutdown(t, func(msg []byte) error { return cli.SendMsg(ctx, msg) }, svr.Shutdown, startCh, releaseCh, recvCh)
+}
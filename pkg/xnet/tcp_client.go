@@ -2,7 +2,9 @@ package xnet
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
+	"time"
 )
 
 type TCPCliArgs struct {
@@ -10,53 +12,104 @@ type TCPCliArgs struct {
 	OnMsg        OnHandlerOnce
 	OnConnect    OnConnect
 	OnDisconnect OnDisconnect
+	TLSConfig    *tls.Config       // 非空开启tls(双向认证: Certificates)
+	WriteQueue   WriteQueueOptions // 写队列策略(队列满时的处理)
+	Metrics      Metrics           // 指标上报(nil:不上报)
+	Heartbeat    HeartbeatOptions  // 心跳及空闲检测(开启ping须配置Codec)
+	Reconnect    ReconnectOptions  // 自动重连
+	Options      Options           // 连接参数(buffer/超时/NoDelay)
 }
 
 type TCPClient struct {
-	sock   *TCPSocket
+	rc     *reconnector[*TCPSocket]
 	bufMgr *bufferManager
 	arg    *TCPCliArgs
 }
 
 func NewTCPClient(ctx context.Context, arg TCPCliArgs) (*TCPClient, error) {
-	bufMgr := newBufferManager()
+	if err := arg.Heartbeat.checkTCP(); err != nil {
+		return nil, err
+	}
+	arg.Options = arg.Options.withDefault()
+	bufMgr := newBufferManager(arg.Options.ReadBufferSize)
 
 	cli := &TCPClient{bufMgr: bufMgr, arg: &arg}
-	if err := cli.newSocket(ctx); err != nil {
+	cli.rc = newReconnector(arg.Reconnect, cli.newSocket)
+	if err := cli.rc.start(ctx); err != nil {
 		return nil, err
 	}
 	return cli, nil
 }
 
-func (cli *TCPClient) newSocket(ctx context.Context) error {
+func (cli *TCPClient) newSocket(ctx context.Context, onLost func(ctx context.Context)) (*TCPSocket, error) {
 	tcpAddr, err := net.ResolveTCPAddr(tcpNetwork, cli.arg.Addr)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	conn, err := net.DialTCP(tcpNetwork, nil, tcpAddr)
+	rawConn, err := net.DialTCP(tcpNetwork, nil, tcpAddr)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	var conn net.Conn = rawConn
+	if cli.arg.TLSConfig != nil {
+		if conn, err = cli.handshake(ctx, rawConn); err != nil {
+			_ = rawConn.Close()
+			return nil, err
+		}
+	}
+	return newTCPSocket(ctx, TCPSocketArgs{
+		conn:       conn,
+		rawConn:    rawConn,
+		readBuffer: cli.bufMgr.newReadBuffer(),
+		writeQueue: cli.arg.WriteQueue,
+		metrics:    cli.arg.Metrics,
+		heartbeat:  cli.arg.Heartbeat,
+		options:    cli.arg.Options,
+		onMsg:      cli.arg.OnMsg,
+		onConnect:  cli.arg.OnConnect,
+		onDisconnect: func(ctx context.Context, state interface{}) {
+			cli.arg.OnDisconnect(ctx, state)
+			onLost(ctx)
+		},
+		releaseFn: func(ctx context.Context, ts *TCPSocket) {},
+	}), nil
+}
+
+// tls握手
+func (cli *TCPClient) handshake(ctx context.Context, rawConn *net.TCPConn) (*tls.Conn, error) {
+	config := cli.arg.TLSConfig
+	if config.ServerName == "" && !config.InsecureSkipVerify {
+		// 同tls.Dial, 未指定ServerName时使用地址host
+		host, _, err := net.SplitHostPort(cli.arg.Addr)
+		if err != nil {
+			return nil, err
+		}
+		config = config.Clone()
+		config.ServerName = host
+	}
+	tlsConn := tls.Client(rawConn, config)
+	hsCtx, cancel := context.WithTimeout(ctx, cli.arg.Options.TLSHandshakeTimeout)
+	defer cancel()
+	if err := tlsConn.HandshakeContext(hsCtx); err != nil {
+		return nil, err
 	}
-	cli.sock = newTCPSocket(ctx, TCPSocketArgs{
-		conn:           conn,
-		readBufferPool: cli.bufMgr.newBufferPool(),
-		onMsg:          cli.arg.OnMsg,
-		onConnect:      cli.arg.OnConnect,
-		onDisconnect:   cli.arg.OnDisconnect,
-		releaseFn:      func(ctx context.Context, ts *TCPSocket) {},
-	})
-	return nil
+	return tlsConn, nil
 }
 
 func (cli *TCPClient) Reconnect(ctx context.Context) error {
-	cli.sock.Close(ctx)
-	return cli.newSocket(ctx)
+	return cli.rc.reconnect(ctx)
 }
 
 func (cli *TCPClient) Close(ctx context.Context) {
-	cli.sock.Close(ctx)
+	cli.rc.close(ctx)
+}
+
+// 最近一次心跳往返时间
+func (cli *TCPClient) RTT() time.Duration {
+	return cli.rc.current().RTT()
 }
 
+// 自动重连开启时, 断线期间按配置缓存
 func (cli *TCPClient) SendMsg(ctx context.Context, msg []byte) error {
-	return cli.sock.SendMsg(ctx, msg)
+	return cli.rc.send(ctx, msg)
 }
@@ -2,7 +2,7 @@ package xnet
 
 import (
 	"context"
-	"fmt"
+	"crypto/tls"
 	"gotu/pkg/xlog"
 	"net"
 	"sync"
@@ -15,52 +15,86 @@ type TCPSvrArgs struct {
 	OnMsg        OnHandlerOnce
 	OnConnect    OnConnect
 	OnDisconnect OnDisconnect
+	OnAccept     OnAccept          // 准入检查(AccessList.OnAccept), nil: 不检查
+	TLSConfig    *tls.Config       // 非空开启tls(双向认证: ClientAuth + ClientCAs)
+	WriteQueue   WriteQueueOptions // 写队列策略(队列满时的处理)
+	Metrics      Metrics           // 指标上报(nil:不上报)
+	Heartbeat    HeartbeatOptions  // 心跳及空闲检测(开启ping须配置Codec)
+	Options      Options           // 连接参数(buffer/超时/NoDelay)
+	Limit        LimitOptions      // 连接数及速率限制
+	Proxy        ProxyOptions      // PROXY protocol(负载均衡后获取客户端真实地址)
+	Listeners    int               // 监听数量, >1时开启SO_REUSEPORT, 每个监听独立accept协程
 }
 
 type TCPServer struct {
-	wg       sync.WaitGroup
-	listener *net.TCPListener
-	closeCh  chan struct{}
+	wg        sync.WaitGroup
+	listeners []*net.TCPListener
+	closeCh   chan struct{}
+	stopOnce  sync.Once
 
 	onMsg        OnHandlerOnce
 	onConnect    OnConnect
 	onDisconnect OnDisconnect
+	onAccept     OnAccept
 
-	bufMgr *bufferManager
+	tlsConfig       *tls.Config
+	handshakeCtx    context.Context // 关闭时取消未完成的tls握手
+	handshakeCancel context.CancelFunc
+
+	bufMgr       *bufferManager
+	writeQueue   WriteQueueOptions
+	queueCounter *writeQueueCounter
+	metrics      Metrics
+	heartbeat    HeartbeatOptions
+	options      Options
+	limiter      *connLimiter
+	proxy        ProxyOptions
 
 	mu      sync.Mutex
 	sockets map[*TCPSocket]bool
 }
 
 func NewTCPServer(ctx context.Context, arg TCPSvrArgs) (*TCPServer, error) {
-	tcpAddr, err := net.ResolveTCPAddr(tcpNetwork, arg.Addr)
-	if err != nil {
+	if err := arg.Heartbeat.checkTCP(); err != nil {
 		return nil, err
 	}
-	listener, err := net.ListenTCP(tcpNetwork, tcpAddr)
+	listeners, err := listenTCP(ctx, arg.Addr, arg.Listeners)
 	if err != nil {
-		return nil, fmt.Errorf("listen addr[%s] failed %w", arg.Addr, err)
+		return nil, err
 	}
+	options := arg.Options.withDefault()
 	svr := &TCPServer{
-		listener:     listener,
+		listeners:    listeners,
 		closeCh:      make(chan struct{}),
 		sockets:      make(map[*TCPSocket]bool),
-		bufMgr:       newBufferManager(),
+		bufMgr:       newBufferManager(options.ReadBufferSize),
 		onMsg:        arg.OnMsg,
 		onConnect:    arg.OnConnect,
 		onDisconnect: arg.OnDisconnect,
+		onAccept:     arg.OnAccept,
+		tlsConfig:    arg.TLSConfig,
+		writeQueue:   arg.WriteQueue,
+		queueCounter: &writeQueueCounter{},
+		metrics:      arg.Metrics,
+		heartbeat:    arg.Heartbeat,
+		options:      options,
+		limiter:      newConnLimiter(arg.Limit),
+		proxy:        arg.Proxy.withDefault(),
 	}
-	svr.wg.Add(1)
-	go svr.accept(ctx)
-	xlog.Get(ctx).Info("Start listen success.", zap.String("addr", arg.Addr))
+	svr.handshakeCtx, svr.handshakeCancel = context.WithCancel(ctx)
+	for _, listener := range listeners {
+		svr.wg.Add(1)
+		go svr.accept(ctx, listener)
+	}
+	xlog.Get(ctx).Info("Start listen success.", zap.String("addr", arg.Addr), zap.Bool("tls", arg.TLSConfig != nil), zap.Int("listeners", len(listeners)))
 	return svr, nil
 }
 
-func (svr *TCPServer) accept(ctx context.Context) {
+func (svr *TCPServer) accept(ctx context.Context, listener *net.TCPListener) {
 	defer svr.wg.Done()
 
 	for {
-		conn, err := svr.listener.AcceptTCP()
+		conn, err := listener.AcceptTCP()
 
 		// 监听关闭检测
 		select {
@@ -74,32 +108,132 @@ func (svr *TCPServer) accept(ctx context.Context) {
 			xlog.Get(ctx).Warn("Accept tcp failed.", zap.Any("err", err))
 			continue
 		}
-		s := newTCPSocket(ctx, TCPSocketArgs{
-			conn:           conn,
-			readBufferPool: svr.bufMgr.newBufferPool(),
-			onMsg:          svr.onMsg,
-			onConnect:      svr.onConnect,
-			onDisconnect:   svr.onDisconnect,
-			releaseFn:      svr.delSocket,
-		})
-		svr.addSocket(ctx, s)
+
+		if svr.tlsConfig != nil || svr.proxy.ProxyProtocol {
+			// PROXY头读取及tls握手放在独立协程, 避免阻塞accept
+			svr.wg.Add(1)
+			go svr.handshake(ctx, conn)
+			continue
+		}
+		if svr.accepted(ctx, conn) {
+			svr.newSocket(ctx, conn, conn)
+		}
+	}
+}
+
+// 准入检查及连接数限制(使用真实地址), 拒绝时关闭连接
+func (svr *TCPServer) accepted(ctx context.Context, conn net.Conn) bool {
+	if !admit(ctx, svr.onAccept, conn.RemoteAddr()) || !svr.limiter.acquire(ctx, conn.RemoteAddr()) {
+		_ = conn.Close()
+		return false
+	}
+	return true
+}
+
+// 读取PROXY头, tls握手
+func (svr *TCPServer) handshake(ctx context.Context, conn *net.TCPConn) {
+	defer svr.wg.Done()
+
+	var c net.Conn = conn
+	if svr.proxy.ProxyProtocol {
+		pc := newProxyConn(conn, svr.proxy)
+		if err := readProxyConn(svr.handshakeCtx, pc); err != nil {
+			xlog.Get(ctx).Warn("Read proxy header failed.", zap.Any("remote", conn.RemoteAddr()), zap.Any("err", err))
+			_ = conn.Close()
+			return
+		}
+		c = pc
+	}
+	if !svr.accepted(ctx, c) {
+		return
+	}
+
+	if svr.tlsConfig != nil {
+		tlsConn := tls.Server(c, svr.tlsConfig)
+		hsCtx, cancel := context.WithTimeout(svr.handshakeCtx, svr.options.TLSHandshakeTimeout)
+		defer cancel()
+		if err := tlsConn.HandshakeContext(hsCtx); err != nil {
+			xlog.Get(ctx).Warn("Tls handshake failed.", zap.Any("remote", c.RemoteAddr()), zap.Any("err", err))
+			_ = conn.Close()
+			svr.limiter.release(c.RemoteAddr())
+			return
+		}
+		c = tlsConn
 	}
+	svr.newSocket(ctx, c, conn)
+}
+
+func (svr *TCPServer) newSocket(ctx context.Context, conn net.Conn, rawConn streamConn) {
+	s := newTCPSocket(ctx, TCPSocketArgs{
+		conn:         conn,
+		rawConn:      rawConn,
+		readBuffer:   svr.bufMgr.newReadBuffer(),
+		writeQueue:   svr.writeQueue,
+		queueCounter: svr.queueCounter,
+		metrics:      svr.metrics,
+		heartbeat:    svr.heartbeat,
+		options:      svr.options,
+		rate:         svr.limiter.newRate(conn.RemoteAddr()),
+		onMsg:        svr.onMsg,
+		onConnect:    svr.onConnect,
+		onDisconnect: svr.onDisconnect,
+		releaseFn:    svr.delSocket,
+	})
+	svr.addSocket(ctx, s)
+}
+
+// 停止接收新连接
+func (svr *TCPServer) stopAccept() {
+	svr.stopOnce.Do(func() {
+		// 停止accept与tls握手, 避免关闭过程中新增连接
+		close(svr.closeCh)
+		for _, listener := range svr.listeners {
+			_ = listener.Close()
+		}
+		svr.handshakeCancel()
+	})
+	svr.wg.Wait()
 }
 
 func (svr *TCPServer) Close(ctx context.Context) {
+	svr.stopAccept()
+
 	svr.mu.Lock()
 	for sock := range svr.sockets {
 		sock.Close(ctx)
 	}
 	svr.mu.Unlock()
 
-	close(svr.closeCh)
-	_ = svr.listener.Close()
-	svr.wg.Wait()
-
 	xlog.Get(ctx).Info("TCP server stop.")
 }
 
+// 优雅关闭: 停止accept, 等待处理中的消息完成并刷新写缓存后关闭连接
+// ctx超时后强制关闭剩余连接, 返回被强制关闭的连接地址
+func (svr *TCPServer) Shutdown(ctx context.Context) ([]net.Addr, error) {
+	svr.stopAccept()
+
+	svr.mu.Lock()
+	socks := make([]drainSocket, 0, len(svr.sockets))
+	for sock := range svr.sockets {
+		socks = append(socks, sock)
+	}
+	svr.mu.Unlock()
+
+	forced, err := drainSockets(ctx, socks)
+	xlog.Get(ctx).Info("TCP server shutdown.", zap.Int("sockets", len(socks)), zap.Int("forced", len(forced)))
+	return forced, err
+}
+
+// 写队列计数(所有连接汇总)
+func (svr *TCPServer) WriteQueueStats() WriteQueueStats {
+	return svr.queueCounter.snapshot()
+}
+
+// 连接数及拒绝计数
+func (svr *TCPServer) LimitStats() LimitStats {
+	return svr.limiter.stats()
+}
+
 func (svr *TCPServer) addSocket(ctx context.Context, s *TCPSocket) {
 	svr.mu.Lock()
 	defer svr.mu.Unlock()
@@ -111,4 +245,5 @@ func (svr *TCPServer) delSocket(ctx context.Context, s *TCPSocket) {
 	defer svr.mu.Unlock()
 
 	delete(svr.sockets, s)
+	svr.limiter.release(s.RemoteAddr())
 }
@@ -2,7 +2,8 @@ package xnet
 
 import (
 	"context"
-	"fmt"
+	"crypto/tls"
+	"errors"
 	"gotu/pkg/xcommon"
 	"gotu/pkg/xlog"
 	"io"
@@ -13,20 +14,36 @@ import (
 )
 
 type TCPSocketArgs struct {
-	conn           *net.TCPConn
-	readBufferPool *bufferPool
-	onMsg          OnHandlerOnce
-	onConnect      OnConnect
-	onDisconnect   OnDisconnect
-	releaseFn      func(ctx context.Context, ts *TCPSocket)
+	conn         net.Conn   // 读写连接(tcp/tls/unix)
+	rawConn      streamConn // 原始tcp/unix连接
+	readBuffer   *readBuffer
+	writeQueue   WriteQueueOptions
+	queueCounter *writeQueueCounter
+	metrics      Metrics
+	heartbeat    HeartbeatOptions
+	options      Options      // 已填充默认值
+	rate         *rateLimiter // 速率限制(nil:不限制)
+	onMsg        OnHandlerOnce
+	onConnect    OnConnect
+	onDisconnect OnDisconnect
+	releaseFn    func(ctx context.Context, ts *TCPSocket)
 }
 
 type TCPSocket struct {
-	conn           *net.TCPConn
-	readBufferPool *bufferPool
-	readCaches     []byte
-	writeCh        chan []byte   // 写消息缓存
-	closeCh        chan struct{} // 关闭channel
+	conn         net.Conn
+	rawConn      streamConn
+	readBuffer   *readBuffer         // 读缓存(零拷贝交由onMsg)
+	writeQueue   *writeQueue[[]byte] // 写消息缓存
+	closeCh      chan struct{}       // 关闭channel
+	reporter     *socketReporter
+	hb           *heartbeat
+	writeTimeout time.Duration
+	rate         *rateLimiter
+
+	vecConn    net.Conn    // 支持writev的原始连接(nil:合并为单个帧写入, 如tls)
+	vecBufs    net.Buffers // 批量写入缓存(复用)
+	batchBytes int         // 单次写入字节上限
+	batchMsgs  int         // 单次写入消息数上限
 
 	onMsg        OnHandlerOnce
 	onConnect    OnConnect
@@ -37,25 +54,43 @@ type TCPSocket struct {
 }
 
 func newTCPSocket(ctx context.Context, arg TCPSocketArgs) *TCPSocket {
-	// arg.conn.SetNoDelay(true)
-	// arg.conn.SetReadBuffer
-	// arg.conn.SetWriteBuffer
+	arg.options.applyConn(ctx, arg.rawConn)
 
 	s := &TCPSocket{
-		conn:           arg.conn,
-		readBufferPool: arg.readBufferPool,
-		readCaches:     make([]byte, 0),
-		writeCh:        make(chan []byte, writeChanLimit),
-		closeCh:        make(chan struct{}),
-		onMsg:          arg.onMsg,
-		onConnect:      arg.onConnect,
-		onDisconnect:   arg.onDisconnect,
-		releaseFn:      arg.releaseFn,
+		conn:         arg.conn,
+		rawConn:      arg.rawConn,
+		readBuffer:   arg.readBuffer,
+		closeCh:      make(chan struct{}),
+		onMsg:        arg.onMsg,
+		onConnect:    arg.onConnect,
+		onDisconnect: arg.onDisconnect,
+		releaseFn:    arg.releaseFn,
+		writeTimeout: arg.options.WriteTimeout,
+		rate:         arg.rate,
+		vecConn:      vectorConn(arg.conn),
+		batchBytes:   arg.options.WriteBatchBytes,
+		batchMsgs:    arg.options.WriteBatchMsgs,
+	}
+	s.writeQueue = newWriteQueue(arg.writeQueue, arg.queueCounter, writeChanLimit, msgSize, s.closeCh)
+	s.reporter = newSocketReporter(arg.metrics, s, 2)
+	s.writeQueue.depthFn = s.reporter.queueDepth
+	s.hb = newHeartbeat(arg.heartbeat, arg.options.ReadTimeout, s, s.closeCh)
+	s.hb.sendPing = s.sendPing
+	s.hb.abort = func(reason CloseReason) {
+		s.reporter.setCloseReason(reason)
+		s.abort(ctx)
 	}
 
 	s.wg.Add(2)
 	go s.readLoop(ctx)
 	go s.writeLoop(ctx)
+	if s.hb.enabled() {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done(ctx)
+			s.hb.loop(ctx)
+		}()
+	}
 	return s
 }
 
@@ -67,45 +102,65 @@ func (sock *TCPSocket) readLoop(ctx context.Context) {
 		if readErr != nil {
 			xlog.Get(ctx).Warn("Read loop exit with error.", zap.Any("err", readErr))
 		}
+		sock.reporter.setReadErr(readErr)
 		close(sock.closeCh)
 		sock.onDisconnect(ctx, state)
+		sock.readBuffer.release()
+		sock.reporter.loopDone()
 	}()
 	defer sock.wg.Done(ctx)
 
 	for {
-		if err := sock.conn.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
+		if err := sock.conn.SetReadDeadline(sock.hb.deadline()); err != nil {
 			readErr = err
 			break
 		}
 
-		// 获取buffer, 读取buffer
-		bytes := sock.readBufferPool.get()
-		n, err := sock.conn.Read(bytes)
+		// 直接读入读缓存, 未处理数据之后
+		n, err := sock.conn.Read(sock.readBuffer.space())
 		if err != nil {
 			if err != io.EOF {
 				readErr = err
 			}
 			break
 		}
+		sock.readBuffer.written(n)
 
-		// 归还剩余buffer
-		sock.readBufferPool.put(bytes[n:])
-		// 合并cache
-		sock.readCaches = append(sock.readCaches, bytes[0:n]...)
+		sock.reporter.bytesIn(n)
+		if !sock.rate.allowBytes(ctx, n) {
+			sock.reporter.setCloseReason(CloseReasonRateLimit)
+			return
+		}
 		isKeepCache := false
 		for !isKeepCache {
+			// 心跳帧不交由OnMsg处理
+			if n, err := sock.onHeartbeat(); err != nil {
+				readErr = err
+				sock.reporter.setCloseReason(CloseReasonHandlerError)
+				return
+			} else if n > 0 {
+				sock.readBuffer.consume(n)
+				continue
+			}
+
 			// do handler
-			reqCount, err := sock.onMsg(ctx, state, sock.readCaches)
+			begin := time.Now()
+			reqCount, err := sock.onMsg(ctx, state, sock.readBuffer.data())
 			if err != nil {
 				readErr = err
+				sock.reporter.setCloseReason(CloseReasonHandlerError)
 				return
 			}
 			if reqCount == 0 {
 				isKeepCache = true
 			} else {
-				// 归还已处理buffer
-				sock.readBufferPool.put(sock.readCaches[0:reqCount])
-				sock.readCaches = sock.readCaches[reqCount:]
+				sock.reporter.msgHandled(begin)
+				sock.hb.active()
+				sock.readBuffer.consume(reqCount)
+				if !sock.rate.allowMsg(ctx, 1) {
+					sock.reporter.setCloseReason(CloseReasonRateLimit)
+					return
+				}
 			}
 		}
 	}
@@ -116,55 +171,121 @@ func (sock *TCPSocket) writeLoop(ctx context.Context) {
 	defer func() {
 		if writeErr != nil {
 			xlog.Get(ctx).Warn("Write loop exit with error", zap.Any("err", writeErr))
+			sock.reporter.setCloseReason(CloseReasonWriteError)
 		}
 		_ = sock.conn.Close()
 		sock.releaseFn(ctx, sock)
+		sock.reporter.loopDone()
 	}()
 
 	defer sock.wg.Done(ctx)
 
-	waitMsg := func() ([]byte, bool) {
+	var datas [][]byte
+	isClosed := false
+	for !isClosed {
 		// 阻塞并等待数据
-		var msg []byte
-		ret := false
 		select {
-		case data := <-sock.writeCh:
-			msg = append(msg, data...)
+		case <-sock.writeQueue.wait():
 		case <-sock.closeCh:
-			ret = true
+			isClosed = true
 		}
 
-		// 非阻塞获取数据
-	loop:
-		for {
-			select {
-			case data := <-sock.writeCh:
-				msg = append(msg, data...)
-			default:
-				break loop
+		// 非阻塞获取全部数据, 复用上一批的切片
+		datas = sock.writeQueue.swap(datas)
+		if err := sock.writeBatches(datas); err != nil {
+			writeErr = err
+			break
+		}
+	}
+}
+
+// 按字节/消息数上限分批写入, 单批至少一条消息
+func (sock *TCPSocket) writeBatches(datas [][]byte) error {
+	for len(datas) > 0 {
+		count, size := 0, 0
+		for count < len(datas) && count < sock.batchMsgs {
+			if count > 0 && size+len(datas[count]) > sock.batchBytes {
+				break
+			}
+			size += len(datas[count])
+			count++
+		}
+		if size > 0 {
+			if err := sock.writev(datas[:count], size); err != nil {
+				return err
 			}
+			sock.reporter.bytesOut(size, count)
 		}
-		return msg, ret
+		datas = datas[count:]
 	}
+	return nil
+}
 
-	isClosed := false
-	for !isClosed {
-		var msg []byte
-		msg, isClosed = waitMsg()
-		if len(msg) == 0 {
-			break
+// 批量写入: 原始连接使用writev, 否则拷贝至帧缓存单次写入(避免tls逐条加密)
+func (sock *TCPSocket) writev(batch [][]byte, size int) error {
+	if sock.vecConn == nil || len(batch) == 1 {
+		if len(batch) == 1 {
+			return sock.write(batch[0])
 		}
-		if err := sock.write(msg); err != nil {
-			writeErr = err
-			break
+		frame := getFrame(size)
+		defer putFrame(frame)
+		for _, data := range batch {
+			*frame = append(*frame, data...)
 		}
+		return sock.write(*frame)
 	}
+
+	if err := sock.conn.SetWriteDeadline(time.Now().Add(sock.writeTimeout)); err != nil {
+		return err
+	}
+	// WriteTo会移动切片头, 使用副本并在写入后清空引用
+	sock.vecBufs = append(sock.vecBufs[:0], batch...)
+	bufs := sock.vecBufs
+	_, err := bufs.WriteTo(sock.vecConn)
+	for i := range sock.vecBufs {
+		sock.vecBufs[i] = nil
+	}
+	return err
+}
+
+// 支持writev的连接(PROXY协议连接写入不受影响, 取其原始连接)
+func vectorConn(conn net.Conn) net.Conn {
+	switch c := unwrapConn(conn).(type) {
+	case *net.TCPConn, *net.UnixConn:
+		return c
+	}
+	return nil
+}
+
+// 处理缓存头部的心跳帧, 返回消耗字节数(0: 非心跳帧)
+func (sock *TCPSocket) onHeartbeat() (int, error) {
+	codec := sock.hb.opt.Codec
+	if codec == nil {
+		return 0, nil
+	}
+	kind, sendAt, n, err := codec.Parse(sock.readBuffer.data())
+	if err != nil {
+		return 0, err
+	}
+	switch kind {
+	case HeartbeatPing:
+		_ = sock.writeQueue.forcePush(codec.Pong(sendAt))
+	case HeartbeatPong:
+		sock.hb.pong(sendAt)
+	default:
+		return 0, nil
+	}
+	return n, nil
+}
+
+func (sock *TCPSocket) sendPing(sendAt int64) error {
+	return sock.writeQueue.forcePush(sock.hb.opt.Codec.Ping(sendAt))
 }
 
 // 写数据
 func (sock *TCPSocket) write(msg []byte) error {
 	for {
-		if err := sock.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		if err := sock.conn.SetWriteDeadline(time.Now().Add(sock.writeTimeout)); err != nil {
 			return err
 		}
 
@@ -181,22 +302,43 @@ func (sock *TCPSocket) write(msg []byte) error {
 }
 
 func (sock *TCPSocket) SendMsg(ctx context.Context, msg []byte) error {
-	select {
-	case sock.writeCh <- msg:
-		return nil
-	case <-sock.closeCh:
-		return fmt.Errorf("sock already close")
-	default:
-		return fmt.Errorf("msg overflow")
+	err := sock.writeQueue.push(ctx, msg)
+	if errors.Is(err, ErrSlowConsumer) {
+		xlog.Get(ctx).Warn("Disconnect slow consumer.", zap.Any("remote", sock.RemoteAddr()))
+		sock.reporter.setCloseReason(CloseReasonSlowConsumer)
+		sock.abort(ctx)
 	}
+	return err
 }
 
 // close =》 read loop => closeCh =》write loop
 func (sock *TCPSocket) Close(ctx context.Context) {
-	sock.conn.CloseRead()
+	sock.reporter.setCloseReason(CloseReasonLocal)
+	// tls连接同样关闭底层读, tls读取EOF后退出
+	sock.rawConn.CloseRead()
+	sock.wg.Wait()
+}
+
+// 停止读取, 处理中的消息完成后刷新写缓存并关闭
+func (sock *TCPSocket) drain(ctx context.Context) {
+	sock.reporter.setCloseReason(CloseReasonShutdown)
+	_ = sock.rawConn.CloseRead()
+}
+
+// 强制关闭, 丢弃未发送数据
+func (sock *TCPSocket) abort(ctx context.Context) {
+	_ = sock.rawConn.Close()
+}
+
+func (sock *TCPSocket) wait() {
 	sock.wg.Wait()
 }
 
+// 最近一次心跳往返时间(未开启ping或未收到pong时为0)
+func (sock *TCPSocket) RTT() time.Duration {
+	return sock.hb.RTT()
+}
+
 func (sock *TCPSocket) RemoteAddr() net.Addr {
 	return sock.conn.RemoteAddr()
 }
@@ -204,3 +346,11 @@ func (sock *TCPSocket) RemoteAddr() net.Addr {
 func (sock *TCPSocket) LocalAddr() net.Addr {
 	return sock.conn.LocalAddr()
 }
+
+// tls连接状态(非tls连接返回false)
+func (sock *TCPSocket) ConnectionState() (tls.ConnectionState, bool) {
+	if tlsConn, ok := sock.conn.(*tls.Conn); ok {
+		return tlsConn.ConnectionState(), true
+	}
+	return tls.ConnectionState{}, false
+}
@@ -96,3 +96,77 @@ func TestTCP(t *testing.T) {
 	wg.Wait()
 	cli.Close(ctx)
 }
+
+// 小读缓存下连续发送不同长度消息, 覆盖前移与扩容
+func TestTCPReadBuffer(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9909"
+	options := xnet.Options{ReadBufferSize: 64}
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{Addr: addr, Options: options, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	recvCh := make(chan []byte, 100)
+	cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{Addr: addr, Options: options, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: recvPayload(recvCh)})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	payloads := make([][]byte, 0, 100)
+	for i := 0; i < 100; i++ {
+		payload := []byte(fmt.Sprintf("%03d:%s", i, make([]byte, i*7%300)))
+		msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: payload})
+		if err != nil {
+			panic(err)
+		}
+		if err := cli.SendMsg(ctx, msg); err != nil {
+			panic(err)
+		}
+		payloads = append(payloads, payload)
+	}
+	for i, payload := range payloads {
+		expectPayload(t, fmt.Sprintf("msg %v", i), recvCh, payload)
+	}
+}
+
+// 批量写入按消息数/字节上限拆分, 单条超过字节上限时单独写入
+func TestTCPWriteBatch(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9911"
+	options := xnet.Options{WriteBatchMsgs: 3, WriteBatchBytes: 256}
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{Addr: addr, Options: options, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	recvCh := make(chan []byte, 100)
+	metrics := xnet.NewMemMetrics()
+	cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{Addr: addr, Options: options, Metrics: metrics, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: recvPayload(recvCh)})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	payloads := make([][]byte, 0, 100)
+	for i := 0; i < 100; i++ {
+		payload := []byte(fmt.Sprintf("%03d:%s", i, make([]byte, i*13%500)))
+		msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: payload})
+		if err != nil {
+			panic(err)
+		}
+		if err := cli.SendMsg(ctx, msg); err != nil {
+			panic(err)
+		}
+		payloads = append(payloads, payload)
+	}
+	for i, payload := range payloads {
+		expectPayload(t, fmt.Sprintf("msg %v", i), recvCh, payload)
+	}
+	if snap := metrics.Snapshot(); snap.MsgsOut != 100 {
+		t.Fatalf("msgs out %v", snap.MsgsOut)
+	}
+}
@@ -0,0 +1,235 @@
+package xnet_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"gotu/pkg/xlog"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"math/big"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 测试证书: 自签名ca, 签发server/client证书
+type testCerts struct {
+	pool   *x509.CertPool
+	server tls.Certificate
+	client tls.Certificate
+}
+
+func newTestCerts() (*testCerts, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	caTpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gotu-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTpl, caTpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := x509.ParseCertificate(caDer)
+	if err != nil {
+		return nil, err
+	}
+
+	issue := func(serial int64, cn string, usage x509.ExtKeyUsage) (tls.Certificate, error) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		tpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: cn},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			DNSNames:     []string{"localhost"},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tpl, ca, &key.PublicKey, caKey)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+	}
+
+	certs := &testCerts{pool: x509.NewCertPool()}
+	certs.pool.AddCert(ca)
+	if certs.server, err = issue(2, "gotu-server", x509.ExtKeyUsageServerAuth); err != nil {
+		return nil, err
+	}
+	if certs.client, err = issue(3, "gotu-client", x509.ExtKeyUsageClientAuth); err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+func newTLSEchoServer(ctx context.Context, addr string, config *tls.Config, connects *int32) (*xnet.TCPServer, error) {
+	return xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{
+		Addr:      addr,
+		TLSConfig: config,
+		OnConnect: func(ctx context.Context, sock xnet.Socket) interface{} {
+			atomic.AddInt32(connects, 1)
+			if cs, ok := sock.(*xnet.TCPSocket).ConnectionState(); ok && len(cs.PeerCertificates) > 0 {
+				xlog.Get(ctx).Debug("Svr tls connect", zap.String("peer", cs.PeerCertificates[0].Subject.CommonName))
+			}
+			return &State{sock: sock}
+		},
+		OnDisconnect: func(ctx context.Context, state interface{}) {
+			xlog.Get(ctx).Debug("Svr disconnect")
+		},
+		OnMsg: xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+			xlog.Get(ctx).Debug("Svr recv msg", zap.String("msg", string(arg.Payload)))
+			s := arg.State.(*State)
+			msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: arg.Payload})
+			if err != nil {
+				return err
+			}
+			return s.sock.SendMsg(ctx, msg)
+		}),
+	})
+}
+
+func newTLSEchoClient(ctx context.Context, addr string, config *tls.Config, wg *sync.WaitGroup, disconnect chan struct{}) (*xnet.TCPClient, error) {
+	return xnet.NewTCPClient(ctx, xnet.TCPCliArgs{
+		Addr:      addr,
+		TLSConfig: config,
+		OnConnect: func(ctx context.Context, sock xnet.Socket) interface{} {
+			return nil
+		},
+		OnDisconnect: func(ctx context.Context, state interface{}) {
+			xlog.Get(ctx).Debug("Cli disconnect")
+			if disconnect != nil {
+				close(disconnect)
+			}
+		},
+		OnMsg: xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+			defer wg.Done()
+			xlog.Get(ctx).Debug("Cli recv msg", zap.String("msg", string(arg.Payload)))
+			return nil
+		}),
+	})
+}
+
+func TestTCPTLS(t *testing.T) {
+	ctx := context.Background()
+	certs, err := newTestCerts()
+	if err != nil {
+		panic(err)
+	}
+
+	var wg sync.WaitGroup
+	var connects int32
+	addr := "127.0.0.1:9981"
+	svr, err := newTLSEchoServer(ctx, addr, &tls.Config{Certificates: []tls.Certificate{certs.server}}, &connects)
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	cli, err := newTLSEchoClient(ctx, addr, &tls.Config{RootCAs: certs.pool}, &wg, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if i > 0 {
+			if err := cli.Reconnect(ctx); err != nil {
+				panic(err)
+			}
+		}
+		msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: []byte(fmt.Sprintf("cli data %v", i))})
+		if err != nil {
+			panic(err)
+		}
+		wg.Add(1)
+		if err := cli.SendMsg(ctx, msg); err != nil {
+			panic(err)
+		}
+		wg.Wait()
+	}
+	cli.Close(ctx)
+
+	if n := atomic.LoadInt32(&connects); n != 10 {
+		t.Fatalf("server connects %v, want 10", n)
+	}
+
+	// 未信任证书的客户端握手失败
+	if _, err := newTLSEchoClient(ctx, addr, &tls.Config{}, &wg, nil); err == nil {
+		t.Fatalf("untrusted server certificate accepted")
+	}
+}
+
+func TestTCPMutualTLS(t *testing.T) {
+	ctx := context.Background()
+	certs, err := newTestCerts()
+	if err != nil {
+		panic(err)
+	}
+
+	var wg sync.WaitGroup
+	var connects int32
+	addr := "127.0.0.1:9982"
+	svr, err := newTLSEchoServer(ctx, addr, &tls.Config{
+		Certificates: []tls.Certificate{certs.server},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    certs.pool,
+	}, &connects)
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	// 携带客户端证书
+	cli, err := newTLSEchoClient(ctx, addr, &tls.Config{RootCAs: certs.pool, Certificates: []tls.Certificate{certs.client}}, &wg, nil)
+	if err != nil {
+		panic(err)
+	}
+	msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: []byte("mtls data")})
+	if err != nil {
+		panic(err)
+	}
+	wg.Add(1)
+	if err := cli.SendMsg(ctx, msg); err != nil {
+		panic(err)
+	}
+	wg.Wait()
+	cli.Close(ctx)
+
+	// 未携带客户端证书, tls1.3客户端握手先完成, 服务端校验失败后断开
+	disconnect := make(chan struct{})
+	anon, err := newTLSEchoClient(ctx, addr, &tls.Config{RootCAs: certs.pool}, &wg, disconnect)
+	if err == nil {
+		select {
+		case <-disconnect:
+		case <-time.After(3 * time.Second):
+			t.Fatalf("client without certificate not disconnected")
+		}
+		anon.Close(ctx)
+	}
+
+	if n := atomic.LoadInt32(&connects); n != 1 {
+		t.Fatalf("server connects %v, want 1", n)
+	}
+}
@@ -0,0 +1,73 @@
+package xnet
+
+import (
+	"context"
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// 批量收发(linux: recvmmsg/sendmmsg, 其他系统每次单个数据包), ipv4/ipv6.PacketConn均满足
+type batchConn interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+	WriteBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+// 按本地地址族选择ipv4/ipv6
+func newBatchConn(conn *net.UDPConn) batchConn {
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && addr.IP.To4() != nil {
+		return ipv4.NewPacketConn(conn)
+	}
+	return ipv6.NewPacketConn(conn)
+}
+
+// 批量读取, 批量buf循环复用, 交由onMsg(session异步处理)的数据包按实际长度Retain复制
+func (sock *UDPSocket) readBatch(ctx context.Context) error {
+	msgs := make([]ipv4.Message, sock.batch)
+	for i := range msgs {
+		msgs[i].Buffers = [][]byte{make([]byte, sock.bufSize)}
+	}
+	for {
+		n, err := sock.bconn.ReadBatch(msgs, 0)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			msg := &msgs[i]
+			if addr, ok := msg.Addr.(*net.UDPAddr); ok {
+				sock.onMsg(ctx, sock, Retain(msg.Buffers[0][:msg.N]), addr)
+			}
+		}
+	}
+}
+
+// 批量写入, 每次最多batch个数据包, 部分写入时继续发送剩余数据包
+func (sock *UDPSocket) writeBatch(datagrams []*udpDatagram) error {
+	for len(datagrams) > 0 {
+		count := len(datagrams)
+		if count > sock.batch {
+			count = sock.batch
+		}
+		msgs := sock.sendMsgs[:count]
+		for i, datagram := range datagrams[:count] {
+			msgs[i].Buffers[0] = datagram.msg
+			if sock.isServer {
+				msgs[i].Addr = datagram.addr
+			}
+		}
+		for len(msgs) > 0 {
+			n, err := sock.bconn.WriteBatch(msgs, 0)
+			if err != nil {
+				return err
+			}
+			msgs = msgs[n:]
+		}
+		for i, datagram := range datagrams[:count] {
+			datagram.reporter.bytesOut(len(datagram.msg), 1)
+			sock.sendMsgs[i].Buffers[0], sock.sendMsgs[i].Addr = nil, nil
+		}
+		datagrams = datagrams[count:]
+	}
+	return nil
+}
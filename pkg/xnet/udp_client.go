@@ -17,6 +17,11 @@ type UDPCliArgs struct {
 	OnMsg        OnHandlerOnce
 	OnConnect    OnConnect
 	OnDisconnect OnDisconnect
+	WriteQueue   WriteQueueOptions // 写队列策略(队列满时的处理)
+	Metrics      Metrics           // 指标上报(nil:不上报)
+	Options      Options           // 连接参数(数据包上限/系统缓冲区)
+	Batch        int               // 单次批量收发数据包数量(linux: recvmmsg/sendmmsg), <=1: 逐个收发
+	Reliable     ReliableOptions   // 可靠性层(通道模式, 服务端须相同配置)
 }
 
 type UDPClient struct {
@@ -49,6 +54,16 @@ func (cli *UDPClient) SendMsg(ctx context.Context, msg []byte) error {
 	return cli.biudp.sendMsg(ctx, msg)
 }
 
+// 指定通道发送(须开启Reliable)
+func (cli *UDPClient) SendChannel(ctx context.Context, ch Channel, msg []byte) error {
+	return cli.biudp.session.SendChannel(ctx, ch, msg)
+}
+
+// 可靠性层计数
+func (cli *UDPClient) ReliableStats() ReliableStats {
+	return cli.biudp.session.ReliableStats()
+}
+
 func (cli *UDPClient) Close(ctx context.Context) {
 	cli.biudp.close(ctx)
 }
@@ -73,7 +88,7 @@ func newBuiltInUDP(ctx context.Context, arg UDPCliArgs) (*builtInUDP, error) {
 		return nil, err
 	}
 	biudp := &builtInUDP{closeCh: make(chan struct{})}
-	sock := NewUDPSocket(ctx, UDPSocketArgs{isServer: false, conn: conn, onMsg: biudp.udpOnMsg})
+	sock := NewUDPSocket(ctx, UDPSocketArgs{isServer: false, conn: conn, onMsg: biudp.udpOnMsg, writeQueue: arg.WriteQueue, options: arg.Options.withDefault(), batch: arg.Batch})
 	biudp.sock = sock
 
 	subCtx, cancel := context.WithCancel(ctx)
@@ -86,6 +101,8 @@ func newBuiltInUDP(ctx context.Context, arg UDPCliArgs) (*builtInUDP, error) {
 		onDisconnect: arg.OnDisconnect,
 		sendMsg:      biudp.sock.sendMsg,
 		now:          time.Now().Unix(),
+		metrics:      arg.Metrics,
+		reliable:     arg.Reliable,
 	})
 	biudp.session = session
 
@@ -111,6 +128,7 @@ loop:
 		}
 
 		if biudp.session.getActiveAt() < time.Now().Unix()-int64(timeout) {
+			biudp.session.reporter.setCloseReason(CloseReasonTimeout)
 			biudp.forceClose(ctx)
 			xlog.Get(ctx).Warn("UDP session timeout", zap.Any("id", addrToString(biudp.session.remoteAddr())))
 			break
@@ -118,14 +136,14 @@ loop:
 	}
 }
 
-func (biudp *builtInUDP) udpOnMsg(ctx context.Context, msg []byte, addr *net.UDPAddr) {
+func (biudp *builtInUDP) udpOnMsg(ctx context.Context, sock *UDPSocket, msg []byte, addr *net.UDPAddr) {
 	if err := biudp.session.recvMsg(msg, time.Now().Unix()); err != nil {
 		xlog.Get(ctx).Warn("Session on msg failed.", zap.Any("err", err))
 	}
 }
 
 func (biudp *builtInUDP) sendMsg(ctx context.Context, msg []byte) error {
-	return biudp.sock.sendMsg(ctx, &udpDatagram{msg: msg, addr: biudp.session.remoteAddr()})
+	return biudp.session.SendMsg(ctx, msg)
 }
 
 func (biudp *builtInUDP) close(ctx context.Context) {
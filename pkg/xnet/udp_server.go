@@ -18,29 +18,40 @@ type UDPSvrArgs struct {
 	OnMsg        OnHandlerOnce
 	OnConnect    OnConnect
 	OnDisconnect OnDisconnect
+	OnAccept     OnAccept          // 准入检查(AccessList.OnAccept), nil: 不检查
+	WriteQueue   WriteQueueOptions // 写队列策略(队列满时的处理)
+	Metrics      Metrics           // 指标上报(nil:不上报)
+	Options      Options           // 连接参数(数据包上限/系统缓冲区)
+	Limit        LimitOptions      // 连接(session)数及速率限制
+	Listeners    int               // socket数量, >1时开启SO_REUSEPORT, 每个socket独立读写协程
+	Batch        int               // 单次批量收发数据包数量(linux: recvmmsg/sendmmsg), <=1: 逐个收发
+	Reliable     ReliableOptions   // 可靠性层(通道模式, 客户端须相同配置)
 }
 
 type UDPServer struct {
 	onMsg        OnHandlerOnce
 	onConnect    OnConnect
 	onDisconnect OnDisconnect
+	onAccept     OnAccept
 
-	sock  atomic.Value
-	local net.Addr
+	socks        []*UDPSocket // 内核按四元组分发, session固定使用首个数据包所在socket回复
+	local        net.Addr
+	queueCounter *writeQueueCounter
+	metrics      Metrics
+	limiter      *connLimiter
+	reliable     ReliableOptions
 
 	mu       sync.Mutex
 	sessions map[string]*UDPSession
+	closed   int32 // 关闭后丢弃新数据包
 
-	closeCh chan struct{}
-	wg      xcommon.WaitGroup
+	closeCh  chan struct{}
+	stopOnce sync.Once
+	wg       xcommon.WaitGroup
 }
 
 func NewUDPServer(ctx context.Context, arg UDPSvrArgs) (*UDPServer, error) {
-	udpAddr, err := net.ResolveUDPAddr(udpNetwork, arg.Addr)
-	if err != nil {
-		return nil, err
-	}
-	conn, err := net.ListenUDP(udpNetwork, udpAddr)
+	conns, err := listenUDP(ctx, arg.Addr, arg.Listeners)
 	if err != nil {
 		return nil, err
 	}
@@ -48,16 +59,23 @@ func NewUDPServer(ctx context.Context, arg UDPSvrArgs) (*UDPServer, error) {
 		onMsg:        arg.OnMsg,
 		onConnect:    arg.OnConnect,
 		onDisconnect: arg.OnDisconnect,
+		onAccept:     arg.OnAccept,
 		sessions:     make(map[string]*UDPSession),
 		closeCh:      make(chan struct{}),
+		queueCounter: &writeQueueCounter{},
+		metrics:      arg.Metrics,
+		limiter:      newConnLimiter(arg.Limit),
+		reliable:     arg.Reliable,
+	}
+	svr.local = conns[0].LocalAddr()
+	for _, conn := range conns {
+		svr.socks = append(svr.socks, NewUDPSocket(ctx, UDPSocketArgs{isServer: true, conn: conn, onMsg: svr.udpOnMsg, writeQueue: arg.WriteQueue, queueCounter: svr.queueCounter, options: arg.Options.withDefault(), batch: arg.Batch}))
 	}
-	svr.sock.Store(NewUDPSocket(ctx, UDPSocketArgs{isServer: true, conn: conn, onMsg: svr.udpOnMsg}))
-	svr.local = conn.LocalAddr()
 
 	svr.wg.Add(1)
 	go svr.checkLoop(ctx, arg.Timeout)
 
-	xlog.Get(ctx).Info("UDP server start success.", zap.Any("addr", arg.Addr))
+	xlog.Get(ctx).Info("UDP server start success.", zap.Any("addr", arg.Addr), zap.Int("listeners", len(conns)))
 	return svr, nil
 }
 
@@ -79,13 +97,15 @@ loop:
 
 		svr.mu.Lock()
 		for _, session := range svr.sessions {
-			if session.getActiveAt() < sessionTimeout {
+			// 超时或已关闭(慢连接断开)
+			if session.getActiveAt() < sessionTimeout || session.isClosed() {
 				expires = append(expires, session)
 			}
 		}
 		svr.mu.Unlock()
 
 		for _, session := range expires {
+			session.reporter.setCloseReason(CloseReasonTimeout)
 			session.Close(ctx)
 			svr.delSession(ctx, session)
 			xlog.Get(ctx).Warn("UDP session timeout", zap.Any("id", addrToString(session.remoteAddr())))
@@ -93,11 +113,18 @@ loop:
 	}
 }
 
-func (svr *UDPServer) udpOnMsg(ctx context.Context, msg []byte, addr *net.UDPAddr) {
+func (svr *UDPServer) udpOnMsg(ctx context.Context, sock *UDPSocket, msg []byte, addr *net.UDPAddr) {
+	if atomic.LoadInt32(&svr.closed) == 1 {
+		return
+	}
 	id := addrToString(addr)
 	now := time.Now().Unix()
 	session := svr.getSession(ctx, id)
 	if session == nil {
+		// 未通过准入或超过连接数, 丢弃新地址的数据包
+		if !admit(ctx, svr.onAccept, addr) || !svr.limiter.acquire(ctx, addr) {
+			return
+		}
 		subCtx, cancel := context.WithCancel(ctx)
 		session = NewUDPSession(subCtx, UDPSessionArgs{
 			cancel:       cancel,
@@ -106,11 +133,18 @@ func (svr *UDPServer) udpOnMsg(ctx context.Context, msg []byte, addr *net.UDPAdd
 			onMsg:        svr.onMsg,
 			onConnect:    svr.onConnect,
 			onDisconnect: svr.onDisconnect,
-			sendMsg:      svr.sock.Load().(*UDPSocket).sendMsg,
+			sendMsg:      sock.sendMsg,
 			now:          now,
+			metrics:      svr.metrics,
+			rate:         svr.limiter.newRate(addr),
+			reliable:     svr.reliable,
 		})
 		svr.addSession(ctx, session)
 	}
+	// 超过速率丢弃数据包
+	if !session.rate.allowBytes(ctx, len(msg)) || !session.rate.allowMsg(ctx, 1) {
+		return
+	}
 	if err := session.recvMsg(msg, now); err != nil {
 		xlog.Get(ctx).Warn("UDP session recv msg failed.", zap.Any("err", err))
 	}
@@ -128,6 +162,7 @@ func (svr *UDPServer) delSession(ctx context.Context, session *UDPSession) {
 	svr.mu.Lock()
 	defer svr.mu.Unlock()
 	delete(svr.sessions, id)
+	svr.limiter.release(session.remoteAddr())
 }
 
 func (svr *UDPServer) getSession(ctx context.Context, id string) *UDPSession {
@@ -136,14 +171,56 @@ func (svr *UDPServer) getSession(ctx context.Context, id string) *UDPSession {
 	return svr.sessions[id]
 }
 
+// 写队列计数(所有连接汇总)
+func (svr *UDPServer) WriteQueueStats() WriteQueueStats {
+	return svr.queueCounter.snapshot()
+}
+
+// 连接(session)数及拒绝计数
+func (svr *UDPServer) LimitStats() LimitStats {
+	return svr.limiter.stats()
+}
+
 func (svr *UDPServer) Close(ctx context.Context) {
+	atomic.StoreInt32(&svr.closed, 1)
+
 	svr.mu.Lock()
 	for _, session := range svr.sessions {
 		session.Close(ctx)
 	}
 	svr.mu.Unlock()
 
-	svr.sock.Load().(*UDPSocket).close(ctx)
-	close(svr.closeCh)
+	svr.stop(ctx)
+}
+
+// 优雅关闭: 丢弃新数据包, 等待session处理剩余消息并刷新写缓存后关闭
+// ctx超时后强制关闭剩余session, 返回被强制关闭的session地址
+func (svr *UDPServer) Shutdown(ctx context.Context) ([]net.Addr, error) {
+	atomic.StoreInt32(&svr.closed, 1)
+
+	svr.mu.Lock()
+	sessions := make([]drainSocket, 0, len(svr.sessions))
+	for _, session := range svr.sessions {
+		sessions = append(sessions, session)
+	}
+	svr.mu.Unlock()
+
+	forced, err := drainSockets(ctx, sessions)
+
+	// session共用socket, 全部关闭后刷新写缓存
+	svr.stop(ctx)
+
+	xlog.Get(ctx).Info("UDP server shutdown.", zap.Int("sessions", len(sessions)), zap.Int("forced", len(forced)))
+	return forced, err
+}
+
+// 关闭socket与检查协程
+func (svr *UDPServer) stop(ctx context.Context) {
+	svr.stopOnce.Do(func() {
+		for _, sock := range svr.socks {
+			sock.close(ctx)
+		}
+		close(svr.closeCh)
+	})
 	svr.wg.Wait()
 }
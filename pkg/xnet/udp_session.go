@@ -2,11 +2,13 @@ package xnet
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"gotu/pkg/xcommon"
 	"gotu/pkg/xlog"
 	"net"
 	"sync/atomic"
+	"time"
 
 	"sync"
 
@@ -22,6 +24,9 @@ type UDPSessionArgs struct {
 	onDisconnect OnDisconnect
 	sendMsg      udpSendMsg
 	now          int64
+	metrics      Metrics
+	rate         *rateLimiter    // 速率限制(nil:不限制)
+	reliable     ReliableOptions // 可靠性层(通道模式)
 }
 
 type UDPSession struct {
@@ -33,11 +38,15 @@ type UDPSession struct {
 	onDisconnect OnDisconnect
 	sendMsg      udpSendMsg
 	activeAt     int64
+	rate         *rateLimiter
+	rel          *reliable // 可靠性层(nil:未开启)
 
-	msgCh chan []byte
+	msgCh    chan []byte
+	reporter *socketReporter
 
 	closeCh   chan struct{}
 	closeOnce sync.Once
+	drainFlag int32 // 优雅关闭标识(关闭后处理剩余消息)
 	wg        xcommon.WaitGroup
 }
 
@@ -51,9 +60,12 @@ func NewUDPSession(ctx context.Context, arg UDPSessionArgs) *UDPSession {
 		onDisconnect: arg.onDisconnect,
 		sendMsg:      arg.sendMsg,
 		activeAt:     arg.now,
+		rate:         arg.rate,
+		rel:          newReliable(arg.reliable),
 		msgCh:        make(chan []byte, udpMsgChanLimit),
 		closeCh:      make(chan struct{}),
 	}
+	session.reporter = newSocketReporter(arg.metrics, session, 1)
 	session.wg.Add(1)
 	go session.handlerLoop(ctx)
 	return session
@@ -64,7 +76,11 @@ func (session *UDPSession) handlerLoop(ctx context.Context) {
 	defer func() {
 		if handlerErr != nil {
 			xlog.Get(ctx).Warn("Handler loop exit with error", zap.Any("err", handlerErr))
+			session.reporter.setCloseReason(CloseReasonHandlerError)
+			// 标记关闭, 由server/client回收
+			session.forceClose(ctx)
 		}
+		session.reporter.loopDone()
 	}()
 
 	defer session.wg.Done(ctx)
@@ -74,27 +90,102 @@ func (session *UDPSession) handlerLoop(ctx context.Context) {
 		session.onDisconnect(ctx, state)
 	}()
 
+	// 可靠性层重传检查
+	var retransmitCh <-chan time.Time
+	if session.rel != nil {
+		ticker := time.NewTicker(session.rel.opt.RTO / 2)
+		defer ticker.Stop()
+		retransmitCh = ticker.C
+	}
+
 loop:
 	for {
 		var msg []byte
 		select {
 		case msg = <-session.msgCh:
+		case <-retransmitCh:
+			if err := session.retransmit(ctx); err != nil {
+				session.reporter.setCloseReason(CloseReasonRetransmit)
+				handlerErr = err
+				break loop
+			}
+			continue
 		case <-session.closeCh:
+			handlerErr = session.drainMsg(ctx, state)
 			break loop
 		}
 
-		_, err := session.onMsg(ctx, state, msg)
-		if err != nil {
+		if err := session.handleMsg(ctx, state, msg); err != nil {
 			handlerErr = err
 			break
 		}
 	}
 }
 
+// 优雅关闭, 处理剩余消息
+func (session *UDPSession) drainMsg(ctx context.Context, state interface{}) error {
+	for atomic.LoadInt32(&session.drainFlag) == 1 {
+		select {
+		case msg := <-session.msgCh:
+			if err := session.handleMsg(ctx, state, msg); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (session *UDPSession) handleMsg(ctx context.Context, state interface{}, msg []byte) error {
+	if session.rel == nil {
+		return session.deliverMsg(ctx, state, msg)
+	}
+	msgs, ack, err := session.rel.unpack(msg)
+	if err != nil {
+		return err
+	}
+	if ack != nil {
+		if err := session.send(ctx, ack); err != nil {
+			xlog.Get(ctx).Warn("UDP session send ack failed.", zap.Any("err", err))
+		}
+	}
+	for _, msg := range msgs {
+		if err := session.deliverMsg(ctx, state, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (session *UDPSession) deliverMsg(ctx context.Context, state interface{}, msg []byte) error {
+	begin := time.Now()
+	if _, err := session.onMsg(ctx, state, msg); err != nil {
+		return err
+	}
+	session.reporter.msgHandled(begin)
+	return nil
+}
+
+// 重传到期未确认的可靠消息
+func (session *UDPSession) retransmit(ctx context.Context) error {
+	datas, err := session.rel.expired(time.Now())
+	if err != nil {
+		return err
+	}
+	for _, data := range datas {
+		if err := session.send(ctx, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (session *UDPSession) recvMsg(msg []byte, now int64) error {
 	select {
 	case session.msgCh <- msg:
 		atomic.StoreInt64(&session.activeAt, now)
+		session.reporter.bytesIn(len(msg))
 		return nil
 	case <-session.closeCh:
 		return fmt.Errorf("session already close")
@@ -120,6 +211,7 @@ func (session *UDPSession) getActiveAt() int64 {
 }
 
 func (session *UDPSession) Close(ctx context.Context) {
+	session.reporter.setCloseReason(CloseReasonLocal)
 	session.forceClose(ctx)
 	session.wg.Wait()
 }
@@ -130,6 +222,64 @@ func (session *UDPSession) forceClose(ctx context.Context) {
 	})
 }
 
+func (session *UDPSession) isClosed() bool {
+	select {
+	case <-session.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// 停止接收, 剩余消息处理完成后关闭
+func (session *UDPSession) drain(ctx context.Context) {
+	session.reporter.setCloseReason(CloseReasonShutdown)
+	atomic.StoreInt32(&session.drainFlag, 1)
+	session.forceClose(ctx)
+}
+
+// 强制关闭, 丢弃剩余消息
+func (session *UDPSession) abort(ctx context.Context) {
+	atomic.StoreInt32(&session.drainFlag, 0)
+	session.forceClose(ctx)
+}
+
+func (session *UDPSession) wait() {
+	session.wg.Wait()
+}
+
+// 开启Reliable时使用ChannelUnreliable发送
 func (session *UDPSession) SendMsg(ctx context.Context, msg []byte) error {
-	return session.sendMsg(ctx, &udpDatagram{msg: msg, addr: session.addr})
+	return session.SendChannel(ctx, ChannelUnreliable, msg)
+}
+
+// 指定通道发送, 未开启Reliable时仅支持ChannelUnreliable
+func (session *UDPSession) SendChannel(ctx context.Context, ch Channel, msg []byte) error {
+	if session.rel == nil {
+		if ch != ChannelUnreliable {
+			return ErrReliableDisabled
+		}
+		return session.send(ctx, msg)
+	}
+	data, err := session.rel.pack(ch, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	return session.send(ctx, data)
+}
+
+// 可靠性层计数(未开启时为零值)
+func (session *UDPSession) ReliableStats() ReliableStats {
+	return session.rel.snapshot()
+}
+
+func (session *UDPSession) send(ctx context.Context, msg []byte) error {
+	err := session.sendMsg(ctx, &udpDatagram{msg: msg, addr: session.addr, reporter: session.reporter})
+	if errors.Is(err, ErrSlowConsumer) {
+		// udp socket为所有session共用, 仅断开当前session
+		xlog.Get(ctx).Warn("Disconnect slow consumer.", zap.Any("remote", session.RemoteAddr()))
+		session.reporter.setCloseReason(CloseReasonSlowConsumer)
+		session.forceClose(ctx)
+	}
+	return err
 }
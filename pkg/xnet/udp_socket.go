@@ -3,31 +3,41 @@ package xnet
 import (
 	"context"
 	"errors"
-	"fmt"
 	"gotu/pkg/xcommon"
 	"gotu/pkg/xlog"
 	"net"
 	"sync"
 
 	"go.uber.org/zap"
+	"golang.org/x/net/ipv4"
 )
 
 type UDPSocketArgs struct {
-	isServer bool
-	conn     *net.UDPConn
-	onMsg    udpOnMsg
+	isServer     bool
+	conn         *net.UDPConn
+	onMsg        udpOnMsg
+	writeQueue   WriteQueueOptions
+	queueCounter *writeQueueCounter
+	options      Options // 已填充默认值
+	batch        int     // 批量收发数据包数量(>1开启)
 }
 
 type udpDatagram struct {
-	msg  []byte
-	addr *net.UDPAddr
+	msg      []byte
+	addr     *net.UDPAddr
+	reporter *socketReporter // 所属session指标上报
 }
 
 type UDPSocket struct {
-	isServer bool
-	conn     *net.UDPConn
-	onMsg    udpOnMsg
-	writeCh  chan *udpDatagram
+	isServer   bool
+	conn       *net.UDPConn
+	onMsg      udpOnMsg
+	writeQueue *writeQueue[*udpDatagram]
+	bufSize    int // 单个数据包上限
+
+	bconn    batchConn      // 批量收发(nil: 逐个收发)
+	batch    int            // 单次批量收发数据包上限
+	sendMsgs []ipv4.Message // 批量写入缓存(复用)
 
 	closeOnce sync.Once
 	closeCh   chan struct{}
@@ -39,9 +49,19 @@ func NewUDPSocket(ctx context.Context, arg UDPSocketArgs) *UDPSocket {
 		isServer: arg.isServer,
 		conn:     arg.conn,
 		onMsg:    arg.onMsg,
-		writeCh:  make(chan *udpDatagram, writeChanLimit*10),
 		closeCh:  make(chan struct{}),
+		bufSize:  arg.options.ReadBufferSize,
+	}
+	if arg.batch > 1 {
+		sock.bconn = newBatchConn(arg.conn)
+		sock.batch = arg.batch
+		sock.sendMsgs = make([]ipv4.Message, arg.batch)
+		for i := range sock.sendMsgs {
+			sock.sendMsgs[i].Buffers = make([][]byte, 1)
+		}
 	}
+	arg.options.applyBuffer(ctx, arg.conn)
+	sock.writeQueue = newWriteQueue(arg.writeQueue, arg.queueCounter, writeChanLimit*10, datagramSize, sock.closeCh)
 	sock.wg.Add(2)
 	go sock.readLoop(ctx)
 	go sock.writeLoop(ctx)
@@ -58,18 +78,28 @@ func (sock *UDPSocket) readLoop(ctx context.Context) {
 	}()
 
 	defer sock.wg.Done(ctx)
-	for {
-		bytes := make([]byte, readBufferSize)
 
-		// TODO 错误分析, 是否出错即关闭
+	// TODO 错误分析, 是否出错即关闭
+	var err error
+	if sock.bconn != nil {
+		err = sock.readBatch(ctx)
+	} else {
+		err = sock.read(ctx)
+	}
+	if !errors.Is(err, net.ErrClosed) {
+		readErr = err
+	}
+}
+
+// 逐个读取数据包
+func (sock *UDPSocket) read(ctx context.Context) error {
+	for {
+		bytes := make([]byte, sock.bufSize)
 		n, addr, err := sock.conn.ReadFromUDP(bytes)
 		if err != nil {
-			if !errors.Is(err, net.ErrClosed) {
-				readErr = err
-			}
-			break
+			return err
 		}
-		sock.onMsg(ctx, bytes[0:n], addr)
+		sock.onMsg(ctx, sock, bytes[0:n], addr)
 	}
 }
 
@@ -85,43 +115,43 @@ func (sock *UDPSocket) writeLoop(ctx context.Context) {
 
 	defer sock.wg.Done(ctx)
 
-	close := false
+	isClosed := false
+	for !isClosed {
+		// 阻塞等待数据, closed状态将待发送数据全部发送
+		select {
+		case <-sock.writeQueue.wait():
+		case <-sock.closeCh:
+			isClosed = true
+		}
 
-loop:
-	for {
-		var datagram *udpDatagram
-		if !close {
-			select {
-			case datagram = <-sock.writeCh:
-			case <-sock.closeCh:
-				close = true
-				continue loop
-			}
+		// TODO 错误分析, 是否出错即关闭
+		datagrams := sock.writeQueue.popAll()
+		if sock.bconn != nil {
+			writeErr = sock.writeBatch(datagrams)
 		} else {
-			select {
-			case datagram = <-sock.writeCh:
-			default:
-			}
+			writeErr = sock.write(datagrams)
 		}
-		if datagram == nil {
-			break
+		if writeErr != nil {
+			return
 		}
+	}
+}
 
-		// TODO 错误分析, 是否出错即关闭
+// 逐个写入数据包
+func (sock *UDPSocket) write(datagrams []*udpDatagram) error {
+	for _, datagram := range datagrams {
 		if sock.isServer {
-			_, err := sock.conn.WriteToUDP(datagram.msg, datagram.addr)
-			if err != nil {
-				writeErr = err
-				break
+			if _, err := sock.conn.WriteToUDP(datagram.msg, datagram.addr); err != nil {
+				return err
 			}
 		} else {
-			_, err := sock.conn.Write(datagram.msg)
-			if err != nil {
-				writeErr = err
-				break
+			if _, err := sock.conn.Write(datagram.msg); err != nil {
+				return err
 			}
 		}
+		datagram.reporter.bytesOut(len(datagram.msg), 1)
 	}
+	return nil
 }
 
 func (sock *UDPSocket) close(ctx context.Context) {
@@ -136,12 +166,9 @@ func (sock *UDPSocket) forceClose(ctx context.Context) {
 }
 
 func (sock *UDPSocket) sendMsg(ctx context.Context, datagram *udpDatagram) error {
-	select {
-	case sock.writeCh <- datagram:
-		return nil
-	case <-sock.closeCh:
-		return fmt.Errorf("sock already close")
-	default:
-		return fmt.Errorf("msg overflow")
-	}
+	return sock.writeQueue.push(ctx, datagram)
+}
+
+func datagramSize(datagram *udpDatagram) int {
+	return len(datagram.msg)
 }
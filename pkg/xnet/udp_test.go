@@ -95,3 +95,39 @@ func TestUDP(t *testing.T) {
 	wg.Wait()
 	cli.Close(ctx)
 }
+
+// 批量收发(recvmmsg/sendmmsg): 连续发送多个数据包, 逐个回包
+func TestUDPBatch(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9915"
+	svr, err := xnet.NewUDPServer(ctx, xnet.UDPSvrArgs{Addr: addr, Timeout: 10, Batch: 8, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	defer svr.Close(ctx)
+
+	recvCh := make(chan []byte, 100)
+	cli, err := xnet.NewUDPClient(ctx, xnet.UDPCliArgs{Addr: addr, Timeout: 10, Batch: 8, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: recvPayload(recvCh)})
+	if err != nil {
+		panic(err)
+	}
+	defer cli.Close(ctx)
+
+	for round := 0; round < 5; round++ {
+		payloads := make([][]byte, 0, 20)
+		for i := 0; i < 20; i++ {
+			payload := []byte(fmt.Sprintf("round %v msg %v", round, i))
+			msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: payload})
+			if err != nil {
+				panic(err)
+			}
+			if err := cli.SendMsg(ctx, msg); err != nil {
+				panic(err)
+			}
+			payloads = append(payloads, payload)
+		}
+		for _, payload := range payloads {
+			expectPayload(t, string(payload), recvCh, payload)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package xnet
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+type UnixCliArgs struct {
+	Path         string // socket文件路径, "@"开头为linux抽象地址
+	OnMsg        OnHandlerOnce
+	OnConnect    OnConnect
+	OnDisconnect OnDisconnect
+	WriteQueue   WriteQueueOptions // 写队列策略(队列满时的处理)
+	Metrics      Metrics           // 指标上报(nil:不上报)
+	Heartbeat    HeartbeatOptions  // 心跳及空闲检测(开启ping须配置Codec)
+	Reconnect    ReconnectOptions  // 自动重连
+	Options      Options           // 连接参数(buffer/超时), Nagle无效
+}
+
+type UnixClient struct {
+	rc     *reconnector[*TCPSocket]
+	bufMgr *bufferManager
+	arg    *UnixCliArgs
+}
+
+func NewUnixClient(ctx context.Context, arg UnixCliArgs) (*UnixClient, error) {
+	if err := arg.Heartbeat.checkTCP(); err != nil {
+		return nil, err
+	}
+	arg.Options = arg.Options.withDefault()
+	bufMgr := newBufferManager(arg.Options.ReadBufferSize)
+
+	cli := &UnixClient{bufMgr: bufMgr, arg: &arg}
+	cli.rc = newReconnector(arg.Reconnect, cli.newSocket)
+	if err := cli.rc.start(ctx); err != nil {
+		return nil, err
+	}
+	return cli, nil
+}
+
+func (cli *UnixClient) newSocket(ctx context.Context, onLost func(ctx context.Context)) (*TCPSocket, error) {
+	conn, err := net.DialUnix(unixNetwork, nil, &net.UnixAddr{Name: cli.arg.Path, Net: unixNetwork})
+	if err != nil {
+		return nil, err
+	}
+	return newTCPSocket(ctx, TCPSocketArgs{
+		conn:       conn,
+		rawConn:    conn,
+		readBuffer: cli.bufMgr.newReadBuffer(),
+		writeQueue: cli.arg.WriteQueue,
+		metrics:    cli.arg.Metrics,
+		heartbeat:  cli.arg.Heartbeat,
+		options:    cli.arg.Options,
+		onMsg:      cli.arg.OnMsg,
+		onConnect:  cli.arg.OnConnect,
+		onDisconnect: func(ctx context.Context, state interface{}) {
+			cli.arg.OnDisconnect(ctx, state)
+			onLost(ctx)
+		},
+		releaseFn: func(ctx context.Context, ts *TCPSocket) {},
+	}), nil
+}
+
+func (cli *UnixClient) Reconnect(ctx context.Context) error {
+	return cli.rc.reconnect(ctx)
+}
+
+func (cli *UnixClient) Close(ctx context.Context) {
+	cli.rc.close(ctx)
+}
+
+// 最近一次心跳往返时间
+func (cli *UnixClient) RTT() time.Duration {
+	return cli.rc.current().RTT()
+}
+
+// 自动重连开启时, 断线期间按配置缓存
+func (cli *UnixClient) SendMsg(ctx context.Context, msg []byte) error {
+	return cli.rc.send(ctx, msg)
+}
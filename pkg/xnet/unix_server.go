@@ -0,0 +1,206 @@
+package xnet
+
+import (
+	"context"
+	"fmt"
+	"gotu/pkg/xlog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// unix domain socket(stream)服务端, 连接为TCPSocket, 消息处理与tcp一致
+type UnixSvrArgs struct {
+	Path         string      // socket文件路径, "@"开头为linux抽象地址(无文件)
+	FileMode     os.FileMode // socket文件权限(默认0660)
+	OnMsg        OnHandlerOnce
+	OnConnect    OnConnect
+	OnDisconnect OnDisconnect
+	WriteQueue   WriteQueueOptions // 写队列策略(队列满时的处理)
+	Metrics      Metrics           // 指标上报(nil:不上报)
+	Heartbeat    HeartbeatOptions  // 心跳及空闲检测(开启ping须配置Codec)
+	Options      Options           // 连接参数(buffer/超时), Nagle无效
+}
+
+type UnixServer struct {
+	wg       sync.WaitGroup
+	listener *net.UnixListener
+	closeCh  chan struct{}
+	stopOnce sync.Once
+
+	onMsg        OnHandlerOnce
+	onConnect    OnConnect
+	onDisconnect OnDisconnect
+
+	bufMgr       *bufferManager
+	writeQueue   WriteQueueOptions
+	queueCounter *writeQueueCounter
+	metrics      Metrics
+	heartbeat    HeartbeatOptions
+	options      Options
+
+	mu      sync.Mutex
+	sockets map[*TCPSocket]bool
+}
+
+func NewUnixServer(ctx context.Context, arg UnixSvrArgs) (*UnixServer, error) {
+	if err := arg.Heartbeat.checkTCP(); err != nil {
+		return nil, err
+	}
+	if err := removeStaleSocket(arg.Path); err != nil {
+		return nil, err
+	}
+	listener, err := net.ListenUnix(unixNetwork, &net.UnixAddr{Name: arg.Path, Net: unixNetwork})
+	if err != nil {
+		return nil, fmt.Errorf("listen unix[%s] failed %w", arg.Path, err)
+	}
+	if !isAbstractUnix(arg.Path) {
+		mode := arg.FileMode
+		if mode == 0 {
+			mode = unixFileMode
+		}
+		if err := os.Chmod(arg.Path, mode); err != nil {
+			_ = listener.Close()
+			return nil, fmt.Errorf("chmod unix[%s] failed %w", arg.Path, err)
+		}
+	}
+	options := arg.Options.withDefault()
+	svr := &UnixServer{
+		listener:     listener,
+		closeCh:      make(chan struct{}),
+		sockets:      make(map[*TCPSocket]bool),
+		bufMgr:       newBufferManager(options.ReadBufferSize),
+		onMsg:        arg.OnMsg,
+		onConnect:    arg.OnConnect,
+		onDisconnect: arg.OnDisconnect,
+		writeQueue:   arg.WriteQueue,
+		queueCounter: &writeQueueCounter{},
+		metrics:      arg.Metrics,
+		heartbeat:    arg.Heartbeat,
+		options:      options,
+	}
+	svr.wg.Add(1)
+	go svr.accept(ctx)
+	xlog.Get(ctx).Info("Start listen unix success.", zap.String("path", arg.Path))
+	return svr, nil
+}
+
+// 删除残留socket文件(上次进程未正常退出), 仍有服务监听或非socket文件返回错误
+func removeStaleSocket(path string) error {
+	if isAbstractUnix(path) {
+		return nil
+	}
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("unix path[%s] exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout(unixNetwork, path, unixProbeTimeout); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("unix path[%s] already in use", path)
+	}
+	return os.Remove(path)
+}
+
+func isAbstractUnix(path string) bool {
+	return strings.HasPrefix(path, "@")
+}
+
+func (svr *UnixServer) accept(ctx context.Context) {
+	defer svr.wg.Done()
+
+	for {
+		conn, err := svr.listener.AcceptUnix()
+
+		// 监听关闭检测
+		select {
+		case <-svr.closeCh:
+			xlog.Get(ctx).Debug("Unix listener close.")
+			return
+		default:
+		}
+
+		if err != nil {
+			xlog.Get(ctx).Warn("Accept unix failed.", zap.Any("err", err))
+			continue
+		}
+		s := newTCPSocket(ctx, TCPSocketArgs{
+			conn:         conn,
+			rawConn:      conn,
+			readBuffer:   svr.bufMgr.newReadBuffer(),
+			writeQueue:   svr.writeQueue,
+			queueCounter: svr.queueCounter,
+			metrics:      svr.metrics,
+			heartbeat:    svr.heartbeat,
+			options:      svr.options,
+			onMsg:        svr.onMsg,
+			onConnect:    svr.onConnect,
+			onDisconnect: svr.onDisconnect,
+			releaseFn:    svr.delSocket,
+		})
+		svr.addSocket(ctx, s)
+	}
+}
+
+// 停止接收新连接(关闭监听时删除socket文件)
+func (svr *UnixServer) stopAccept() {
+	svr.stopOnce.Do(func() {
+		close(svr.closeCh)
+		_ = svr.listener.Close()
+	})
+	svr.wg.Wait()
+}
+
+func (svr *UnixServer) Close(ctx context.Context) {
+	svr.stopAccept()
+
+	svr.mu.Lock()
+	for sock := range svr.sockets {
+		sock.Close(ctx)
+	}
+	svr.mu.Unlock()
+
+	xlog.Get(ctx).Info("Unix server stop.")
+}
+
+// 优雅关闭: 停止accept, 等待处理中的消息完成并刷新写缓存后关闭连接
+// ctx超时后强制关闭剩余连接, 返回被强制关闭的连接地址
+func (svr *UnixServer) Shutdown(ctx context.Context) ([]net.Addr, error) {
+	svr.stopAccept()
+
+	svr.mu.Lock()
+	socks := make([]drainSocket, 0, len(svr.sockets))
+	for sock := range svr.sockets {
+		socks = append(socks, sock)
+	}
+	svr.mu.Unlock()
+
+	forced, err := drainSockets(ctx, socks)
+	xlog.Get(ctx).Info("Unix server shutdown.", zap.Int("sockets", len(socks)), zap.Int("forced", len(forced)))
+	return forced, err
+}
+
+// 写队列计数(所有连接汇总)
+func (svr *UnixServer) WriteQueueStats() WriteQueueStats {
+	return svr.queueCounter.snapshot()
+}
+
+func (svr *UnixServer) addSocket(ctx context.Context, s *TCPSocket) {
+	svr.mu.Lock()
+	defer svr.mu.Unlock()
+	svr.sockets[s] = true
+}
+
+func (svr *UnixServer) delSocket(ctx context.Context, s *TCPSocket) {
+	svr.mu.Lock()
+	defer svr.mu.Unlock()
+	delete(svr.sockets, s)
+}
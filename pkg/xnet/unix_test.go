@@ -0,0 +1,60 @@
+package xnet_test
+
+import (
+	"context"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnix(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "gotu.sock")
+
+	// 残留socket文件(进程异常退出)
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		panic(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	svr, err := xnet.NewUnixServer(ctx, xnet.UnixSvrArgs{Path: path, FileMode: 0o600, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()})
+	if err != nil {
+		panic(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		panic(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("file mode %v", info.Mode())
+	}
+	// 已有服务监听
+	if _, err := xnet.NewUnixServer(ctx, xnet.UnixSvrArgs{Path: path, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: echoHandler()}); err == nil {
+		t.Fatalf("listen path in use success")
+	}
+
+	recvCh := make(chan []byte, 1)
+	cli, err := xnet.NewUnixClient(ctx, xnet.UnixCliArgs{Path: path, OnConnect: onConnectSock, OnDisconnect: onDisconnectLog, OnMsg: recvPayload(recvCh)})
+	if err != nil {
+		panic(err)
+	}
+	msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: []byte("unix data")})
+	if err != nil {
+		panic(err)
+	}
+	if err := cli.SendMsg(ctx, msg); err != nil {
+		panic(err)
+	}
+	expectPayload(t, "unix", recvCh, []byte("unix data"))
+
+	cli.Close(ctx)
+	svr.Close(ctx)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("socket file not removed %v", err)
+	}
+}
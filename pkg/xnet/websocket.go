@@ -2,11 +2,12 @@ package xnet
 
 import (
 	"context"
-	"fmt"
+	"encoding/binary"
 	"gotu/pkg/xcommon"
 	"gotu/pkg/xlog"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
@@ -20,6 +21,13 @@ type WebsocketArgs struct {
 	onMsg        OnHandlerOnce
 	onConnect    OnConnect
 	onDisconnect OnDisconnect
+	writeQueue   WriteQueueOptions
+	queueCounter *writeQueueCounter
+	metrics      Metrics
+	heartbeat    HeartbeatOptions
+	options      Options      // 已填充默认值
+	remote       net.Addr     // 客户端真实地址(X-Forwarded-For), nil: 连接地址
+	rate         *rateLimiter // 速率限制(nil:不限制)
 }
 
 type Websocket struct {
@@ -28,10 +36,17 @@ type Websocket struct {
 	onConnect    OnConnect
 	onDisconnect OnDisconnect
 
-	writeCh chan []byte // 写channel
+	writeQueue *writeQueue[[]byte] // 写队列
+	reporter   *socketReporter
+	hb         *heartbeat
+
+	writeTimeout time.Duration
+	rate         *rateLimiter
+	remote       net.Addr
 
 	closeOnce sync.Once
 	closeCh   chan struct{}
+	drainFlag int32 // 优雅关闭标识(停止读取)
 	wg        xcommon.WaitGroup
 }
 
@@ -40,14 +55,35 @@ func NewWebsocket(ctx context.Context, arg WebsocketArgs) (*Websocket, error) {
 		onMsg:        arg.onMsg,
 		onConnect:    arg.onConnect,
 		onDisconnect: arg.onDisconnect,
-		writeCh:      make(chan []byte, writeChanLimit),
 		closeCh:      make(chan struct{}),
+		writeTimeout: arg.options.WriteTimeout,
+		rate:         arg.rate,
+		remote:       arg.remote,
+	}
+	sock.writeQueue = newWriteQueue(arg.writeQueue, arg.queueCounter, writeChanLimit, msgSize, sock.closeCh)
+	sock.reporter = newSocketReporter(arg.metrics, sock, 2)
+	sock.writeQueue.depthFn = sock.reporter.queueDepth
+	sock.conn.SetReadLimit(arg.options.MaxMessageSize)
+	arg.options.applyConn(ctx, unwrapConn(sock.conn.UnderlyingConn()))
+	sock.hb = newHeartbeat(arg.heartbeat, arg.options.ReadTimeout, sock, sock.closeCh)
+	sock.hb.sendPing = sock.sendPing
+	sock.hb.abort = func(reason CloseReason) {
+		sock.reporter.setCloseReason(reason)
+		sock.abort(ctx)
 	}
-	sock.conn.SetReadLimit(maxMessageSize)
+	sock.conn.SetPingHandler(sock.onPing)
+	sock.conn.SetPongHandler(sock.onPong)
 
 	sock.wg.Add(2)
 	go sock.readLoop(ctx)
 	go sock.writeLoop(ctx)
+	if sock.hb.enabled() {
+		sock.wg.Add(1)
+		go func() {
+			defer sock.wg.Done(ctx)
+			sock.hb.loop(ctx)
+		}()
+	}
 	return sock, nil
 }
 
@@ -57,7 +93,9 @@ func (sock *Websocket) readLoop(ctx context.Context) {
 		if readErr != nil {
 			xlog.Get(ctx).Warn("Read loop exit with error.", zap.Any("err", readErr))
 		}
+		sock.reporter.setReadErr(readErr)
 		sock.forceClose()
+		sock.reporter.loopDone()
 	}()
 
 	defer sock.wg.Done(ctx)
@@ -68,32 +106,84 @@ func (sock *Websocket) readLoop(ctx context.Context) {
 	}()
 
 	for {
-		if err := sock.conn.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
+		if err := sock.conn.SetReadDeadline(sock.hb.deadline()); err != nil {
 			readErr = err
 			break
 		}
+		// 优雅关闭, 停止读取(须在设置deadline之后检测)
+		if atomic.LoadInt32(&sock.drainFlag) == 1 {
+			break
+		}
 
 		_, message, err := sock.conn.ReadMessage()
 		if err != nil {
-			if e, ok := err.(*websocket.CloseError); (!ok || e.Code != websocket.CloseNormalClosure) && !errors.Is(err, net.ErrClosed) {
+			if e, ok := err.(*websocket.CloseError); (!ok || e.Code != websocket.CloseNormalClosure) && !errors.Is(err, net.ErrClosed) && atomic.LoadInt32(&sock.drainFlag) == 0 {
 				readErr = err
 			}
 			break
 		}
+		sock.reporter.bytesIn(len(message))
+		if !sock.rate.allowBytes(ctx, len(message)) {
+			sock.reporter.setCloseReason(CloseReasonRateLimit)
+			break
+		}
 		// websocket 自动解包, 无需流式处理
+		begin := time.Now()
 		_, err = sock.onMsg(ctx, state, message)
 		if err != nil {
 			readErr = err
+			sock.reporter.setCloseReason(CloseReasonHandlerError)
+			break
+		}
+		sock.reporter.msgHandled(begin)
+		sock.hb.active()
+		if !sock.rate.allowMsg(ctx, 1) {
+			sock.reporter.setCloseReason(CloseReasonRateLimit)
 			break
 		}
 	}
 }
 
+// 控制帧在ReadMessage内处理, 需延长读超时
+func (sock *Websocket) extendDeadline() {
+	if atomic.LoadInt32(&sock.drainFlag) == 0 {
+		_ = sock.conn.SetReadDeadline(sock.hb.deadline())
+	}
+}
+
+// 回复pong(控制帧可与写循环并发发送)
+func (sock *Websocket) onPing(data string) error {
+	sock.extendDeadline()
+	err := sock.conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(sock.writeTimeout))
+	if err != nil && err != websocket.ErrCloseSent {
+		return err
+	}
+	return nil
+}
+
+func (sock *Websocket) onPong(data string) error {
+	sock.extendDeadline()
+	var sendAt int64
+	if len(data) == 8 {
+		sendAt = int64(binary.BigEndian.Uint64([]byte(data)))
+	}
+	sock.hb.pong(sendAt)
+	return nil
+}
+
+// ping控制帧, payload为发送时间
+func (sock *Websocket) sendPing(sendAt int64) error {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(sendAt))
+	return sock.conn.WriteControl(websocket.PingMessage, buf[:], time.Now().Add(sock.writeTimeout))
+}
+
 func (sock *Websocket) writeLoop(ctx context.Context) {
 	var writeErr error
 	defer func() {
 		if writeErr != nil {
 			xlog.Get(ctx).Warn("Write loop exit with error.", zap.Any("err", writeErr))
+			sock.reporter.setCloseReason(CloseReasonWriteError)
 		}
 
 		if err := sock.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil && err != websocket.ErrCloseSent {
@@ -101,47 +191,38 @@ func (sock *Websocket) writeLoop(ctx context.Context) {
 		}
 
 		_ = sock.conn.Close()
+		sock.reporter.loopDone()
 	}()
 
 	defer sock.wg.Done(ctx)
 
-	close := false
-
-loop:
-	for {
-		var msg []byte
-		if !close {
-			// 阻塞获取数据
-			select {
-			case msg = <-sock.writeCh:
-			case <-sock.closeCh:
-				close = true
-				continue loop
-			}
-		} else {
-			// closed状态,非阻塞获取数据,将待发送数据全部发送
-			select {
-			case msg = <-sock.writeCh:
-			default:
-			}
-		}
-		if msg == nil {
-			break
+	isClosed := false
+	for !isClosed {
+		// 阻塞等待数据, closed状态将待发送数据全部发送
+		select {
+		case <-sock.writeQueue.wait():
+		case <-sock.closeCh:
+			isClosed = true
 		}
 
-		if err := sock.conn.SetWriteDeadline(time.Now().Add(readTimeout)); err != nil {
-			writeErr = err
-			break
-		}
+		// 每条消息独立成帧
+		for _, msg := range sock.writeQueue.popAll() {
+			if err := sock.conn.SetWriteDeadline(time.Now().Add(sock.writeTimeout)); err != nil {
+				writeErr = err
+				return
+			}
 
-		if err := sock.conn.WriteMessage(websocket.BinaryMessage, msg); err != nil {
-			writeErr = err
-			break
+			if err := sock.conn.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+				writeErr = err
+				return
+			}
+			sock.reporter.bytesOut(len(msg), 1)
 		}
 	}
 }
 
 func (sock *Websocket) Close(ctx context.Context) {
+	sock.reporter.setCloseReason(CloseReasonLocal)
 	sock.forceClose()
 	sock.wg.Wait()
 }
@@ -152,22 +233,45 @@ func (sock *Websocket) forceClose() {
 	})
 }
 
+// 停止读取, 处理中的消息完成后刷新写缓存并关闭
+func (sock *Websocket) drain(ctx context.Context) {
+	sock.reporter.setCloseReason(CloseReasonShutdown)
+	atomic.StoreInt32(&sock.drainFlag, 1)
+	_ = sock.conn.SetReadDeadline(time.Now())
+}
+
+// 强制关闭, 丢弃未发送数据
+func (sock *Websocket) abort(ctx context.Context) {
+	_ = sock.conn.Close()
+}
+
+func (sock *Websocket) wait() {
+	sock.wg.Wait()
+}
+
 func (sock *Websocket) WaitUntilClose(ctx context.Context) {
 	sock.wg.Wait()
 }
 
 func (sock *Websocket) SendMsg(ctx context.Context, msg []byte) error {
-	select {
-	case sock.writeCh <- msg:
-		return nil
-	case <-sock.closeCh:
-		return fmt.Errorf("sock already close")
-	default:
-		return fmt.Errorf("msg overflow")
+	err := sock.writeQueue.push(ctx, msg)
+	if errors.Is(err, ErrSlowConsumer) {
+		xlog.Get(ctx).Warn("Disconnect slow consumer.", zap.Any("remote", sock.RemoteAddr()))
+		sock.reporter.setCloseReason(CloseReasonSlowConsumer)
+		sock.abort(ctx)
 	}
+	return err
+}
+
+// 最近一次心跳往返时间(未开启ping或未收到pong时为0)
+func (sock *Websocket) RTT() time.Duration {
+	return sock.hb.RTT()
 }
 
 func (sock *Websocket) RemoteAddr() net.Addr {
+	if sock.remote != nil {
+		return sock.remote
+	}
 	return sock.conn.RemoteAddr()
 }
 
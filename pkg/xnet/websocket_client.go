@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -14,49 +15,63 @@ type WSCliArgs struct {
 	OnMsg        OnHandlerOnce
 	OnConnect    OnConnect
 	OnDisconnect OnDisconnect
+	WriteQueue   WriteQueueOptions // 写队列策略(队列满时的处理)
+	Metrics      Metrics           // 指标上报(nil:不上报)
+	Heartbeat    HeartbeatOptions  // 心跳及空闲检测(ping/pong控制帧, 无需Codec)
+	Options      Options           // 连接参数(消息上限/超时/NoDelay)
+	Reconnect    ReconnectOptions  // 自动重连
 }
 
 type WSClient struct {
-	sock *Websocket
-	arg  *WSCliArgs
+	rc  *reconnector[*Websocket]
+	arg *WSCliArgs
 }
 
 func NewWSClient(ctx context.Context, arg WSCliArgs) (*WSClient, error) {
+	arg.Options = arg.Options.withDefault()
 	cli := &WSClient{arg: &arg}
-	if err := cli.newSocket(ctx); err != nil {
+	cli.rc = newReconnector(arg.Reconnect, cli.newSocket)
+	if err := cli.rc.start(ctx); err != nil {
 		return nil, err
 	}
 	return cli, nil
 }
 
-func (cli *WSClient) newSocket(ctx context.Context) error {
+func (cli *WSClient) newSocket(ctx context.Context, onLost func(ctx context.Context)) (*Websocket, error) {
 	u := url.URL{Scheme: "ws", Host: cli.arg.Addr, Path: cli.arg.Path}
 	conn, _, err := websocket.DefaultDialer.Dial(u.String(), http.Header{})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	sock, err := NewWebsocket(ctx, WebsocketArgs{
-		conn:         conn,
-		onMsg:        cli.arg.OnMsg,
-		onConnect:    cli.arg.OnConnect,
-		onDisconnect: cli.arg.OnDisconnect,
+	return NewWebsocket(ctx, WebsocketArgs{
+		conn:      conn,
+		onMsg:     cli.arg.OnMsg,
+		onConnect: cli.arg.OnConnect,
+		onDisconnect: func(ctx context.Context, state interface{}) {
+			cli.arg.OnDisconnect(ctx, state)
+			onLost(ctx)
+		},
+		writeQueue: cli.arg.WriteQueue,
+		metrics:    cli.arg.Metrics,
+		heartbeat:  cli.arg.Heartbeat,
+		options:    cli.arg.Options,
 	})
-	cli.sock = sock
-	if err != nil {
-		return err
-	}
-	return err
 }
 
 func (cli *WSClient) Reconnect(ctx context.Context) error {
-	cli.sock.Close(ctx)
-	return cli.newSocket(ctx)
+	return cli.rc.reconnect(ctx)
 }
 
 func (cli *WSClient) Close(ctx context.Context) {
-	cli.sock.Close(ctx)
+	cli.rc.close(ctx)
+}
+
+// 最近一次心跳往返时间
+func (cli *WSClient) RTT() time.Duration {
+	return cli.rc.current().RTT()
 }
 
+// 自动重连开启时, 断线期间按配置缓存
 func (cli *WSClient) SendMsg(ctx context.Context, msg []byte) error {
-	return cli.sock.SendMsg(ctx, msg)
+	return cli.rc.send(ctx, msg)
 }
@@ -18,6 +18,13 @@ type WSSvrArgs struct {
 	OnMsg        OnHandlerOnce
 	OnConnect    OnConnect
 	OnDisconnect OnDisconnect
+	OnAccept     OnAccept          // 准入检查(AccessList.OnAccept), nil: 不检查
+	WriteQueue   WriteQueueOptions // 写队列策略(队列满时的处理)
+	Metrics      Metrics           // 指标上报(nil:不上报)
+	Heartbeat    HeartbeatOptions  // 心跳及空闲检测(ping/pong控制帧, 无需Codec)
+	Options      Options           // 连接参数(消息上限/超时/NoDelay)
+	Limit        LimitOptions      // 连接数及速率限制
+	Proxy        ProxyOptions      // PROXY protocol/X-Forwarded-For(负载均衡后获取客户端真实地址)
 }
 
 type WSServer struct {
@@ -25,19 +32,42 @@ type WSServer struct {
 	httpSrv  *http.Server
 	wg       xcommon.WaitGroup
 
+	queueCounter *writeQueueCounter
+	limiter      *connLimiter
+	proxy        ProxyOptions
+
 	mu      sync.Mutex
 	sockets map[*Websocket]bool // 所有的active连接
+	closed  bool                // 关闭后拒绝新连接
 }
 
 func NewWSServer(ctx context.Context, arg WSSvrArgs) *WSServer {
+	options := arg.Options.withDefault()
 	svr := &WSServer{
 		upgrader: &websocket.Upgrader{},
 		sockets:  make(map[*Websocket]bool),
+
+		queueCounter: &writeQueueCounter{},
+		limiter:      newConnLimiter(arg.Limit),
+		proxy:        arg.Proxy.withDefault(),
 	}
 	// 注册websocket路由
 	mux := http.NewServeMux()
 	mux.Handle(arg.Path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
+		// 升级前准入检查, 拒绝返回403
+		remote := forwardedAddr(r, svr.proxy)
+		if !admit(ctx, arg.OnAccept, remote) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		// 超过连接数返回503
+		if !svr.limiter.acquire(ctx, remote) {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		defer svr.limiter.release(remote)
+
 		conn, err := svr.upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			xlog.Get(ctx).Error("Upgrade connection failed", zap.Any("err", err))
@@ -51,11 +81,21 @@ func NewWSServer(ctx context.Context, arg WSSvrArgs) *WSServer {
 			onMsg:        arg.OnMsg,
 			onConnect:    arg.OnConnect,
 			onDisconnect: arg.OnDisconnect,
+			writeQueue:   arg.WriteQueue,
+			queueCounter: svr.queueCounter,
+			metrics:      arg.Metrics,
+			heartbeat:    arg.Heartbeat,
+			options:      options,
+			remote:       remote,
+			rate:         svr.limiter.newRate(remote),
 		})
 		if err != nil {
 			return
 		}
-		svr.addSocket(sock)
+		if !svr.addSocket(sock) {
+			sock.Close(ctx)
+			return
+		}
 
 		sock.WaitUntilClose(ctx)
 
@@ -79,14 +119,27 @@ func NewWSServer(ctx context.Context, arg WSSvrArgs) *WSServer {
 func (svr *WSServer) start(ctx context.Context) {
 	defer svr.wg.Done(ctx)
 	go func() {
-		if err := svr.httpSrv.ListenAndServe(); err != nil {
+		if err := svr.serve(); err != nil {
 			xlog.Get(ctx).Warn("Http server stop failed.", zap.Any("err", err))
 		}
 	}()
 }
 
+// 监听并处理请求, 开启PROXY protocol时包装listener
+func (svr *WSServer) serve() error {
+	if !svr.proxy.ProxyProtocol {
+		return svr.httpSrv.ListenAndServe()
+	}
+	ln, err := net.Listen(tcpNetwork, svr.httpSrv.Addr)
+	if err != nil {
+		return err
+	}
+	return svr.httpSrv.Serve(&proxyListener{Listener: ln, opt: svr.proxy})
+}
+
 func (svr *WSServer) Close(ctx context.Context) {
 	svr.mu.Lock()
+	svr.closed = true
 	for sock := range svr.sockets {
 		sock.Close(ctx)
 	}
@@ -95,10 +148,46 @@ func (svr *WSServer) Close(ctx context.Context) {
 	svr.wg.Wait()
 }
 
-func (svr *WSServer) addSocket(sock *Websocket) {
+// 优雅关闭: 停止accept, 等待处理中的消息完成并刷新写缓存后关闭连接
+// ctx超时后强制关闭剩余连接, 返回被强制关闭的连接地址
+func (svr *WSServer) Shutdown(ctx context.Context) ([]net.Addr, error) {
+	// 关闭监听与空闲http连接(已升级的websocket连接不受影响)
+	if err := svr.httpSrv.Shutdown(ctx); err != nil {
+		_ = svr.httpSrv.Close()
+	}
+	svr.wg.Wait()
+
+	svr.mu.Lock()
+	svr.closed = true
+	socks := make([]drainSocket, 0, len(svr.sockets))
+	for sock := range svr.sockets {
+		socks = append(socks, sock)
+	}
+	svr.mu.Unlock()
+
+	forced, err := drainSockets(ctx, socks)
+	xlog.Get(ctx).Info("Websocket server shutdown.", zap.Int("sockets", len(socks)), zap.Int("forced", len(forced)))
+	return forced, err
+}
+
+// 写队列计数(所有连接汇总)
+func (svr *WSServer) WriteQueueStats() WriteQueueStats {
+	return svr.queueCounter.snapshot()
+}
+
+// 连接数及拒绝计数
+func (svr *WSServer) LimitStats() LimitStats {
+	return svr.limiter.stats()
+}
+
+func (svr *WSServer) addSocket(sock *Websocket) bool {
 	svr.mu.Lock()
 	defer svr.mu.Unlock()
+	if svr.closed {
+		return false
+	}
 	svr.sockets[sock] = true
+	return true
 }
 
 func (svr *WSServer) delSocket(sock *Websocket) {
@@ -106,3 +195,11 @@ func (svr *WSServer) delSocket(sock *Websocket) {
 	defer svr.mu.Unlock()
 	delete(svr.sockets, sock)
 }
+
+// http请求对端地址
+func remoteAddr(r *http.Request) net.Addr {
+	if addr, err := net.ResolveTCPAddr(tcpNetwork, r.RemoteAddr); err == nil {
+		return addr
+	}
+	return &net.TCPAddr{}
+}
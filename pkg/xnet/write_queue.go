@@ -0,0 +1,254 @@
+package xnet
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 写队列满时的处理策略
+type BackpressurePolicy int
+
+const (
+	PolicyDropNewest BackpressurePolicy = iota // 丢弃新消息, 返回ErrMsgOverflow(默认)
+	PolicyDropOldest                           // 丢弃最旧消息, 新消息入队
+	PolicyBlock                                // 阻塞等待空间, 直到ctx/BlockTimeout超时
+	PolicyDisconnect                           // 断开慢连接, 返回ErrSlowConsumer
+)
+
+func (p BackpressurePolicy) String() string {
+	switch p {
+	case PolicyDropNewest:
+		return "drop-newest"
+	case PolicyDropOldest:
+		return "drop-oldest"
+	case PolicyBlock:
+		return "block"
+	case PolicyDisconnect:
+		return "disconnect"
+	default:
+		return "unknown"
+	}
+}
+
+// 写队列配置(server级, 所有连接共用)
+type WriteQueueOptions struct {
+	Policy       BackpressurePolicy
+	MaxMsgs      int           // 消息数量上限(0:默认值)
+	MaxBytes     int           // 字节上限(0:不限制), 单条消息超过上限时仅在队列为空时入队
+	BlockTimeout time.Duration // PolicyBlock: ctx无deadline时的最长等待时间(0:仅由ctx控制)
+}
+
+// 写队列计数
+type WriteQueueStats struct {
+	Enqueued      uint64 // 入队消息数
+	DropNewest    uint64 // 丢弃新消息数
+	DropOldest    uint64 // 丢弃旧消息数
+	BlockWaits    uint64 // 阻塞等待次数
+	BlockTimeouts uint64 // 阻塞超时次数
+	Disconnects   uint64 // 断开慢连接次数
+}
+
+// 写队列计数器(线程安全)
+type writeQueueCounter struct {
+	enqueued      uint64
+	dropNewest    uint64
+	dropOldest    uint64
+	blockWaits    uint64
+	blockTimeouts uint64
+	disconnects   uint64
+}
+
+func (c *writeQueueCounter) snapshot() WriteQueueStats {
+	return WriteQueueStats{
+		Enqueued:      atomic.LoadUint64(&c.enqueued),
+		DropNewest:    atomic.LoadUint64(&c.dropNewest),
+		DropOldest:    atomic.LoadUint64(&c.dropOldest),
+		BlockWaits:    atomic.LoadUint64(&c.blockWaits),
+		BlockTimeouts: atomic.LoadUint64(&c.blockTimeouts),
+		Disconnects:   atomic.LoadUint64(&c.disconnects),
+	}
+}
+
+func msgSize(msg []byte) int {
+	return len(msg)
+}
+
+// 写队列: 多协程写入, 单协程(write loop)读取
+type writeQueue[T any] struct {
+	opt     WriteQueueOptions
+	counter *writeQueueCounter
+	sizeFn  func(T) int
+	closeCh chan struct{} // socket关闭
+	depthFn func(int)     // 队列长度变化通知(持有锁调用, 保证顺序)
+
+	mu       sync.Mutex
+	items    []T
+	bytes    int
+	notifyCh chan struct{} // 有新数据
+	spaceCh  chan struct{} // 有空闲空间(取出数据时关闭并重建)
+}
+
+func newWriteQueue[T any](opt WriteQueueOptions, counter *writeQueueCounter, defaultMaxMsgs int, sizeFn func(T) int, closeCh chan struct{}) *writeQueue[T] {
+	if opt.MaxMsgs <= 0 {
+		opt.MaxMsgs = defaultMaxMsgs
+	}
+	if counter == nil {
+		counter = &writeQueueCounter{}
+	}
+	return &writeQueue[T]{
+		opt:      opt,
+		counter:  counter,
+		sizeFn:   sizeFn,
+		closeCh:  closeCh,
+		depthFn:  func(int) {},
+		items:    make([]T, 0),
+		notifyCh: make(chan struct{}, 1),
+		spaceCh:  make(chan struct{}),
+	}
+}
+
+// 队列是否可容纳n字节(调用方持有锁)
+func (q *writeQueue[T]) fits(n int) bool {
+	if len(q.items) >= q.opt.MaxMsgs {
+		return false
+	}
+	return q.opt.MaxBytes <= 0 || len(q.items) == 0 || q.bytes+n <= q.opt.MaxBytes
+}
+
+// 写入队列, 队列满时按策略处理
+func (q *writeQueue[T]) push(ctx context.Context, item T) error {
+	select {
+	case <-q.closeCh:
+		return ErrSockClosed
+	default:
+	}
+
+	n := q.sizeFn(item)
+	waited := false
+	var timeoutCh <-chan time.Time
+
+	q.mu.Lock()
+	for !q.fits(n) {
+		switch q.opt.Policy {
+		case PolicyDropOldest:
+			q.bytes -= q.sizeFn(q.items[0])
+			var zero T
+			q.items[0] = zero
+			q.items = q.items[1:]
+			atomic.AddUint64(&q.counter.dropOldest, 1)
+		case PolicyBlock:
+			spaceCh := q.spaceCh
+			q.mu.Unlock()
+
+			if !waited {
+				waited = true
+				atomic.AddUint64(&q.counter.blockWaits, 1)
+				if _, ok := ctx.Deadline(); !ok && q.opt.BlockTimeout > 0 {
+					timer := time.NewTimer(q.opt.BlockTimeout)
+					defer timer.Stop()
+					timeoutCh = timer.C
+				}
+			}
+			select {
+			case <-spaceCh:
+			case <-ctx.Done():
+				atomic.AddUint64(&q.counter.blockTimeouts, 1)
+				return ErrMsgOverflow
+			case <-timeoutCh:
+				atomic.AddUint64(&q.counter.blockTimeouts, 1)
+				return ErrMsgOverflow
+			case <-q.closeCh:
+				return ErrSockClosed
+			}
+			q.mu.Lock()
+		case PolicyDisconnect:
+			q.mu.Unlock()
+			atomic.AddUint64(&q.counter.disconnects, 1)
+			return ErrSlowConsumer
+		default:
+			q.mu.Unlock()
+			atomic.AddUint64(&q.counter.dropNewest, 1)
+			return ErrMsgOverflow
+		}
+	}
+	q.items = append(q.items, item)
+	q.bytes += n
+	q.depthFn(len(q.items))
+	q.mu.Unlock()
+
+	atomic.AddUint64(&q.counter.enqueued, 1)
+	q.notify()
+	return nil
+}
+
+// 忽略上限写入(内置协议消息)
+func (q *writeQueue[T]) forcePush(item T) error {
+	select {
+	case <-q.closeCh:
+		return ErrSockClosed
+	default:
+	}
+
+	q.mu.Lock()
+	q.items = append(q.items, item)
+	q.bytes += q.sizeFn(item)
+	q.depthFn(len(q.items))
+	q.mu.Unlock()
+
+	atomic.AddUint64(&q.counter.enqueued, 1)
+	q.notify()
+	return nil
+}
+
+func (q *writeQueue[T]) notify() {
+	select {
+	case q.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+// 等待新数据
+func (q *writeQueue[T]) wait() <-chan struct{} {
+	return q.notifyCh
+}
+
+// 取出全部数据
+func (q *writeQueue[T]) popAll() []T {
+	return q.swap(nil)
+}
+
+// 取出全部数据, spare(已处理完的上一批)清空后复用为新队列, 避免每次分配
+func (q *writeQueue[T]) swap(spare []T) []T {
+	var zero T
+	for i := range spare {
+		spare[i] = zero
+	}
+
+	q.mu.Lock()
+	if len(q.items) == 0 {
+		q.mu.Unlock()
+		return spare[:0]
+	}
+	items := q.items
+	if spare == nil {
+		spare = make([]T, 0, len(items))
+	}
+	q.items = spare[:0]
+	q.bytes = 0
+
+	// 唤醒阻塞的写入方
+	close(q.spaceCh)
+	q.spaceCh = make(chan struct{})
+	q.depthFn(0)
+	q.mu.Unlock()
+	return items
+}
+
+// 队列长度
+func (q *writeQueue[T]) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
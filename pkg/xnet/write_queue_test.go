@@ -0,0 +1,167 @@
+package xnet_test
+
+import (
+	"context"
+	"errors"
+	"gotu/pkg/xlog"
+	"gotu/pkg/xnet"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const slowPayloadSize = 64 * 1024
+
+// 慢连接服务端: 客户端不读取数据, 服务端持续写入直到写队列满
+func newSlowConsumerServer(ctx context.Context, addr string, opt xnet.WriteQueueOptions, sockCh chan xnet.Socket, disconnectCh chan struct{}) *xnet.TCPServer {
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{
+		Addr:       addr,
+		WriteQueue: opt,
+		OnConnect: func(ctx context.Context, sock xnet.Socket) interface{} {
+			sockCh <- sock
+			return nil
+		},
+		OnDisconnect: func(ctx context.Context, state interface{}) {
+			xlog.Get(ctx).Debug("Svr disconnect")
+			close(disconnectCh)
+		},
+		OnMsg: func(ctx context.Context, state interface{}, msg []byte) (int, error) {
+			return len(msg), nil
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return svr
+}
+
+// 持续写入直到返回错误
+func fillUntilErr(ctx context.Context, sock xnet.Socket) (int, error) {
+	payload := make([]byte, slowPayloadSize)
+	for i := 0; i < 10000; i++ {
+		if err := sock.SendMsg(ctx, payload); err != nil {
+			return i, err
+		}
+	}
+	return 10000, nil
+}
+
+func dialSlowConsumer(addr string, sockCh chan xnet.Socket) (net.Conn, xnet.Socket) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+	return conn, <-sockCh
+}
+
+func TestWriteQueueDropNewest(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9961"
+	sockCh, disconnectCh := make(chan xnet.Socket, 1), make(chan struct{})
+	svr := newSlowConsumerServer(ctx, addr, xnet.WriteQueueOptions{Policy: xnet.PolicyDropNewest, MaxBytes: 4 * slowPayloadSize}, sockCh, disconnectCh)
+	defer svr.Close(ctx)
+
+	conn, sock := dialSlowConsumer(addr, sockCh)
+	defer conn.Close()
+
+	n, err := fillUntilErr(ctx, sock)
+	if !errors.Is(err, xnet.ErrMsgOverflow) {
+		t.Fatalf("send %v msgs, err %v", n, err)
+	}
+	stats := svr.WriteQueueStats()
+	xlog.Get(ctx).Debug("Drop newest", zap.Int("sent", n), zap.Any("stats", stats))
+	if stats.DropNewest != 1 || stats.Enqueued != uint64(n) {
+		t.Fatalf("stats %+v", stats)
+	}
+}
+
+func TestWriteQueueDropOldest(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9962"
+	sockCh, disconnectCh := make(chan xnet.Socket, 1), make(chan struct{})
+	svr := newSlowConsumerServer(ctx, addr, xnet.WriteQueueOptions{Policy: xnet.PolicyDropOldest, MaxMsgs: 4}, sockCh, disconnectCh)
+	defer svr.Close(ctx)
+
+	conn, sock := dialSlowConsumer(addr, sockCh)
+	defer conn.Close()
+
+	// 丢弃旧消息, 写入不返回错误
+	n, err := fillUntilErr(ctx, sock)
+	if err != nil {
+		t.Fatalf("send %v msgs, err %v", n, err)
+	}
+	stats := svr.WriteQueueStats()
+	xlog.Get(ctx).Debug("Drop oldest", zap.Any("stats", stats))
+	if stats.DropOldest == 0 || stats.Enqueued != uint64(n) {
+		t.Fatalf("stats %+v", stats)
+	}
+}
+
+func TestWriteQueueBlock(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9963"
+	sockCh, disconnectCh := make(chan xnet.Socket, 1), make(chan struct{})
+	svr := newSlowConsumerServer(ctx, addr, xnet.WriteQueueOptions{Policy: xnet.PolicyBlock, MaxMsgs: 4, BlockTimeout: 100 * time.Millisecond}, sockCh, disconnectCh)
+	defer svr.Close(ctx)
+
+	conn, sock := dialSlowConsumer(addr, sockCh)
+	defer conn.Close()
+
+	// 阻塞超时
+	begin := time.Now()
+	n, err := fillUntilErr(ctx, sock)
+	if !errors.Is(err, xnet.ErrMsgOverflow) || time.Since(begin) < 100*time.Millisecond {
+		t.Fatalf("send %v msgs, err %v", n, err)
+	}
+	if stats := svr.WriteQueueStats(); stats.BlockTimeouts != 1 {
+		t.Fatalf("stats %+v", stats)
+	}
+
+	// 客户端开始读取, 阻塞写入成功
+	go func() {
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+	sctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	payload := make([]byte, slowPayloadSize)
+	for i := 0; i < 100; i++ {
+		if err := sock.SendMsg(sctx, payload); err != nil {
+			t.Fatalf("send after read err %v", err)
+		}
+	}
+	stats := svr.WriteQueueStats()
+	xlog.Get(ctx).Debug("Block", zap.Any("stats", stats))
+	if stats.BlockWaits < 2 || stats.BlockTimeouts != 1 {
+		t.Fatalf("stats %+v", stats)
+	}
+}
+
+func TestWriteQueueDisconnect(t *testing.T) {
+	ctx := context.Background()
+	addr := "127.0.0.1:9964"
+	sockCh, disconnectCh := make(chan xnet.Socket, 1), make(chan struct{})
+	svr := newSlowConsumerServer(ctx, addr, xnet.WriteQueueOptions{Policy: xnet.PolicyDisconnect, MaxMsgs: 4}, sockCh, disconnectCh)
+	defer svr.Close(ctx)
+
+	conn, sock := dialSlowConsumer(addr, sockCh)
+	defer conn.Close()
+
+	n, err := fillUntilErr(ctx, sock)
+	if !errors.Is(err, xnet.ErrSlowConsumer) {
+		t.Fatalf("send %v msgs, err %v", n, err)
+	}
+	select {
+	case <-disconnectCh:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("slow consumer not disconnected")
+	}
+	if err := sock.SendMsg(ctx, []byte("closed")); !errors.Is(err, xnet.ErrSockClosed) {
+		t.Fatalf("send after disconnect err %v", err)
+	}
+	if stats := svr.WriteQueueStats(); stats.Disconnects != 1 {
+		t.Fatalf("stats %+v", stats)
+	}
+}
@@ -0,0 +1,48 @@
+package xprom
+
+import (
+	"gotu/pkg/xactor"
+	"gotu/pkg/xnet"
+	"sort"
+)
+
+// xnet连接指标, name区分不同server/client
+func NetCollector(name string, m *xnet.MemMetrics) Collector {
+	return CollectorFunc(func(w *Writer) {
+		snap := m.Snapshot()
+		l := L("name", name)
+
+		w.Gauge("gotu_xnet_connections_active", "Active connections.", S(float64(len(snap.Sockets)), l))
+		w.Counter("gotu_xnet_connections_opened_total", "Opened connections.", S(float64(snap.Opened), l))
+
+		reasons := make([]string, 0, len(snap.CloseReasons))
+		for reason := range snap.CloseReasons {
+			reasons = append(reasons, string(reason))
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			w.Counter("gotu_xnet_connections_closed_total", "Closed connections by reason.", S(float64(snap.CloseReasons[xnet.CloseReason(reason)]), l, L("reason", reason)))
+		}
+
+		w.Counter("gotu_xnet_bytes_in_total", "Bytes read.", S(float64(snap.BytesIn), l))
+		w.Counter("gotu_xnet_bytes_out_total", "Bytes written.", S(float64(snap.BytesOut), l))
+		w.Counter("gotu_xnet_messages_in_total", "Messages handled.", S(float64(snap.MsgsIn), l))
+		w.Counter("gotu_xnet_messages_out_total", "Messages written.", S(float64(snap.MsgsOut), l))
+		w.Gauge("gotu_xnet_write_queue_depth", "Queued messages waiting to be written.", S(float64(snap.QueueDepth), l))
+		w.Summary("gotu_xnet_handler_seconds", "Message handler latency.", snap.HandlerTotal.Seconds(), snap.MsgsIn, l)
+		w.Gauge("gotu_xnet_handler_seconds_max", "Max message handler latency.", S(snap.HandlerMax.Seconds(), l))
+	})
+}
+
+// xactor邮箱与处理耗时
+func ActorCollector() Collector {
+	return CollectorFunc(func(w *Writer) {
+		for _, stats := range xactor.Stats() {
+			l := L("actor", stats.Name)
+			w.Gauge("gotu_xactor_mailbox_depth", "Mails waiting in actor mailbox.", S(float64(stats.MailboxDepth), l))
+			w.Gauge("gotu_xactor_mailbox_capacity", "Actor mailbox capacity.", S(float64(stats.MailboxCap), l))
+			w.Summary("gotu_xactor_handler_seconds", "Actor mail handler latency.", stats.HandleTotal.Seconds(), stats.Handled, l)
+			w.Gauge("gotu_xactor_handler_seconds_max", "Max actor mail handler latency.", S(stats.HandleMax.Seconds(), l))
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package xprom
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// 指标采集器, 每次抓取时调用
+type Collector interface {
+	Collect(w *Writer)
+}
+
+type CollectorFunc func(w *Writer)
+
+func (fn CollectorFunc) Collect(w *Writer) {
+	fn(w)
+}
+
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make([]Collector, 0)}
+}
+
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// 采集全部指标并输出
+func (r *Registry) Expose(out io.Writer) error {
+	r.mu.Lock()
+	collectors := append(make([]Collector, 0, len(r.collectors)), r.collectors...)
+	r.mu.Unlock()
+
+	w := newWriter()
+	for _, c := range collectors {
+		c.Collect(w)
+	}
+	return w.writeTo(out)
+}
+
+func (r *Registry) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	var buf bytes.Buffer
+	if err := r.Expose(&buf); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", contentType)
+	_, _ = rw.Write(buf.Bytes())
+}
+
+var defaultRegistry = NewRegistry()
+
+// 注册到默认registry
+func Register(c Collector) {
+	defaultRegistry.Register(c)
+}
+
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
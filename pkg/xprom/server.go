@@ -0,0 +1,68 @@
+package xprom
+
+import (
+	"context"
+	"errors"
+	"gotu/pkg/xcommon"
+	"gotu/pkg/xlog"
+	"net"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+const defaultPath = "/metrics"
+
+type SvrArgs struct {
+	Addr     string
+	Path     string    // 默认/metrics
+	Registry *Registry // 默认使用DefaultRegistry
+}
+
+// 指标http服务
+type Server struct {
+	httpSrv *http.Server
+	addr    net.Addr
+	wg      xcommon.WaitGroup
+}
+
+func NewServer(ctx context.Context, arg SvrArgs) (*Server, error) {
+	if arg.Path == "" {
+		arg.Path = defaultPath
+	}
+	if arg.Registry == nil {
+		arg.Registry = defaultRegistry
+	}
+	listener, err := net.Listen("tcp", arg.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(arg.Path, arg.Registry)
+	svr := &Server{
+		httpSrv: &http.Server{Handler: mux},
+		addr:    listener.Addr(),
+	}
+
+	svr.wg.Add(1)
+	go svr.serve(ctx, listener)
+	xlog.Get(ctx).Info("Metrics server start success.", zap.String("addr", svr.addr.String()), zap.String("path", arg.Path))
+	return svr, nil
+}
+
+func (svr *Server) serve(ctx context.Context, listener net.Listener) {
+	defer svr.wg.Done(ctx)
+	if err := svr.httpSrv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		xlog.Get(ctx).Warn("Metrics server stop failed.", zap.Any("err", err))
+	}
+}
+
+func (svr *Server) Addr() net.Addr {
+	return svr.addr
+}
+
+func (svr *Server) Close(ctx context.Context) {
+	_ = svr.httpSrv.Close()
+	svr.wg.Wait()
+}
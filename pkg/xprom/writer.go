@@ -0,0 +1,139 @@
+package xprom
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// 指标类型
+type MetricType string
+
+const (
+	Counter MetricType = "counter"
+	Gauge   MetricType = "gauge"
+	Summary MetricType = "summary"
+	Untyped MetricType = "untyped"
+)
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+func L(name string, value string) Label {
+	return Label{Name: name, Value: value}
+}
+
+// 单个采样点
+type Sample struct {
+	Suffix string // 名称后缀(summary: _sum, _count)
+	Labels []Label
+	Value  float64
+}
+
+func S(value float64, labels ...Label) Sample {
+	return Sample{Labels: labels, Value: value}
+}
+
+type family struct {
+	name    string
+	help    string
+	typ     MetricType
+	samples []Sample
+}
+
+// 指标收集: 同名指标合并输出(HELP/TYPE只输出一次)
+type Writer struct {
+	families []*family
+	index    map[string]*family
+}
+
+func newWriter() *Writer {
+	return &Writer{index: make(map[string]*family)}
+}
+
+func (w *Writer) family(name string, help string, typ MetricType) *family {
+	if f, ok := w.index[name]; ok {
+		return f
+	}
+	f := &family{name: name, help: help, typ: typ}
+	w.families = append(w.families, f)
+	w.index[name] = f
+	return f
+}
+
+func (w *Writer) Counter(name string, help string, samples ...Sample) {
+	f := w.family(name, help, Counter)
+	f.samples = append(f.samples, samples...)
+}
+
+func (w *Writer) Gauge(name string, help string, samples ...Sample) {
+	f := w.family(name, help, Gauge)
+	f.samples = append(f.samples, samples...)
+}
+
+// summary(不含分位数): _sum, _count
+func (w *Writer) Summary(name string, help string, sum float64, count uint64, labels ...Label) {
+	f := w.family(name, help, Summary)
+	f.samples = append(f.samples, Sample{Suffix: "_sum", Labels: labels, Value: sum}, Sample{Suffix: "_count", Labels: labels, Value: float64(count)})
+}
+
+// summary分位数采样点
+func (w *Writer) Quantile(name string, help string, quantile float64, value float64, labels ...Label) {
+	f := w.family(name, help, Summary)
+	ls := append(append(make([]Label, 0, len(labels)+1), labels...), L("quantile", formatFloat(quantile)))
+	f.samples = append(f.samples, Sample{Labels: ls, Value: value})
+}
+
+// 输出文本格式(text/plain; version=0.0.4)
+func (w *Writer) writeTo(out io.Writer) error {
+	bw := bufio.NewWriter(out)
+	for _, f := range w.families {
+		bw.WriteString("# HELP " + f.name + " " + escapeHelp(f.help) + "\n")
+		bw.WriteString("# TYPE " + f.name + " " + string(f.typ) + "\n")
+		for _, s := range f.samples {
+			bw.WriteString(f.name + s.Suffix)
+			if len(s.Labels) > 0 {
+				bw.WriteByte('{')
+				for i, l := range s.Labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					bw.WriteString(l.Name + "=\"" + escapeLabel(l.Value) + "\"")
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteString(" " + formatFloat(s.Value) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
@@ -0,0 +1,171 @@
+package xprom_test
+
+import (
+	"context"
+	"fmt"
+	"gotu/pkg/xactor"
+	"gotu/pkg/xlog"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"gotu/pkg/xprom"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type promActor struct{}
+
+type PingReq struct{}
+
+type PingResp struct{}
+
+func (a *promActor) InitArg() xactor.ActorHandlerArgs {
+	return xactor.ActorHandlerArgs{Syncs: []xactor.SyncHandlerArgs{xactor.SyncHandlerWrap(a.ping)}}
+}
+
+func (a *promActor) Name() string { return "promActor" }
+
+func (a *promActor) Close(ctx context.Context) {}
+
+func (a *promActor) ping(ctx context.Context, req *PingReq) (*PingResp, error) {
+	return &PingResp{}, nil
+}
+
+// 建立tcp连接并收发count条消息
+func tcpTraffic(ctx context.Context, addr string, svrMetrics xnet.Metrics, count int) (*xnet.TCPServer, *xnet.TCPClient) {
+	svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{
+		Addr:         addr,
+		Metrics:      svrMetrics,
+		OnConnect:    func(ctx context.Context, sock xnet.Socket) interface{} { return sock },
+		OnDisconnect: func(ctx context.Context, state interface{}) {},
+		OnMsg: xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+			msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: arg.Payload})
+			if err != nil {
+				return err
+			}
+			return arg.State.(xnet.Socket).SendMsg(ctx, msg)
+		}),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	var wg sync.WaitGroup
+	cli, err := xnet.NewTCPClient(ctx, xnet.TCPCliArgs{
+		Addr:         addr,
+		OnConnect:    func(ctx context.Context, sock xnet.Socket) interface{} { return nil },
+		OnDisconnect: func(ctx context.Context, state interface{}) {},
+		OnMsg: xmsg.ParseMsgWarp(func(ctx context.Context, arg xmsg.MsgArgs) error {
+			wg.Done()
+			return nil
+		}),
+	})
+	if err != nil {
+		panic(err)
+	}
+	for i := 0; i < count; i++ {
+		msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Payload: []byte(fmt.Sprintf("prom data %v", i))})
+		if err != nil {
+			panic(err)
+		}
+		wg.Add(1)
+		if err := cli.SendMsg(ctx, msg); err != nil {
+			panic(err)
+		}
+	}
+	wg.Wait()
+	return svr, cli
+}
+
+func scrape(url string) (string, string) {
+	resp, err := http.Get(url)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		panic(err)
+	}
+	return resp.Header.Get("Content-Type"), string(body)
+}
+
+func TestScrape(t *testing.T) {
+	ctx := context.Background()
+	svrMetrics, idleMetrics := xnet.NewMemMetrics(), xnet.NewMemMetrics()
+	svr, cli := tcpTraffic(ctx, "127.0.0.1:9941", svrMetrics, 5)
+	defer svr.Close(ctx)
+	defer cli.Close(ctx)
+
+	if err := xactor.NewActorGroutine(ctx, &promActor{}); err != nil {
+		panic(err)
+	}
+	defer xactor.CloseAll(ctx)
+	if _, err := xactor.SyncRequest[PingReq, PingResp](ctx, "promActor", &PingReq{}); err != nil {
+		panic(err)
+	}
+
+	reg := xprom.NewRegistry()
+	reg.Register(xprom.NetCollector("tcp", svrMetrics))
+	reg.Register(xprom.NetCollector("idle", idleMetrics))
+	reg.Register(xprom.ActorCollector())
+	reg.Register(xprom.CollectorFunc(func(w *xprom.Writer) {
+		w.Gauge("gotu_test_escape", "Escape \\ test.", xprom.S(1, xprom.L("v", "a\"b\\c\nd")))
+	}))
+
+	promSvr, err := xprom.NewServer(ctx, xprom.SvrArgs{Addr: "127.0.0.1:9942", Registry: reg})
+	if err != nil {
+		panic(err)
+	}
+	defer promSvr.Close(ctx)
+
+	// 回包写入后计数
+	for i := 0; i < 100 && svrMetrics.Snapshot().MsgsOut != 5; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	contentType, body := scrape("http://127.0.0.1:9942/metrics")
+	xlog.Get(ctx).Debug("Scrape", zap.String("content-type", contentType), zap.String("body", body))
+	if !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Fatalf("content type %v", contentType)
+	}
+
+	lines := []string{
+		`# TYPE gotu_xnet_connections_active gauge`,
+		`gotu_xnet_connections_active{name="tcp"} 1`,
+		`gotu_xnet_connections_active{name="idle"} 0`,
+		`gotu_xnet_connections_opened_total{name="tcp"} 1`,
+		`gotu_xnet_messages_in_total{name="tcp"} 5`,
+		`gotu_xnet_messages_out_total{name="tcp"} 5`,
+		`# TYPE gotu_xnet_handler_seconds summary`,
+		`gotu_xnet_handler_seconds_count{name="tcp"} 5`,
+		`gotu_xnet_write_queue_depth{name="tcp"} 0`,
+		`gotu_xactor_mailbox_depth{actor="promActor"} 0`,
+		`gotu_xactor_handler_seconds_count{actor="promActor"} 1`,
+		`# HELP gotu_test_escape Escape \\ test.`,
+		`gotu_test_escape{v="a\"b\\c\nd"} 1`,
+	}
+	for _, line := range lines {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("missing line %q", line)
+		}
+	}
+	// 同名指标只输出一次HELP/TYPE
+	if n := strings.Count(body, "# TYPE gotu_xnet_bytes_in_total counter"); n != 1 {
+		t.Fatalf("type line count %v", n)
+	}
+
+	// 关闭原因
+	cli.Close(ctx)
+	for i := 0; i < 100 && svrMetrics.Snapshot().Closed != 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, body := scrape("http://127.0.0.1:9942/metrics"); !strings.Contains(body, `gotu_xnet_connections_closed_total{name="tcp",reason="remote"} 1`+"\n") {
+		t.Fatalf("missing close reason")
+	}
+}
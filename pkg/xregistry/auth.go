@@ -0,0 +1,132 @@
+package xregistry
+
+import (
+	"context"
+	"errors"
+	"gotu/pkg/xlog"
+	"gotu/pkg/xmsg"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultAuthDeadline = 10 * time.Second
+
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// 登录结果
+const (
+	LoginOK     int32 = 0
+	LoginFailed int32 = 1 // token校验失败
+	LoginReject int32 = 2 // OnLogin拒绝(如重复登录)
+)
+
+// 用户身份
+type Identity struct {
+	UID  int64
+	Meta map[string]string
+}
+
+// 鉴权: 校验token, 返回用户身份
+type Authenticator interface {
+	Authenticate(ctx context.Context, state *State, token string) (*Identity, error)
+}
+
+type AuthenticatorFunc func(ctx context.Context, state *State, token string) (*Identity, error)
+
+func (fn AuthenticatorFunc) Authenticate(ctx context.Context, state *State, token string) (*Identity, error) {
+	return fn(ctx, state, token)
+}
+
+type AuthArgs struct {
+	Authenticator Authenticator
+	LoginCmd      int32                                                       // 登录cmd, 请求payload为token原始数据
+	AllowCmds     []int32                                                     // 登录前允许的cmd(LoginCmd默认允许)
+	Deadline      time.Duration                                               // 连接后未登录断开时间, 默认10s
+	OnLogin       func(ctx context.Context, state *State, id *Identity) error // 鉴权通过后回调, 返回错误拒绝登录
+}
+
+// 登录响应, 固定binary序列化
+type LoginResp struct {
+	Code int32
+	UID  int64
+}
+
+type authConfig struct {
+	arg       AuthArgs
+	allowCmds map[int32]bool
+}
+
+// 开启登录鉴权: 登录前仅允许AllowCmds, 超时未登录断开连接
+// 鉴权拦截器按调用顺序加入全局拦截器
+func (r *Registry) EnableAuth(arg AuthArgs) {
+	if arg.Deadline <= 0 {
+		arg.Deadline = defaultAuthDeadline
+	}
+	auth := &authConfig{arg: arg, allowCmds: map[int32]bool{arg.LoginCmd: true}}
+	for _, cmd := range arg.AllowCmds {
+		auth.allowCmds[cmd] = true
+	}
+
+	r.Register(arg.LoginCmd, r.login)
+	r.SetCmdSerializer(arg.LoginCmd, BinarySerializer)
+	r.mu.Lock()
+	r.auth = auth
+	r.mu.Unlock()
+	r.Use(auth.intercept)
+}
+
+// 默认注册器开启登录鉴权
+func EnableAuth(arg AuthArgs) {
+	defaultRegistry.EnableAuth(arg)
+}
+
+func (r *Registry) authConfig() *authConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.auth
+}
+
+func (auth *authConfig) intercept(ctx context.Context, state *State, header *xmsg.Header, payload []byte, next Next) error {
+	if !state.Authed() && !auth.allowCmds[header.Cmd] {
+		return ErrUnauthenticated
+	}
+	return next(ctx, state, header, payload)
+}
+
+// 连接建立后开始计时
+// 超时回调在timer协程执行(非连接回调内), 且关闭时不持有state.mu:
+// 读协程退出时OnDisconnect的stopAuthTimer仅停止timer不等待回调, 故Close等待读写协程不会死锁
+func (auth *authConfig) watch(ctx context.Context, state *State) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.authTimer = time.AfterFunc(auth.arg.Deadline, func() {
+		if !state.Authed() {
+			xlog.Get(ctx).Info("Close unauthenticated connection.", zap.Any("addr", state.Sock.RemoteAddr()))
+			if !state.closeSock(ctx) {
+				xlog.Get(ctx).Warn("Socket not closable.", zap.Any("addr", state.Sock.RemoteAddr()))
+			}
+		}
+	})
+}
+
+func (r *Registry) login(ctx context.Context, state *State, req []byte) error {
+	auth := r.authConfig()
+	id, err := auth.arg.Authenticator.Authenticate(ctx, state, string(req))
+	if err != nil {
+		xlog.Get(ctx).Info("Authenticate failed.", zap.Any("err", err), zap.Any("addr", state.Sock.RemoteAddr()))
+		state.SendMsg(ctx, auth.arg.LoginCmd, &LoginResp{Code: LoginFailed})
+		return nil
+	}
+	if auth.arg.OnLogin != nil {
+		if err := auth.arg.OnLogin(ctx, state, id); err != nil {
+			xlog.Get(ctx).Info("Login rejected.", zap.Any("err", err), zap.Int64("uid", id.UID))
+			state.SendMsg(ctx, auth.arg.LoginCmd, &LoginResp{Code: LoginReject, UID: id.UID})
+			return nil
+		}
+	}
+
+	state.login(id)
+	state.SendMsg(ctx, auth.arg.LoginCmd, &LoginResp{Code: LoginOK, UID: id.UID})
+	return nil
+}
@@ -0,0 +1,103 @@
+package xregistry_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xregistry"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	cmdLogin  = int32(301)
+	cmdWhoami = int32(302) // 需登录
+)
+
+type WhoamiResp struct {
+	UID int64
+}
+
+func newAuthRegistry() *xregistry.Registry {
+	r := xregistry.NewRegistry("auth")
+	r.Register(xregistry.CMD_ECHO, xregistry.HandleWarp(xregistry.Echo))
+	r.Register(cmdWhoami, xregistry.HandleWarp(func(ctx context.Context, state *xregistry.State, req *xregistry.EchoReq) error {
+		state.SendMsg(ctx, cmdWhoami, &WhoamiResp{UID: state.UID()})
+		return nil
+	}))
+	r.EnableAuth(xregistry.AuthArgs{
+		// token: "tok-{uid}"
+		Authenticator: xregistry.AuthenticatorFunc(func(ctx context.Context, state *xregistry.State, token string) (*xregistry.Identity, error) {
+			if !strings.HasPrefix(token, "tok-") {
+				return nil, fmt.Errorf("token invalid")
+			}
+			uid, err := strconv.ParseInt(strings.TrimPrefix(token, "tok-"), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			return &xregistry.Identity{UID: uid, Meta: map[string]string{"token": token}}, nil
+		}),
+		LoginCmd:  cmdLogin,
+		AllowCmds: []int32{xregistry.CMD_ECHO},
+		Deadline:  300 * time.Millisecond,
+		OnLogin: func(ctx context.Context, state *xregistry.State, id *xregistry.Identity) error {
+			if id.UID == 0 {
+				return fmt.Errorf("uid 0 banned")
+			}
+			return nil
+		},
+	})
+	return r
+}
+
+func TestAuth(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, fixtureArgs{Registry: newAuthRegistry(), TCP: "127.0.0.1:9929"})
+
+	// 登录前: 白名单cmd正常, 其他cmd断开连接
+	cli := f.dial("tcp", xregistry.ClientArgs{})
+	if _, err := xregistry.Call[xregistry.EchoResp](ctx, cli, xregistry.CMD_ECHO, &xregistry.EchoReq{Num: 1}); err != nil {
+		panic(err)
+	}
+	if _, err := xregistry.Call[WhoamiResp](ctx, cli, cmdWhoami, &xregistry.EchoReq{}); !errors.Is(err, xregistry.ErrDisconnected) {
+		t.Fatalf("unauthenticated err %v", err)
+	}
+	cli.Close(ctx)
+
+	// 登录
+	cli = f.dial("tcp", xregistry.ClientArgs{})
+	if _, err := cli.Login(ctx, cmdLogin, "bad"); !errors.Is(err, xregistry.ErrUnauthenticated) {
+		t.Fatalf("bad token err %v", err)
+	}
+	if resp, err := cli.Login(ctx, cmdLogin, "tok-0"); !errors.Is(err, xregistry.ErrUnauthenticated) || resp.Code != xregistry.LoginReject {
+		t.Fatalf("reject err %v", err)
+	}
+	resp, err := cli.Login(ctx, cmdLogin, "tok-42")
+	if err != nil {
+		panic(err)
+	}
+	if resp.UID != 42 {
+		t.Fatalf("login uid %v", resp.UID)
+	}
+	// 超过deadline仍保持连接
+	time.Sleep(400 * time.Millisecond)
+	whoami, err := xregistry.Call[WhoamiResp](ctx, cli, cmdWhoami, &xregistry.EchoReq{})
+	if err != nil {
+		panic(err)
+	}
+	if whoami.UID != 42 {
+		t.Fatalf("whoami %v", whoami.UID)
+	}
+
+	// 未登录超时断开
+	closeCh := make(chan struct{})
+	f.dialRaw(func(ctx context.Context, arg xmsg.MsgArgs) error { return nil }, func() { close(closeCh) })
+	select {
+	case <-closeCh:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("unauthenticated connection not closed")
+	}
+}
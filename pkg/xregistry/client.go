@@ -0,0 +1,248 @@
+package xregistry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gotu/pkg/xlog"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultCallTimeout = 5 * time.Second
+
+var (
+	ErrCallTimeout  = errors.New("call timeout")
+	ErrDisconnected = errors.New("disconnected")
+)
+
+// xnet客户端(TCPClient/KCPClient/WSClient/UDPClient)
+type Conn interface {
+	SendMsg(ctx context.Context, msg []byte) error
+	Reconnect(ctx context.Context) error
+	Close(ctx context.Context)
+}
+
+type ClientArgs struct {
+	Registry *Registry     // 序列化方案及推送消息(Seq为0或无匹配请求)处理, nil: 默认方案, 丢弃推送
+	Timeout  time.Duration // 请求超时(ctx无deadline时生效), 默认5s
+}
+
+// 请求/响应客户端, 通过Header.Seq匹配响应
+type Client struct {
+	conn     Conn
+	registry *Registry
+	timeout  time.Duration
+
+	seq     int32
+	mu      sync.Mutex
+	pending map[int32]chan xmsg.MsgArgs // seq => 响应
+}
+
+func newClient(arg ClientArgs) *Client {
+	c := &Client{registry: arg.Registry, timeout: arg.Timeout, pending: make(map[int32]chan xmsg.MsgArgs)}
+	if c.registry == nil {
+		c.registry = NewRegistry("client")
+	}
+	if c.timeout <= 0 {
+		c.timeout = defaultCallTimeout
+	}
+	return c
+}
+
+// OnMsg/OnConnect/OnDisconnect由Client接管
+func NewTCPClient(ctx context.Context, cliArg xnet.TCPCliArgs, arg ClientArgs) (*Client, error) {
+	c := newClient(arg)
+	cliArg.OnMsg, cliArg.OnConnect, cliArg.OnDisconnect = xmsg.ParseMsgWarp(c.onMsg), c.onConnect, c.onDisconnect
+	conn, err := xnet.NewTCPClient(ctx, cliArg)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return c, nil
+}
+
+// OnMsg/OnConnect/OnDisconnect由Client接管
+func NewKCPClient(ctx context.Context, cliArg xnet.KCPClientArgs, arg ClientArgs) (*Client, error) {
+	c := newClient(arg)
+	cliArg.OnMsg, cliArg.OnConnect, cliArg.OnDisconnect = xmsg.ParseMsgWarp(c.onMsg), c.onConnect, c.onDisconnect
+	conn, err := xnet.NewKCPClient(ctx, cliArg)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return c, nil
+}
+
+// OnMsg/OnConnect/OnDisconnect由Client接管
+func NewWSClient(ctx context.Context, cliArg xnet.WSCliArgs, arg ClientArgs) (*Client, error) {
+	c := newClient(arg)
+	cliArg.OnMsg, cliArg.OnConnect, cliArg.OnDisconnect = xmsg.ParseMsgWarp(c.onMsg), c.onConnect, c.onDisconnect
+	conn, err := xnet.NewWSClient(ctx, cliArg)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return c, nil
+}
+
+// OnMsg/OnConnect/OnDisconnect由Client接管
+func NewUDPClient(ctx context.Context, cliArg xnet.UDPCliArgs, arg ClientArgs) (*Client, error) {
+	c := newClient(arg)
+	cliArg.OnMsg, cliArg.OnConnect, cliArg.OnDisconnect = xmsg.ParseMsgWarp(c.onMsg), c.onConnect, c.onDisconnect
+	conn, err := xnet.NewUDPClient(ctx, cliArg)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return c, nil
+}
+
+// 请求并等待响应, resp按响应Header.Flag反序列化
+func (c *Client) Call(ctx context.Context, cmd int32, req interface{}, resp interface{}) error {
+	return c.call(ctx, resp, func(seq int32) ([]byte, error) {
+		return c.registry.packMsg(ctx, seq, cmd, req)
+	})
+}
+
+// 请求(payload不经序列化)并等待响应
+func (c *Client) CallRaw(ctx context.Context, cmd int32, payload []byte, resp interface{}) error {
+	return c.call(ctx, resp, func(seq int32) ([]byte, error) {
+		return xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Seq: seq, Cmd: cmd, Payload: payload})
+	})
+}
+
+// 登录, token原始数据作为payload, 登录失败返回ErrUnauthenticated
+func (c *Client) Login(ctx context.Context, loginCmd int32, token string) (*LoginResp, error) {
+	resp := &LoginResp{}
+	if err := c.CallRaw(ctx, loginCmd, []byte(token), resp); err != nil {
+		return nil, err
+	}
+	if resp.Code != LoginOK {
+		return resp, fmt.Errorf("%w: login code[%d]", ErrUnauthenticated, resp.Code)
+	}
+	return resp, nil
+}
+
+func (c *Client) call(ctx context.Context, resp interface{}, pack func(seq int32) ([]byte, error)) error {
+	seq := c.nextSeq()
+	respCh := make(chan xmsg.MsgArgs, 1)
+	c.mu.Lock()
+	c.pending[seq] = respCh
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, seq)
+		c.mu.Unlock()
+	}()
+
+	msg, err := pack(seq)
+	if err != nil {
+		return err
+	}
+	if err := c.conn.SendMsg(ctx, msg); err != nil {
+		return err
+	}
+
+	var timeoutCh <-chan time.Time
+	if _, ok := ctx.Deadline(); !ok {
+		timer := time.NewTimer(c.timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+	select {
+	case arg, ok := <-respCh:
+		if !ok {
+			return ErrDisconnected
+		}
+		serializer, err := GetSerializer(arg.Header.Flag)
+		if err != nil {
+			return err
+		}
+		return serializer.Unmarshal(arg.Payload, resp)
+	case <-timeoutCh:
+		return ErrCallTimeout
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrCallTimeout
+		}
+		return ctx.Err()
+	}
+}
+
+// 请求并等待响应
+func Call[M any](ctx context.Context, c *Client, cmd int32, req interface{}) (*M, error) {
+	resp := new(M)
+	if err := c.Call(ctx, cmd, req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// 单向发送, Seq为0
+func (c *Client) Send(ctx context.Context, cmd int32, data interface{}) error {
+	msg, err := c.registry.packMsg(ctx, 0, cmd, data)
+	if err != nil {
+		return err
+	}
+	return c.conn.SendMsg(ctx, msg)
+}
+
+func (c *Client) Reconnect(ctx context.Context) error {
+	return c.conn.Reconnect(ctx)
+}
+
+func (c *Client) Close(ctx context.Context) {
+	c.conn.Close(ctx)
+}
+
+// 分配Seq, 跳过0(推送)
+func (c *Client) nextSeq() int32 {
+	for {
+		if seq := atomic.AddInt32(&c.seq, 1); seq != 0 {
+			return seq
+		}
+	}
+}
+
+func (c *Client) onConnect(ctx context.Context, sock xnet.Socket) interface{} {
+	return c.registry.OnConnect(ctx, sock)
+}
+
+func (c *Client) onDisconnect(ctx context.Context, state interface{}) {
+	c.registry.OnDisconnect(ctx, state)
+
+	// 等待中的请求立即失败
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for seq, respCh := range c.pending {
+		close(respCh)
+		delete(c.pending, seq)
+	}
+}
+
+func (c *Client) onMsg(ctx context.Context, arg xmsg.MsgArgs) error {
+	if arg.Header.Seq != 0 {
+		c.mu.Lock()
+		respCh, ok := c.pending[arg.Header.Seq]
+		delete(c.pending, arg.Header.Seq)
+		c.mu.Unlock()
+		if ok {
+			// payload为读缓存, 需拷贝
+			respCh <- xmsg.MsgArgs{Header: arg.Header, Payload: xnet.Retain(arg.Payload)}
+			return nil
+		}
+	}
+
+	// 推送消息
+	if !c.registry.Has(arg.Header.Cmd) {
+		xlog.Get(ctx).Debug("Drop msg without handler.", zap.Int32("cmd", arg.Header.Cmd), zap.Int32("seq", arg.Header.Seq))
+		return nil
+	}
+	return c.registry.OnMsg(ctx, arg)
+}
@@ -0,0 +1,177 @@
+package xregistry_test
+
+import (
+	"context"
+	"errors"
+	"gotu/pkg/xregistry"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	cmdDelay = int32(201) // 延迟回包(乱序)
+	cmdDrop  = int32(202) // 不回包
+	cmdPush  = int32(203) // 服务端推送
+	cmdKick  = int32(204) // 服务端断开连接
+)
+
+type DelayReq struct {
+	Num   int32
+	Delay int32 // ms
+}
+
+func newCallRegistry() *xregistry.Registry {
+	r := xregistry.NewRegistry("call")
+	r.Register(xregistry.CMD_ECHO, xregistry.HandleWarp(xregistry.Echo))
+	r.Register(cmdDelay, xregistry.HandleWarp(func(ctx context.Context, state *xregistry.State, req *DelayReq) error {
+		go func() {
+			time.Sleep(time.Duration(req.Delay) * time.Millisecond)
+			state.SendMsg(ctx, cmdDelay, &xregistry.EchoResp{Num: req.Num})
+		}()
+		return nil
+	}))
+	r.Register(cmdDrop, xregistry.HandleWarp(func(ctx context.Context, state *xregistry.State, req *xregistry.EchoReq) error {
+		// 推送不影响等待中的请求
+		state.Push(ctx, cmdPush, &xregistry.EchoResp{Num: req.Num})
+		return nil
+	}))
+	r.Register(cmdKick, func(ctx context.Context, state *xregistry.State, req []byte) error {
+		return errors.New("kick")
+	})
+	return r
+}
+
+func TestCall(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, fixtureArgs{Registry: newCallRegistry(), TCP: "127.0.0.1:9995"})
+
+	// 客户端推送处理
+	pushCh := make(chan int32, 1)
+	cliRegistry := xregistry.NewRegistry("cli")
+	cliRegistry.Register(cmdPush, xregistry.HandleWarp(func(ctx context.Context, state *xregistry.State, resp *xregistry.EchoResp) error {
+		pushCh <- resp.Num
+		return nil
+	}))
+	cli := f.dial("tcp", xregistry.ClientArgs{Registry: cliRegistry, Timeout: 200 * time.Millisecond})
+
+	resp, err := xregistry.Call[xregistry.EchoResp](ctx, cli, xregistry.CMD_ECHO, &xregistry.EchoReq{Num: 7})
+	if err != nil {
+		panic(err)
+	}
+	if resp.Num != 7 {
+		t.Fatalf("echo resp %v", resp.Num)
+	}
+
+	// 乱序回包按Seq匹配
+	var wg sync.WaitGroup
+	for i := int32(0); i < 10; i++ {
+		wg.Add(1)
+		go func(num int32) {
+			defer wg.Done()
+			resp, err := xregistry.Call[xregistry.EchoResp](ctx, cli, cmdDelay, &DelayReq{Num: num, Delay: (10 - num) * 10})
+			if err != nil {
+				t.Errorf("call %v err %v", num, err)
+				return
+			}
+			if resp.Num != num {
+				t.Errorf("call %v resp %v", num, resp.Num)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 超时, 推送正常处理
+	if _, err := xregistry.Call[xregistry.EchoResp](ctx, cli, cmdDrop, &xregistry.EchoReq{Num: 9}); !errors.Is(err, xregistry.ErrCallTimeout) {
+		t.Fatalf("call timeout err %v", err)
+	}
+	select {
+	case num := <-pushCh:
+		if num != 9 {
+			t.Fatalf("push %v", num)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("push timeout")
+	}
+
+	// ctx超时
+	callCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := xregistry.Call[xregistry.EchoResp](callCtx, cli, cmdDrop, &xregistry.EchoReq{}); !errors.Is(err, xregistry.ErrCallTimeout) {
+		t.Fatalf("ctx timeout err %v", err)
+	}
+
+	// 断开连接
+	if _, err := xregistry.Call[xregistry.EchoResp](ctx, cli, cmdKick, &xregistry.EchoReq{}); !errors.Is(err, xregistry.ErrDisconnected) {
+		t.Fatalf("disconnect err %v", err)
+	}
+}
+
+func TestWSCall(t *testing.T) {
+	ctx := context.Background()
+	f := newFixture(t, fixtureArgs{Registry: newCallRegistry(), WS: "127.0.0.1:9994"})
+	cli := f.dial("ws", xregistry.ClientArgs{})
+
+	resp := &xregistry.EchoResp{}
+	if err := cli.Call(ctx, xregistry.CMD_ECHO, &xregistry.EchoReq{Num: 8}, resp); err != nil {
+		panic(err)
+	}
+	if resp.Num != 8 {
+		t.Fatalf("echo resp %v", resp.Num)
+	}
+}
+
+// handler内向其他连接发送: 不回填当前请求Seq, 不会被对端误匹配为其进行中请求的回包
+func TestCallCrossConnection(t *testing.T) {
+	ctx := context.Background()
+	cmdWait, cmdRelay := int32(205), int32(206)
+
+	waitCh := make(chan *xregistry.State, 1)
+	r := xregistry.NewRegistry("cross")
+	r.Register(cmdWait, xregistry.HandleWarp(func(ctx context.Context, state *xregistry.State, req *xregistry.EchoReq) error {
+		waitCh <- state
+		return nil
+	}))
+	r.Register(cmdRelay, xregistry.HandleWarp(func(ctx context.Context, state *xregistry.State, req *xregistry.EchoReq) error {
+		other := <-waitCh
+		other.SendMsg(ctx, cmdPush, &xregistry.EchoResp{Num: req.Num})
+		state.SendMsg(ctx, cmdRelay, &xregistry.EchoResp{Num: req.Num})
+		return nil
+	}))
+	f := newFixture(t, fixtureArgs{Registry: r, TCP: "127.0.0.1:9932"})
+
+	// 等待方: 请求挂起(Seq与发送方请求相同), 推送交由Registry处理
+	pushCh := make(chan int32, 1)
+	waitRegistry := xregistry.NewRegistry("wait")
+	waitRegistry.Register(cmdPush, xregistry.HandleWarp(func(ctx context.Context, state *xregistry.State, resp *xregistry.EchoResp) error {
+		pushCh <- resp.Num
+		return nil
+	}))
+	waiter := f.dial("tcp", xregistry.ClientArgs{Registry: waitRegistry, Timeout: 500 * time.Millisecond})
+	sender := f.dial("tcp", xregistry.ClientArgs{})
+
+	waitErrCh := make(chan error, 1)
+	go func() {
+		_, err := xregistry.Call[xregistry.EchoResp](ctx, waiter, cmdWait, &xregistry.EchoReq{})
+		waitErrCh <- err
+	}()
+
+	resp, err := xregistry.Call[xregistry.EchoResp](ctx, sender, cmdRelay, &xregistry.EchoReq{Num: 5})
+	if err != nil {
+		panic(err)
+	}
+	if resp.Num != 5 {
+		t.Fatalf("relay resp %v", resp.Num)
+	}
+	select {
+	case num := <-pushCh:
+		if num != 5 {
+			t.Fatalf("push %v", num)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("push timeout")
+	}
+	if err := <-waitErrCh; !errors.Is(err, xregistry.ErrCallTimeout) {
+		t.Fatalf("wait call err %v", err)
+	}
+}
@@ -1,55 +1,47 @@
 package xregistry
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
-	"fmt"
-	"gotu/pkg/xlog"
 	"gotu/pkg/xmsg"
 	"gotu/pkg/xnet"
-
-	"go.uber.org/zap"
 )
 
-// handlers: cmd => func
-var handlers = make(map[int32]HandleFunc)
+// 默认注册器, 包级函数均作用于此
+var defaultRegistry = NewRegistry("default")
 
-type HandleFunc func(ctx context.Context, state *State, req []byte) error
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
 
 // 注册回调
-// 非线程安全，无锁处理, init内调用
+// init内调用, cmd重复时panic
 func Register(cmd int32, fn HandleFunc) {
-	if _, ok := handlers[cmd]; ok {
-		panic(fmt.Sprintf("cmd[%d] is repeated.", cmd))
-	}
-	handlers[cmd] = fn
+	defaultRegistry.Register(cmd, fn)
+}
+
+// 设置默认序列化方案(发送时使用, 接收时以Header.Flag为准)
+func SetSerializer(s Serializer) {
+	defaultRegistry.SetSerializer(s)
+}
+
+// 设置cmd序列化方案, 优先于默认方案
+func SetCmdSerializer(cmd int32, s Serializer) {
+	defaultRegistry.SetCmdSerializer(cmd, s)
 }
 
 // 消息处理
 func OnMsg(ctx context.Context, arg xmsg.MsgArgs) error {
-	s := arg.State.(*State)
-
-	// 业务处理
-	handler := handlers[arg.Header.Cmd]
-	if handler != nil {
-		return handler(ctx, s, arg.Payload)
-	} else {
-		return fmt.Errorf("can not find handler[%d]", arg.Header.Cmd)
-	}
+	return defaultRegistry.OnMsg(ctx, arg)
 }
 
 // 建立连接
 func OnConnect(ctx context.Context, sock xnet.Socket) interface{} {
-	xlog.Get(ctx).Debug("Svr connect", zap.Any("addr", sock.RemoteAddr()))
-	return &State{Sock: sock}
+	return defaultRegistry.OnConnect(ctx, sock)
 }
 
 // 断开连接
 func OnDisconnect(ctx context.Context, state interface{}) {
-	// 可做一些逻辑层操作
-	s := state.(*State)
-	xlog.Get(ctx).Debug("Svr disconnect", zap.Any("addr", s.Sock.RemoteAddr()))
+	defaultRegistry.OnDisconnect(ctx, state)
 }
 
 // 函数包装：logic handler => HandleFunc
@@ -57,8 +49,8 @@ func HandleWarp[M1 any](fn func(ctx context.Context, stats *State, req *M1) erro
 	return func(ctx context.Context, state *State, msg []byte) error {
 		req := new(M1)
 
-		// 反序列化
-		if err := Unmarshal(msg, req); err != nil {
+		// 反序列化(Header.Flag指定方案)
+		if err := serializerFrom(ctx).Unmarshal(msg, req); err != nil {
 			return err
 		}
 
@@ -67,39 +59,17 @@ func HandleWarp[M1 any](fn func(ctx context.Context, stats *State, req *M1) erro
 	}
 }
 
-// 序列化(个人选择序列化方案)
-func Marshal(data interface{}) ([]byte, error) {
-	// TODO: proto格式检测, 序列化
-	// pdata, ok := interface{}(data).(protoreflect.ProtoMessage)
-	// if !ok {
-	// 	return nil, fmt.Errorf("data[%v] not proto", data)
-	// }
-	// return proto.Marshal(pdata)
+// 序列化并打包数据包, Header.Flag记录序列化方案
+func PackMsg(ctx context.Context, cmd int32, data interface{}) ([]byte, error) {
+	return defaultRegistry.PackMsg(ctx, cmd, data)
+}
 
-	// 默认序列化方案, 不支持变长数据结构
-	ioWrite := bytes.NewBuffer(nil)
-	if err := binary.Write(ioWrite, binary.LittleEndian, data); err != nil {
-		return nil, err
-	}
-	msg := ioWrite.Bytes()
-	return msg, nil
+// 序列化(binary方案, 对应Header.Flag为0)
+func Marshal(data interface{}) ([]byte, error) {
+	return BinarySerializer.Marshal(data)
 }
 
-// 反序列化(个人选择序列化方案)
+// 反序列化(binary方案, 对应Header.Flag为0)
 func Unmarshal(msg []byte, data interface{}) error {
-	// TODO: proto格式检测,反序列化
-	// pdata, ok := data.(protoreflect.ProtoMessage)
-	// if !ok {
-	// 	return fmt.Errorf("data[%v] not proto", pdata)
-	// }
-	// if err := proto.Unmarshal(msg, pdata); err != nil {
-	// 	return err
-	// }
-
-	// 默认序列化方案, 不支持变长数据结构
-	ioReader := bytes.NewReader(msg)
-	if err := binary.Read(ioReader, binary.LittleEndian, data); err != nil {
-		return err
-	}
-	return nil
+	return BinarySerializer.Unmarshal(msg, data)
 }
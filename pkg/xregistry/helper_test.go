@@ -0,0 +1,101 @@
+package xregistry_test
+
+import (
+	"context"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"gotu/pkg/xregistry"
+	"testing"
+	"time"
+)
+
+// 测试服务端配置: Registry处理各协议连接, 地址为空不监听该协议
+type fixtureArgs struct {
+	Registry *xregistry.Registry
+	TCP      string
+	WS       string // path: "/"
+	KCP      string
+	UDP      string
+}
+
+// 测试服务端及客户端, 测试结束时按创建的逆序关闭
+type fixture struct {
+	t   *testing.T
+	ctx context.Context
+	arg fixtureArgs
+}
+
+func newFixture(t *testing.T, arg fixtureArgs) *fixture {
+	ctx := context.Background()
+	r := arg.Registry
+	if arg.TCP != "" {
+		svr, err := xnet.NewTCPServer(ctx, xnet.TCPSvrArgs{Addr: arg.TCP, OnConnect: r.OnConnect, OnDisconnect: r.OnDisconnect, OnMsg: xmsg.ParseMsgWarp(r.OnMsg)})
+		if err != nil {
+			panic(err)
+		}
+		t.Cleanup(func() { svr.Close(ctx) })
+	}
+	if arg.WS != "" {
+		svr := xnet.NewWSServer(ctx, xnet.WSSvrArgs{Addr: arg.WS, Path: "/", OnConnect: r.OnConnect, OnDisconnect: r.OnDisconnect, OnMsg: xmsg.ParseMsgWarp(r.OnMsg)})
+		t.Cleanup(func() { svr.Close(ctx) })
+		// 等待http服务监听
+		time.Sleep(100 * time.Millisecond)
+	}
+	if arg.KCP != "" {
+		svr, err := xnet.NewKCPServer(ctx, xnet.KCPServerArgs{Addr: arg.KCP, OnConnect: r.OnConnect, OnDisconnect: r.OnDisconnect, OnMsg: xmsg.ParseMsgWarp(r.OnMsg)})
+		if err != nil {
+			panic(err)
+		}
+		t.Cleanup(func() { svr.Close(ctx) })
+	}
+	if arg.UDP != "" {
+		svr, err := xnet.NewUDPServer(ctx, xnet.UDPSvrArgs{Addr: arg.UDP, Timeout: 10, OnConnect: r.OnConnect, OnDisconnect: r.OnDisconnect, OnMsg: xmsg.ParseMsgWarp(r.OnMsg)})
+		if err != nil {
+			panic(err)
+		}
+		t.Cleanup(func() { svr.Close(ctx) })
+	}
+	return &fixture{t: t, ctx: ctx, arg: arg}
+}
+
+// 连接指定协议(tcp/ws/kcp/udp)的xregistry客户端
+func (f *fixture) dial(network string, arg xregistry.ClientArgs) *xregistry.Client {
+	var cli *xregistry.Client
+	var err error
+	switch network {
+	case "tcp":
+		cli, err = xregistry.NewTCPClient(f.ctx, xnet.TCPCliArgs{Addr: f.arg.TCP}, arg)
+	case "ws":
+		cli, err = xregistry.NewWSClient(f.ctx, xnet.WSCliArgs{Addr: f.arg.WS, Path: "/"}, arg)
+	case "kcp":
+		cli, err = xregistry.NewKCPClient(f.ctx, xnet.KCPClientArgs{Addr: f.arg.KCP}, arg)
+	case "udp":
+		cli, err = xregistry.NewUDPClient(f.ctx, xnet.UDPCliArgs{Addr: f.arg.UDP, Timeout: 10}, arg)
+	default:
+		f.t.Fatalf("unknown network %v", network)
+	}
+	if err != nil {
+		panic(err)
+	}
+	f.t.Cleanup(func() { cli.Close(f.ctx) })
+	return cli
+}
+
+// 连接tcp的原始客户端(自行处理收到的消息), onDisconnect可为nil
+func (f *fixture) dialRaw(onMsg func(ctx context.Context, arg xmsg.MsgArgs) error, onDisconnect func()) *xnet.TCPClient {
+	cli, err := xnet.NewTCPClient(f.ctx, xnet.TCPCliArgs{
+		Addr:      f.arg.TCP,
+		OnConnect: func(ctx context.Context, sock xnet.Socket) interface{} { return nil },
+		OnDisconnect: func(ctx context.Context, state interface{}) {
+			if onDisconnect != nil {
+				onDisconnect()
+			}
+		},
+		OnMsg: xmsg.ParseMsgWarp(onMsg),
+	})
+	if err != nil {
+		panic(err)
+	}
+	f.t.Cleanup(func() { cli.Close(f.ctx) })
+	return cli
+}
@@ -0,0 +1,108 @@
+package xregistry
+
+import (
+	"context"
+	"fmt"
+	"gotu/pkg/xlog"
+	"gotu/pkg/xmsg"
+	"runtime/debug"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 拦截器链中的下一环
+type Next func(ctx context.Context, state *State, header *xmsg.Header, payload []byte) error
+
+// 拦截器: 可在next前后处理, 不调用next则中断处理
+type Interceptor func(ctx context.Context, state *State, header *xmsg.Header, payload []byte, next Next) error
+
+// 注册全局拦截器(所有cmd), 按注册顺序执行, 先于cmd拦截器
+func (r *Registry) Use(interceptors ...Interceptor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// 重新分配, 已取出的链不受影响
+	r.interceptors = append(append(make([]Interceptor, 0, len(r.interceptors)+len(interceptors)), r.interceptors...), interceptors...)
+}
+
+// 注册cmd拦截器
+func (r *Registry) UseCmd(cmd int32, interceptors ...Interceptor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := r.cmdInterceptors[cmd]
+	r.cmdInterceptors[cmd] = append(append(make([]Interceptor, 0, len(old)+len(interceptors)), old...), interceptors...)
+}
+
+// 默认注册器全局拦截器
+func Use(interceptors ...Interceptor) {
+	defaultRegistry.Use(interceptors...)
+}
+
+// 默认注册器cmd拦截器
+func UseCmd(cmd int32, interceptors ...Interceptor) {
+	defaultRegistry.UseCmd(cmd, interceptors...)
+}
+
+// 拦截器链: 全局 + cmd
+func (r *Registry) chain(cmd int32) []Interceptor {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cmdInterceptors := r.cmdInterceptors[cmd]
+	if len(cmdInterceptors) == 0 {
+		return r.interceptors
+	}
+	if len(r.interceptors) == 0 {
+		return cmdInterceptors
+	}
+	return append(append(make([]Interceptor, 0, len(r.interceptors)+len(cmdInterceptors)), r.interceptors...), cmdInterceptors...)
+}
+
+func invoke(ctx context.Context, state *State, header *xmsg.Header, payload []byte, chain []Interceptor, final Next) error {
+	if len(chain) == 0 {
+		return final(ctx, state, header, payload)
+	}
+	return chain[0](ctx, state, header, payload, func(ctx context.Context, state *State, header *xmsg.Header, payload []byte) error {
+		return invoke(ctx, state, header, payload, chain[1:], final)
+	})
+}
+
+// 捕获handler panic, 记录堆栈并返回错误(断开连接)
+func Recovery() Interceptor {
+	return func(ctx context.Context, state *State, header *xmsg.Header, payload []byte, next Next) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				xlog.Get(ctx).Sugar().Errorf("Handler panic cmd %v %v stack %v", header.Cmd, r, string(debug.Stack()))
+				err = fmt.Errorf("cmd[%d] panic: %v", header.Cmd, r)
+			}
+		}()
+		return next(ctx, state, header, payload)
+	}
+}
+
+// 请求日志
+func Logging() Interceptor {
+	return func(ctx context.Context, state *State, header *xmsg.Header, payload []byte, next Next) error {
+		begin := time.Now()
+		err := next(ctx, state, header, payload)
+		fields := []zap.Field{zap.Int32("cmd", header.Cmd), zap.Int32("seq", header.Seq), zap.Int("len", len(payload)), zap.Duration("cost", time.Since(begin))}
+		if state != nil && state.Sock != nil {
+			fields = append(fields, zap.Any("addr", state.Sock.RemoteAddr()))
+		}
+		if err != nil {
+			xlog.Get(ctx).Warn("Handle msg failed.", append(fields, zap.Any("err", err))...)
+		} else {
+			xlog.Get(ctx).Debug("Handle msg", fields...)
+		}
+		return err
+	}
+}
+
+// 处理耗时上报
+func Latency(observe func(ctx context.Context, header *xmsg.Header, cost time.Duration, err error)) Interceptor {
+	return func(ctx context.Context, state *State, header *xmsg.Header, payload []byte, next Next) error {
+		begin := time.Now()
+		err := next(ctx, state, header, payload)
+		observe(ctx, header, time.Since(begin), err)
+		return err
+	}
+}
@@ -0,0 +1,83 @@
+package xregistry_test
+
+import (
+	"context"
+	"errors"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xregistry"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 记录执行顺序
+func traceInterceptor(name string, trace *[]string) xregistry.Interceptor {
+	return func(ctx context.Context, state *xregistry.State, header *xmsg.Header, payload []byte, next xregistry.Next) error {
+		*trace = append(*trace, name)
+		return next(ctx, state, header, payload)
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	ctx := context.Background()
+	var trace []string
+	costs := make(map[int32]error)
+
+	r := xregistry.NewRegistry("interceptor")
+	r.Register(1, func(ctx context.Context, state *xregistry.State, req []byte) error {
+		trace = append(trace, "handler")
+		return nil
+	})
+	r.Register(2, func(ctx context.Context, state *xregistry.State, req []byte) error {
+		panic("handler panic")
+	})
+	r.Register(3, func(ctx context.Context, state *xregistry.State, req []byte) error {
+		trace = append(trace, "handler")
+		return nil
+	})
+	r.Use(xregistry.Recovery(), xregistry.Logging(), xregistry.Latency(func(ctx context.Context, header *xmsg.Header, cost time.Duration, err error) {
+		costs[header.Cmd] = err
+	}))
+	r.Use(traceInterceptor("global", &trace))
+	r.UseCmd(1, traceInterceptor("cmd", &trace))
+	// 拦截(如鉴权失败)
+	denied := errors.New("denied")
+	r.UseCmd(3, func(ctx context.Context, state *xregistry.State, header *xmsg.Header, payload []byte, next xregistry.Next) error {
+		return denied
+	})
+
+	onMsg := func(cmd int32) error {
+		return r.OnMsg(ctx, xmsg.MsgArgs{State: &xregistry.State{}, Header: &xmsg.Header{Cmd: cmd}})
+	}
+
+	// 执行顺序: 全局 => cmd => handler
+	if err := onMsg(1); err != nil {
+		panic(err)
+	}
+	if !reflect.DeepEqual(trace, []string{"global", "cmd", "handler"}) {
+		t.Fatalf("trace %v", trace)
+	}
+
+	// panic恢复
+	if err := onMsg(2); err == nil {
+		t.Fatalf("panic not recovered")
+	}
+
+	// 中断处理
+	trace = nil
+	if err := onMsg(3); !errors.Is(err, denied) {
+		t.Fatalf("denied err %v", err)
+	}
+	if !reflect.DeepEqual(trace, []string{"global"}) {
+		t.Fatalf("trace %v", trace)
+	}
+
+	// 未注册cmd同样经过全局拦截器
+	if err := onMsg(4); err == nil {
+		t.Fatalf("unknown cmd")
+	}
+	// panic跳过内层Latency, 由外层Recovery处理
+	if _, ok := costs[2]; len(costs) != 3 || ok || costs[1] != nil || costs[4] == nil {
+		t.Fatalf("latency %v", costs)
+	}
+}
@@ -0,0 +1,209 @@
+package xregistry
+
+import (
+	"context"
+	"fmt"
+	"gotu/pkg/xlog"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xnet"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+type HandleFunc func(ctx context.Context, state *State, req []byte) error
+
+// 注册器: 一组cmd => handler映射及序列化方案, 可对应一个服务端口
+type Registry struct {
+	name string
+
+	mu                sync.RWMutex
+	handlers          map[int32]HandleFunc    // cmd => func
+	defaultSerializer Serializer              // 默认序列化方案
+	cmdSerializers    map[int32]Serializer    // cmd => 序列化方案
+	interceptors      []Interceptor           // 全局拦截器
+	cmdInterceptors   map[int32][]Interceptor // cmd => 拦截器
+	auth              *authConfig             // 登录鉴权, nil: 不开启
+	disconnectHooks   []func(ctx context.Context, state *State)
+}
+
+func NewRegistry(name string) *Registry {
+	return &Registry{
+		name:              name,
+		handlers:          make(map[int32]HandleFunc),
+		defaultSerializer: BinarySerializer,
+		cmdSerializers:    make(map[int32]Serializer),
+		cmdInterceptors:   make(map[int32][]Interceptor),
+	}
+}
+
+func (r *Registry) Name() string {
+	return r.name
+}
+
+// 注册回调, cmd重复时panic
+func (r *Registry) Register(cmd int32, fn HandleFunc) {
+	if err := r.TryRegister(cmd, fn); err != nil {
+		panic(err)
+	}
+}
+
+// 注册回调, cmd重复时返回错误
+func (r *Registry) TryRegister(cmd int32, fn HandleFunc) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.handlers[cmd]; ok {
+		return fmt.Errorf("registry[%s] cmd[%d] is repeated", r.name, cmd)
+	}
+	r.handlers[cmd] = fn
+	return nil
+}
+
+// cmd是否已注册
+func (r *Registry) Has(cmd int32) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.handlers[cmd]
+	return ok
+}
+
+// 已注册cmd列表(升序)
+func (r *Registry) Cmds() []int32 {
+	r.mu.RLock()
+	cmds := make([]int32, 0, len(r.handlers))
+	for cmd := range r.handlers {
+		cmds = append(cmds, cmd)
+	}
+	r.mu.RUnlock()
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i] < cmds[j] })
+	return cmds
+}
+
+// 设置默认序列化方案(发送时使用, 接收时以Header.Flag为准)
+func (r *Registry) SetSerializer(s Serializer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaultSerializer = s
+}
+
+// 设置cmd序列化方案, 优先于默认方案
+func (r *Registry) SetCmdSerializer(cmd int32, s Serializer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cmdSerializers[cmd] = s
+}
+
+func (r *Registry) serializerOf(cmd int32) Serializer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if s, ok := r.cmdSerializers[cmd]; ok {
+		return s
+	}
+	return r.defaultSerializer
+}
+
+func (r *Registry) handler(cmd int32) HandleFunc {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.handlers[cmd]
+}
+
+// 消息处理
+func (r *Registry) OnMsg(ctx context.Context, arg xmsg.MsgArgs) error {
+	s := arg.State.(*State)
+
+	// 序列化方案
+	serializer, err := GetSerializer(arg.Header.Flag)
+	if err != nil {
+		return err
+	}
+
+	// 拦截器 => 业务处理
+	ctx = withReq(ctx, s, serializer, arg.Header.Seq)
+	return invoke(ctx, s, arg.Header, arg.Payload, r.chain(arg.Header.Cmd), r.handle)
+}
+
+func (r *Registry) handle(ctx context.Context, state *State, header *xmsg.Header, payload []byte) error {
+	handler := r.handler(header.Cmd)
+	if handler != nil {
+		return handler(ctx, state, payload)
+	} else {
+		return fmt.Errorf("registry[%s] can not find handler[%d]", r.name, header.Cmd)
+	}
+}
+
+// 建立连接
+func (r *Registry) OnConnect(ctx context.Context, sock xnet.Socket) interface{} {
+	xlog.Get(ctx).Debug("Svr connect", zap.String("registry", r.name), zap.Any("addr", sock.RemoteAddr()))
+	state := &State{Sock: sock, registry: r}
+	if auth := r.authConfig(); auth != nil {
+		auth.watch(ctx, state)
+	}
+	return state
+}
+
+// 断开连接
+func (r *Registry) OnDisconnect(ctx context.Context, state interface{}) {
+	// 可做一些逻辑层操作
+	s := state.(*State)
+	s.stopAuthTimer()
+	xlog.Get(ctx).Debug("Svr disconnect", zap.String("registry", r.name), zap.Any("addr", s.Sock.RemoteAddr()))
+
+	r.mu.RLock()
+	hooks := r.disconnectHooks
+	r.mu.RUnlock()
+	for _, hook := range hooks {
+		hook(ctx, s)
+	}
+}
+
+// 连接断开回调(如会话/订阅清理), 按注册顺序执行
+func (r *Registry) AddDisconnectHook(hook func(ctx context.Context, state *State)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.disconnectHooks = append(append(make([]func(ctx context.Context, state *State), 0, len(r.disconnectHooks)+1), r.disconnectHooks...), hook)
+}
+
+// 序列化并打包数据包, Header.Flag记录序列化方案
+// 目标连接未知, Seq为0(回包使用State.SendMsg)
+func (r *Registry) PackMsg(ctx context.Context, cmd int32, data interface{}) ([]byte, error) {
+	return r.packMsg(ctx, 0, cmd, data)
+}
+
+func (r *Registry) packMsg(ctx context.Context, seq int32, cmd int32, data interface{}) ([]byte, error) {
+	serializer := r.serializerOf(cmd)
+	payload, err := serializer.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Seq: seq, Cmd: cmd, Flag: serializer.Type(), Payload: payload})
+}
+
+type reqKey struct{}
+
+// 当前处理的请求信息
+type reqInfo struct {
+	origin     *State     // 请求来源连接
+	serializer Serializer // 请求序列化方案
+	seq        int32      // 请求Seq, 向来源连接回包时回填
+}
+
+func withReq(ctx context.Context, origin *State, serializer Serializer, seq int32) context.Context {
+	return context.WithValue(ctx, reqKey{}, &reqInfo{origin: origin, serializer: serializer, seq: seq})
+}
+
+func serializerFrom(ctx context.Context) Serializer {
+	if info, ok := ctx.Value(reqKey{}).(*reqInfo); ok {
+		return info.serializer
+	}
+	return BinarySerializer
+}
+
+// 向s发送消息的Seq: 仅回复请求来源连接时回填请求Seq, 其他连接为0(推送), 避免被误匹配为其进行中请求的回包
+func seqFor(ctx context.Context, s *State) int32 {
+	if info, ok := ctx.Value(reqKey{}).(*reqInfo); ok && info.origin == s {
+		return info.seq
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package xregistry_test
+
+import (
+	"context"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xregistry"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 同一cmd在不同注册器返回不同结果
+func newNumRegistry(name string, num int32) *xregistry.Registry {
+	r := xregistry.NewRegistry(name)
+	r.Register(xregistry.CMD_ECHO, xregistry.HandleWarp(func(ctx context.Context, state *xregistry.State, req *xregistry.EchoReq) error {
+		state.SendMsg(ctx, xregistry.CMD_ECHO, &xregistry.EchoResp{Num: req.Num + num})
+		return nil
+	}))
+	return r
+}
+
+func echoNum(ctx context.Context, t *testing.T, r *xregistry.Registry, addr string) int32 {
+	f := newFixture(t, fixtureArgs{Registry: r, TCP: addr})
+	numCh := make(chan int32, 1)
+	cli := f.dialRaw(func(ctx context.Context, arg xmsg.MsgArgs) error {
+		resp := &xregistry.EchoResp{}
+		if err := xregistry.Unmarshal(arg.Payload, resp); err != nil {
+			return err
+		}
+		numCh <- resp.Num
+		return nil
+	}, nil)
+
+	msg, err := r.PackMsg(ctx, xregistry.CMD_ECHO, &xregistry.EchoReq{Num: 1})
+	if err != nil {
+		panic(err)
+	}
+	if err := cli.SendMsg(ctx, msg); err != nil {
+		panic(err)
+	}
+	select {
+	case num := <-numCh:
+		return num
+	case <-time.After(3 * time.Second):
+		t.Fatalf("recv timeout")
+	}
+	return 0
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	gateway, admin := newNumRegistry("gateway", 100), newNumRegistry("admin", 200)
+	if num := echoNum(ctx, t, gateway, "127.0.0.1:9997"); num != 101 {
+		t.Fatalf("gateway num %v", num)
+	}
+	if num := echoNum(ctx, t, admin, "127.0.0.1:9996"); num != 201 {
+		t.Fatalf("admin num %v", num)
+	}
+
+	// 重复检测
+	if err := admin.TryRegister(xregistry.CMD_ECHO, nil); err == nil {
+		t.Fatalf("repeated cmd")
+	}
+	if err := admin.TryRegister(3, func(ctx context.Context, state *xregistry.State, req []byte) error { return nil }); err != nil {
+		panic(err)
+	}
+
+	// 列表
+	if cmds := admin.Cmds(); !reflect.DeepEqual(cmds, []int32{xregistry.CMD_ECHO, 3}) {
+		t.Fatalf("admin cmds %v", cmds)
+	}
+	if gateway.Has(3) || !admin.Has(3) {
+		t.Fatalf("registry not isolated")
+	}
+	if !xregistry.DefaultRegistry().Has(xregistry.CMD_ECHO) {
+		t.Fatalf("default registry missing echo")
+	}
+}
@@ -0,0 +1,120 @@
+package xregistry
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// 序列化类型, 记录在Header.Flag低8位
+const (
+	SerializerBinary int32 = 0 // encoding/binary, 仅支持定长结构
+	SerializerJSON   int32 = 1
+	SerializerProto  int32 = 2 // proto.Message
+
+	SerializerMask int32 = 0xff
+)
+
+// 序列化方案
+type Serializer interface {
+	Type() int32 // 序列化类型(0~255)
+	Marshal(data interface{}) ([]byte, error)
+	Unmarshal(msg []byte, data interface{}) error
+}
+
+var (
+	BinarySerializer Serializer = binarySerializer{}
+	JSONSerializer   Serializer = jsonSerializer{}
+	ProtoSerializer  Serializer = protoSerializer{}
+)
+
+var serializers = struct {
+	mu    sync.RWMutex
+	types map[int32]Serializer
+}{types: map[int32]Serializer{
+	SerializerBinary: BinarySerializer,
+	SerializerJSON:   JSONSerializer,
+	SerializerProto:  ProtoSerializer,
+}}
+
+// 注册自定义序列化方案
+func RegisterSerializer(s Serializer) {
+	if s.Type() < 0 || s.Type() > SerializerMask {
+		panic(fmt.Sprintf("serializer type[%d] out of range.", s.Type()))
+	}
+	serializers.mu.Lock()
+	defer serializers.mu.Unlock()
+	if _, ok := serializers.types[s.Type()]; ok {
+		panic(fmt.Sprintf("serializer type[%d] is repeated.", s.Type()))
+	}
+	serializers.types[s.Type()] = s
+}
+
+// 根据Header.Flag获取序列化方案
+func GetSerializer(flag int32) (Serializer, error) {
+	serializers.mu.RLock()
+	defer serializers.mu.RUnlock()
+	if s, ok := serializers.types[flag&SerializerMask]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("can not find serializer[%d]", flag&SerializerMask)
+}
+
+type binarySerializer struct{}
+
+func (binarySerializer) Type() int32 {
+	return SerializerBinary
+}
+
+// 不支持变长数据结构(string/slice/map)
+func (binarySerializer) Marshal(data interface{}) ([]byte, error) {
+	ioWrite := bytes.NewBuffer(nil)
+	if err := binary.Write(ioWrite, binary.LittleEndian, data); err != nil {
+		return nil, err
+	}
+	return ioWrite.Bytes(), nil
+}
+
+func (binarySerializer) Unmarshal(msg []byte, data interface{}) error {
+	return binary.Read(bytes.NewReader(msg), binary.LittleEndian, data)
+}
+
+type jsonSerializer struct{}
+
+func (jsonSerializer) Type() int32 {
+	return SerializerJSON
+}
+
+func (jsonSerializer) Marshal(data interface{}) ([]byte, error) {
+	return json.Marshal(data)
+}
+
+func (jsonSerializer) Unmarshal(msg []byte, data interface{}) error {
+	return json.Unmarshal(msg, data)
+}
+
+type protoSerializer struct{}
+
+func (protoSerializer) Type() int32 {
+	return SerializerProto
+}
+
+func (protoSerializer) Marshal(data interface{}) ([]byte, error) {
+	pdata, ok := data.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("data[%T] not proto", data)
+	}
+	return proto.Marshal(pdata)
+}
+
+func (protoSerializer) Unmarshal(msg []byte, data interface{}) error {
+	pdata, ok := data.(proto.Message)
+	if !ok {
+		return fmt.Errorf("data[%T] not proto", data)
+	}
+	return proto.Unmarshal(msg, pdata)
+}
@@ -0,0 +1,98 @@
+package xregistry_test
+
+import (
+	"context"
+	"gotu/pkg/xmsg"
+	"gotu/pkg/xregistry"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var (
+	cmdJSON  = int32(101)
+	cmdProto = int32(102)
+)
+
+type UserReq struct {
+	Name  string
+	Tags  []string
+	Attrs map[string]int
+}
+
+func newSerializerRegistry() *xregistry.Registry {
+	r := xregistry.NewRegistry("serializer")
+	r.SetCmdSerializer(cmdJSON, xregistry.JSONSerializer)
+	r.Register(cmdJSON, xregistry.HandleWarp(func(ctx context.Context, state *xregistry.State, req *UserReq) error {
+		state.SendMsg(ctx, cmdJSON, req)
+		return nil
+	}))
+	r.SetCmdSerializer(cmdProto, xregistry.ProtoSerializer)
+	r.Register(cmdProto, xregistry.HandleWarp(func(ctx context.Context, state *xregistry.State, req *wrapperspb.StringValue) error {
+		state.SendMsg(ctx, cmdProto, wrapperspb.String("re: "+req.Value))
+		return nil
+	}))
+	return r
+}
+
+func TestSerializer(t *testing.T) {
+	ctx := context.Background()
+	r := newSerializerRegistry()
+	f := newFixture(t, fixtureArgs{Registry: r, TCP: "127.0.0.1:9998"})
+	respCh := make(chan xmsg.MsgArgs, 2)
+	cli := f.dialRaw(func(ctx context.Context, arg xmsg.MsgArgs) error {
+		respCh <- xmsg.MsgArgs{Header: arg.Header, Payload: append([]byte(nil), arg.Payload...)}
+		return nil
+	}, nil)
+
+	recv := func(data interface{}) {
+		select {
+		case arg := <-respCh:
+			serializer, err := xregistry.GetSerializer(arg.Header.Flag)
+			if err != nil {
+				panic(err)
+			}
+			if err := serializer.Unmarshal(arg.Payload, data); err != nil {
+				panic(err)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("recv timeout")
+		}
+	}
+
+	// json: 变长数据结构
+	req := &UserReq{Name: "gotu", Tags: []string{"a", "b"}, Attrs: map[string]int{"lv": 10}}
+	msg, err := r.PackMsg(ctx, cmdJSON, req)
+	if err != nil {
+		panic(err)
+	}
+	if err := cli.SendMsg(ctx, msg); err != nil {
+		panic(err)
+	}
+	resp := &UserReq{}
+	recv(resp)
+	if !reflect.DeepEqual(req, resp) {
+		t.Fatalf("json resp %v", resp)
+	}
+
+	// protobuf
+	msg, err = r.PackMsg(ctx, cmdProto, wrapperspb.String("hello"))
+	if err != nil {
+		panic(err)
+	}
+	if err := cli.SendMsg(ctx, msg); err != nil {
+		panic(err)
+	}
+	presp := &wrapperspb.StringValue{}
+	recv(presp)
+	if presp.Value != "re: hello" {
+		t.Fatalf("proto resp %v", presp)
+	}
+
+	// 未知序列化方案
+	if _, err := xregistry.GetSerializer(0x7f); err == nil {
+		t.Fatalf("unknown serializer")
+	}
+}
@@ -0,0 +1,260 @@
+package xregistry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gotu/pkg/xlog"
+	"gotu/pkg/xmsg"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+var ErrDuplicateLogin = errors.New("duplicate login")
+
+// 重复登录策略
+type DupLoginPolicy int
+
+const (
+	DupKickOld   DupLoginPolicy = iota // 踢掉旧连接(默认)
+	DupRejectNew                       // 拒绝新连接
+)
+
+type SessionArgs struct {
+	Policy  DupLoginPolicy
+	KickCmd int32 // 踢下线通知cmd(空payload), 0: 不通知
+}
+
+// 会话管理: 按用户ID/标签索引在线State, 支持踢下线及广播
+type SessionManager struct {
+	registry *Registry
+	arg      SessionArgs
+
+	mu     sync.RWMutex
+	states map[*State]*sessionEntry       // 所有会话
+	users  map[int64]*State               // uid => State
+	tags   map[string]map[*State]struct{} // tag => States
+}
+
+type sessionEntry struct {
+	uid  int64 // 0: 未绑定用户
+	tags map[string]struct{}
+}
+
+// 连接断开时自动清理
+func NewSessionManager(r *Registry, arg SessionArgs) *SessionManager {
+	m := &SessionManager{
+		registry: r,
+		arg:      arg,
+		states:   make(map[*State]*sessionEntry),
+		users:    make(map[int64]*State),
+		tags:     make(map[string]map[*State]struct{}),
+	}
+	r.AddDisconnectHook(m.Remove)
+	return m
+}
+
+// 可作为AuthArgs.OnLogin
+func (m *SessionManager) OnLogin(ctx context.Context, state *State, id *Identity) error {
+	return m.Bind(ctx, state, id.UID)
+}
+
+// 绑定用户ID, 按重复登录策略处理
+func (m *SessionManager) Bind(ctx context.Context, state *State, uid int64) error {
+	if uid == 0 {
+		return fmt.Errorf("uid 0 invalid")
+	}
+	m.mu.Lock()
+	old := m.users[uid]
+	if old != nil && old != state && m.arg.Policy == DupRejectNew {
+		m.mu.Unlock()
+		return ErrDuplicateLogin
+	}
+	if old != nil && old != state {
+		m.removeLocked(old)
+	}
+	entry := m.entryLocked(state)
+	if entry.uid != 0 && entry.uid != uid {
+		delete(m.users, entry.uid)
+	}
+	entry.uid = uid
+	m.users[uid] = state
+	m.mu.Unlock()
+
+	if old != nil && old != state {
+		xlog.Get(ctx).Info("Kick duplicate login.", zap.Int64("uid", uid), zap.Any("addr", old.Sock.RemoteAddr()))
+		m.kick(ctx, old)
+	}
+	return nil
+}
+
+// 断开用户连接
+func (m *SessionManager) Kick(ctx context.Context, uid int64) bool {
+	m.mu.Lock()
+	state := m.users[uid]
+	if state != nil {
+		m.removeLocked(state)
+	}
+	m.mu.Unlock()
+
+	if state == nil {
+		return false
+	}
+	m.kick(ctx, state)
+	return true
+}
+
+// 通知并异步关闭(调用方可能处于其他连接的回调内)
+func (m *SessionManager) kick(ctx context.Context, state *State) {
+	if m.arg.KickCmd != 0 {
+		if msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Cmd: m.arg.KickCmd}); err == nil {
+			_ = state.Sock.SendMsg(ctx, msg)
+		}
+	}
+	go state.closeSock(ctx)
+}
+
+// 移除会话(连接断开时自动调用)
+func (m *SessionManager) Remove(ctx context.Context, state *State) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeLocked(state)
+}
+
+func (m *SessionManager) removeLocked(state *State) {
+	entry, ok := m.states[state]
+	if !ok {
+		return
+	}
+	if entry.uid != 0 && m.users[entry.uid] == state {
+		delete(m.users, entry.uid)
+	}
+	for tag := range entry.tags {
+		m.untagLocked(state, tag)
+	}
+	delete(m.states, state)
+}
+
+func (m *SessionManager) entryLocked(state *State) *sessionEntry {
+	entry, ok := m.states[state]
+	if !ok {
+		entry = &sessionEntry{tags: make(map[string]struct{})}
+		m.states[state] = entry
+	}
+	return entry
+}
+
+// 添加标签(如房间/渠道)
+func (m *SessionManager) Tag(state *State, tags ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.entryLocked(state)
+	for _, tag := range tags {
+		entry.tags[tag] = struct{}{}
+		if _, ok := m.tags[tag]; !ok {
+			m.tags[tag] = make(map[*State]struct{})
+		}
+		m.tags[tag][state] = struct{}{}
+	}
+}
+
+// 移除标签
+func (m *SessionManager) Untag(state *State, tags ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, tag := range tags {
+		m.untagLocked(state, tag)
+	}
+}
+
+func (m *SessionManager) untagLocked(state *State, tag string) {
+	if entry, ok := m.states[state]; ok {
+		delete(entry.tags, tag)
+	}
+	if states, ok := m.tags[tag]; ok {
+		delete(states, state)
+		if len(states) == 0 {
+			delete(m.tags, tag)
+		}
+	}
+}
+
+// 根据用户ID查找, 不在线返回nil
+func (m *SessionManager) Get(uid int64) *State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.users[uid]
+}
+
+// 标签下的会话
+func (m *SessionManager) Tagged(tag string) []*State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	states := make([]*State, 0, len(m.tags[tag]))
+	for state := range m.tags[tag] {
+		states = append(states, state)
+	}
+	return states
+}
+
+// 会话数量
+func (m *SessionManager) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.states)
+}
+
+// 广播所有会话, 返回发送成功数量
+func (m *SessionManager) Broadcast(ctx context.Context, cmd int32, data interface{}) (int, error) {
+	m.mu.RLock()
+	states := make([]*State, 0, len(m.states))
+	for state := range m.states {
+		states = append(states, state)
+	}
+	m.mu.RUnlock()
+	return m.send(ctx, states, cmd, data)
+}
+
+// 发送给指定用户
+func (m *SessionManager) Multicast(ctx context.Context, uids []int64, cmd int32, data interface{}) (int, error) {
+	m.mu.RLock()
+	states := make([]*State, 0, len(uids))
+	for _, uid := range uids {
+		if state, ok := m.users[uid]; ok {
+			states = append(states, state)
+		}
+	}
+	m.mu.RUnlock()
+	return m.send(ctx, states, cmd, data)
+}
+
+// 发送给标签下的会话
+func (m *SessionManager) MulticastTag(ctx context.Context, tag string, cmd int32, data interface{}) (int, error) {
+	return m.send(ctx, m.Tagged(tag), cmd, data)
+}
+
+// 序列化一次, 分发到各连接
+func (m *SessionManager) send(ctx context.Context, states []*State, cmd int32, data interface{}) (int, error) {
+	if len(states) == 0 {
+		return 0, nil
+	}
+	msg, err := m.registry.packMsg(ctx, 0, cmd, data)
+	if err != nil {
+		return 0, err
+	}
+	return SendStates(ctx, states, msg), nil
+}
+
+// 发送已打包数据, 返回发送成功数量
+func SendStates(ctx context.Context, states []*State, msg []byte) int {
+	count := 0
+	for _, state := range states {
+		if err := state.Sock.SendMsg(ctx, msg); err != nil {
+			xlog.Get(ctx).Debug("Send msg failed.", zap.Any("err", err), zap.Any("addr", state.Sock.RemoteAddr()))
+			continue
+		}
+		count++
+	}
+	return count
+}
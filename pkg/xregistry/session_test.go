@@ -0,0 +1,168 @@
+package xregistry_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gotu/pkg/xregistry"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+var (
+	cmdSessLogin = int32(401)
+	cmdJoin      = int32(402) // 加入房间
+	cmdNotice    = int32(403) // 推送
+	cmdKicked    = int32(404) // 踢下线通知
+)
+
+type NoticeMsg struct {
+	Num int32
+}
+
+func newSessionRegistry() (*xregistry.Registry, *xregistry.SessionManager) {
+	r := xregistry.NewRegistry("session")
+	m := xregistry.NewSessionManager(r, xregistry.SessionArgs{Policy: xregistry.DupKickOld, KickCmd: cmdKicked})
+	r.EnableAuth(xregistry.AuthArgs{
+		Authenticator: xregistry.AuthenticatorFunc(func(ctx context.Context, state *xregistry.State, token string) (*xregistry.Identity, error) {
+			var uid int64
+			if _, err := fmt.Sscanf(token, "uid-%d", &uid); err != nil {
+				return nil, err
+			}
+			return &xregistry.Identity{UID: uid}, nil
+		}),
+		LoginCmd: cmdSessLogin,
+		OnLogin:  m.OnLogin,
+	})
+	r.Register(cmdJoin, xregistry.HandleWarp(func(ctx context.Context, state *xregistry.State, req *xregistry.EchoReq) error {
+		m.Tag(state, "room")
+		state.SendMsg(ctx, cmdJoin, &xregistry.EchoResp{Num: req.Num})
+		return nil
+	}))
+	return r, m
+}
+
+// 推送记录到noticeCh
+func newNoticeRegistry(name string, noticeCh chan string) *xregistry.Registry {
+	r := xregistry.NewRegistry(name)
+	r.Register(cmdNotice, xregistry.HandleWarp(func(ctx context.Context, state *xregistry.State, msg *NoticeMsg) error {
+		noticeCh <- name
+		return nil
+	}))
+	r.Register(cmdKicked, func(ctx context.Context, state *xregistry.State, req []byte) error {
+		noticeCh <- name + "-kicked"
+		return nil
+	})
+	return r
+}
+
+func recvNotices(t *testing.T, noticeCh chan string, count int) []string {
+	names := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		select {
+		case name := <-noticeCh:
+			names = append(names, name)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("recv notice timeout %v", names)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSession(t *testing.T) {
+	ctx := context.Background()
+	r, m := newSessionRegistry()
+	f := newFixture(t, fixtureArgs{Registry: r, TCP: "127.0.0.1:9921", WS: "127.0.0.1:9922", KCP: "127.0.0.1:9923", UDP: "127.0.0.1:9924"})
+
+	// 各协议客户端登录: tcp(1) ws(2) kcp(3) udp(4)
+	noticeCh := make(chan string, 16)
+	clients := make([]*xregistry.Client, 0, 4)
+	for _, name := range []string{"tcp", "ws", "kcp", "udp"} {
+		cli := f.dial(name, xregistry.ClientArgs{Registry: newNoticeRegistry(name, noticeCh)})
+		if _, err := cli.Login(ctx, cmdSessLogin, fmt.Sprintf("uid-%d", len(clients)+1)); err != nil {
+			panic(err)
+		}
+		clients = append(clients, cli)
+	}
+	if m.Count() != 4 || m.Get(3) == nil {
+		t.Fatalf("session count %v", m.Count())
+	}
+
+	// 广播
+	if n, err := m.Broadcast(ctx, cmdNotice, &NoticeMsg{Num: 1}); err != nil || n != 4 {
+		t.Fatalf("broadcast %v %v", n, err)
+	}
+	if names := recvNotices(t, noticeCh, 4); !reflect.DeepEqual(names, []string{"kcp", "tcp", "udp", "ws"}) {
+		t.Fatalf("broadcast notices %v", names)
+	}
+
+	// 指定用户
+	if n, err := m.Multicast(ctx, []int64{2, 4, 5}, cmdNotice, &NoticeMsg{Num: 2}); err != nil || n != 2 {
+		t.Fatalf("multicast %v %v", n, err)
+	}
+	if names := recvNotices(t, noticeCh, 2); !reflect.DeepEqual(names, []string{"udp", "ws"}) {
+		t.Fatalf("multicast notices %v", names)
+	}
+
+	// 标签
+	for _, cli := range []*xregistry.Client{clients[0], clients[2]} {
+		if _, err := xregistry.Call[xregistry.EchoResp](ctx, cli, cmdJoin, &xregistry.EchoReq{}); err != nil {
+			panic(err)
+		}
+	}
+	if n, err := m.MulticastTag(ctx, "room", cmdNotice, &NoticeMsg{Num: 3}); err != nil || n != 2 {
+		t.Fatalf("multicast tag %v %v", n, err)
+	}
+	if names := recvNotices(t, noticeCh, 2); !reflect.DeepEqual(names, []string{"kcp", "tcp"}) {
+		t.Fatalf("multicast tag notices %v", names)
+	}
+
+	// 重复登录踢掉旧连接
+	dup := f.dial("tcp", xregistry.ClientArgs{Registry: newNoticeRegistry("dup", noticeCh)})
+	if _, err := dup.Login(ctx, cmdSessLogin, "uid-1"); err != nil {
+		panic(err)
+	}
+	if names := recvNotices(t, noticeCh, 1); !reflect.DeepEqual(names, []string{"tcp-kicked"}) {
+		t.Fatalf("kick notices %v", names)
+	}
+	if _, err := xregistry.Call[xregistry.EchoResp](ctx, clients[0], cmdJoin, &xregistry.EchoReq{}); err == nil {
+		t.Fatalf("kicked client still connected")
+	}
+	if tagged := m.Tagged("room"); len(tagged) != 1 {
+		t.Fatalf("tagged %v", len(tagged))
+	}
+
+	// 主动踢下线, 断开后清理
+	if !m.Kick(ctx, 2) {
+		t.Fatalf("kick ws failed")
+	}
+	recvNotices(t, noticeCh, 1)
+	if m.Get(2) != nil || m.Get(1) == nil || m.Count() != 3 {
+		t.Fatalf("session after kick %v", m.Count())
+	}
+	dup.Close(ctx)
+	for i := 0; i < 100 && m.Count() != 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if m.Count() != 2 || m.Get(1) != nil || len(m.Tagged("room")) != 1 {
+		t.Fatalf("session after disconnect %v", m.Count())
+	}
+}
+
+func TestSessionRejectNew(t *testing.T) {
+	ctx := context.Background()
+	m := xregistry.NewSessionManager(xregistry.NewRegistry("reject"), xregistry.SessionArgs{Policy: xregistry.DupRejectNew})
+	first, second := &xregistry.State{}, &xregistry.State{}
+	if err := m.Bind(ctx, first, 5); err != nil {
+		panic(err)
+	}
+	if err := m.Bind(ctx, second, 5); !errors.Is(err, xregistry.ErrDuplicateLogin) {
+		t.Fatalf("reject err %v", err)
+	}
+	if m.Get(5) != first {
+		t.Fatalf("session replaced")
+	}
+}
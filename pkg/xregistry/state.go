@@ -3,8 +3,9 @@ package xregistry
 import (
 	"context"
 	"gotu/pkg/xlog"
-	"gotu/pkg/xmsg"
 	"gotu/pkg/xnet"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -13,19 +14,85 @@ import (
 type State struct {
 	Sock xnet.Socket
 
-	// UID
-	// TOKEN
+	registry *Registry // 所属注册器, nil时使用默认注册器
+
+	mu        sync.RWMutex
+	identity  *Identity   // 登录后用户身份
+	authTimer *time.Timer // 未登录超时断开
 }
 
-func (s *State) SendMsg(ctx context.Context, cmd int32, data interface{}) {
-	// 序列化数据 payload
-	payload, err := Marshal(data)
-	if err != nil {
-		xlog.Get(ctx).Warn("Marshal data failed.", zap.Any("err", err), zap.Int32("cmd", cmd), zap.Any("data", data))
-		return
+// 是否已登录
+func (s *State) Authed() bool {
+	return s.Identity() != nil
+}
+
+// 用户身份, 未登录返回nil
+func (s *State) Identity() *Identity {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.identity
+}
+
+// 用户ID, 未登录返回0
+func (s *State) UID() int64 {
+	if id := s.Identity(); id != nil {
+		return id.UID
 	}
-	// 打包数据包 header + payload
-	msg, err := xmsg.PackMsg(ctx, xmsg.PackMsgArgs{Cmd: cmd, Payload: payload})
+	return 0
+}
+
+func (s *State) login(id *Identity) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.identity = id
+	if s.authTimer != nil {
+		s.authTimer.Stop()
+	}
+}
+
+func (s *State) stopAuthTimer() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.authTimer != nil {
+		s.authTimer.Stop()
+	}
+}
+
+// xnet各socket实现的关闭方法(xnet.Socket未包含), 等待读写协程退出
+type sockCloser interface {
+	Close(ctx context.Context)
+}
+
+// 关闭连接, 不支持关闭的Sock返回false
+// Close等待读协程退出, 而读协程内执行该连接的回调, 故不可在该连接的回调内调用(死锁)
+func (s *State) closeSock(ctx context.Context) bool {
+	c, ok := s.Sock.(sockCloser)
+	if ok {
+		c.Close(ctx)
+	}
+	return ok
+}
+
+func (s *State) Registry() *Registry {
+	if s.registry == nil {
+		return defaultRegistry
+	}
+	return s.registry
+}
+
+// 发送消息, handler内向请求来源连接发送时回填请求Seq(作为回包), 其他连接Seq为0
+func (s *State) SendMsg(ctx context.Context, cmd int32, data interface{}) {
+	s.sendMsg(ctx, seqFor(ctx, s), cmd, data)
+}
+
+// 主动推送, Seq为0
+func (s *State) Push(ctx context.Context, cmd int32, data interface{}) {
+	s.sendMsg(ctx, 0, cmd, data)
+}
+
+func (s *State) sendMsg(ctx context.Context, seq int32, cmd int32, data interface{}) {
+	// 序列化并打包数据包 header + payload
+	msg, err := s.Registry().packMsg(ctx, seq, cmd, data)
 	if err != nil {
 		xlog.Get(ctx).Warn("Pack msg failed.", zap.Any("err", err), zap.Int32("cmd", cmd), zap.Any("data", data))
 		return
@@ -0,0 +1,226 @@
+package xregistry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gotu/pkg/xlog"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// 订阅结果
+const (
+	TopicOK     int32 = 0
+	TopicReject int32 = 1 // Authorize拒绝
+)
+
+var ErrTopicRejected = errors.New("topic rejected")
+
+type TopicArgs struct {
+	SubscribeCmd   int32                                                       // 订阅cmd, 请求payload为topic原始数据
+	UnsubscribeCmd int32                                                       // 取消订阅cmd, 请求payload为topic原始数据
+	LastValue      bool                                                        // 缓存最后一条消息(仅限已被订阅过的topic), 订阅后立即推送
+	Authorize      func(ctx context.Context, state *State, topic string) error // 订阅校验, nil: 全部允许
+}
+
+// 订阅/取消订阅响应, 固定binary序列化
+type TopicResp struct {
+	Code int32
+}
+
+// 主题订阅/推送(进程内)
+type TopicManager struct {
+	registry *Registry
+	arg      TopicArgs
+
+	mu     sync.RWMutex
+	topics map[string]*topicEntry
+	subs   map[*State]map[string]struct{} // State => 已订阅topic
+}
+
+type topicEntry struct {
+	subscribers map[*State]struct{}
+	last        []byte // 最后一条消息(已打包)
+}
+
+// 注册订阅/取消订阅cmd, 连接断开时自动取消订阅
+func NewTopicManager(r *Registry, arg TopicArgs) *TopicManager {
+	tm := &TopicManager{
+		registry: r,
+		arg:      arg,
+		topics:   make(map[string]*topicEntry),
+		subs:     make(map[*State]map[string]struct{}),
+	}
+	r.Register(arg.SubscribeCmd, tm.onSubscribe)
+	r.SetCmdSerializer(arg.SubscribeCmd, BinarySerializer)
+	r.Register(arg.UnsubscribeCmd, tm.onUnsubscribe)
+	r.SetCmdSerializer(arg.UnsubscribeCmd, BinarySerializer)
+	r.AddDisconnectHook(tm.UnsubscribeAll)
+	return tm
+}
+
+func (tm *TopicManager) onSubscribe(ctx context.Context, state *State, req []byte) error {
+	topic := string(req)
+	if tm.arg.Authorize != nil {
+		if err := tm.arg.Authorize(ctx, state, topic); err != nil {
+			xlog.Get(ctx).Info("Subscribe rejected.", zap.Any("err", err), zap.String("topic", topic), zap.Int64("uid", state.UID()))
+			state.SendMsg(ctx, tm.arg.SubscribeCmd, &TopicResp{Code: TopicReject})
+			return nil
+		}
+	}
+	last := tm.subscribe(state, topic)
+	state.SendMsg(ctx, tm.arg.SubscribeCmd, &TopicResp{Code: TopicOK})
+	tm.sendLast(ctx, state, last)
+	return nil
+}
+
+func (tm *TopicManager) onUnsubscribe(ctx context.Context, state *State, req []byte) error {
+	tm.Unsubscribe(state, string(req))
+	state.SendMsg(ctx, tm.arg.UnsubscribeCmd, &TopicResp{Code: TopicOK})
+	return nil
+}
+
+// 服务端订阅(不经Authorize), 存在缓存时立即推送
+func (tm *TopicManager) Subscribe(ctx context.Context, state *State, topic string) {
+	tm.sendLast(ctx, state, tm.subscribe(state, topic))
+}
+
+func (tm *TopicManager) subscribe(state *State, topic string) []byte {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	entry, ok := tm.topics[topic]
+	if !ok {
+		entry = &topicEntry{subscribers: make(map[*State]struct{})}
+		tm.topics[topic] = entry
+	}
+	entry.subscribers[state] = struct{}{}
+	if _, ok := tm.subs[state]; !ok {
+		tm.subs[state] = make(map[string]struct{})
+	}
+	tm.subs[state][topic] = struct{}{}
+	return entry.last
+}
+
+func (tm *TopicManager) sendLast(ctx context.Context, state *State, last []byte) {
+	if last == nil {
+		return
+	}
+	if err := state.Sock.SendMsg(ctx, last); err != nil {
+		xlog.Get(ctx).Debug("Send last value failed.", zap.Any("err", err))
+	}
+}
+
+// 取消订阅
+func (tm *TopicManager) Unsubscribe(state *State, topic string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.unsubscribeLocked(state, topic)
+}
+
+// 取消全部订阅(连接断开时自动调用)
+func (tm *TopicManager) UnsubscribeAll(ctx context.Context, state *State) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	for topic := range tm.subs[state] {
+		tm.unsubscribeLocked(state, topic)
+	}
+}
+
+func (tm *TopicManager) unsubscribeLocked(state *State, topic string) {
+	if topics, ok := tm.subs[state]; ok {
+		delete(topics, topic)
+		if len(topics) == 0 {
+			delete(tm.subs, state)
+		}
+	}
+	entry, ok := tm.topics[topic]
+	if !ok {
+		return
+	}
+	delete(entry.subscribers, state)
+	// 保留缓存供后续订阅
+	if len(entry.subscribers) == 0 && entry.last == nil {
+		delete(tm.topics, topic)
+	}
+}
+
+// 推送: 打包一次分发给所有订阅者, 返回发送成功数量
+func (tm *TopicManager) Publish(ctx context.Context, topic string, cmd int32, data interface{}) (int, error) {
+	msg, err := tm.registry.packMsg(ctx, 0, cmd, data)
+	if err != nil {
+		return 0, err
+	}
+
+	// 仅由订阅创建topic, 未被订阅过的topic不缓存
+	tm.mu.Lock()
+	var states []*State
+	if entry, ok := tm.topics[topic]; ok {
+		if tm.arg.LastValue {
+			entry.last = msg
+		}
+		states = make([]*State, 0, len(entry.subscribers))
+		for state := range entry.subscribers {
+			states = append(states, state)
+		}
+	}
+	tm.mu.Unlock()
+	return SendStates(ctx, states, msg), nil
+}
+
+// 清除topic缓存
+func (tm *TopicManager) ClearLast(topic string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if entry, ok := tm.topics[topic]; ok {
+		entry.last = nil
+		if len(entry.subscribers) == 0 {
+			delete(tm.topics, topic)
+		}
+	}
+}
+
+// 订阅者数量
+func (tm *TopicManager) Subscribers(topic string) int {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	if entry, ok := tm.topics[topic]; ok {
+		return len(entry.subscribers)
+	}
+	return 0
+}
+
+// 当前topic列表(升序)
+func (tm *TopicManager) Topics() []string {
+	tm.mu.RLock()
+	topics := make([]string, 0, len(tm.topics))
+	for topic := range tm.topics {
+		topics = append(topics, topic)
+	}
+	tm.mu.RUnlock()
+	sort.Strings(topics)
+	return topics
+}
+
+// 客户端订阅
+func (c *Client) Subscribe(ctx context.Context, subscribeCmd int32, topic string) error {
+	return c.topicCall(ctx, subscribeCmd, topic)
+}
+
+// 客户端取消订阅
+func (c *Client) Unsubscribe(ctx context.Context, unsubscribeCmd int32, topic string) error {
+	return c.topicCall(ctx, unsubscribeCmd, topic)
+}
+
+func (c *Client) topicCall(ctx context.Context, cmd int32, topic string) error {
+	resp := &TopicResp{}
+	if err := c.CallRaw(ctx, cmd, []byte(topic), resp); err != nil {
+		return err
+	}
+	if resp.Code != TopicOK {
+		return fmt.Errorf("%w: topic[%s] code[%d]", ErrTopicRejected, topic, resp.Code)
+	}
+	return nil
+}
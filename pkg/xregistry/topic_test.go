@@ -0,0 +1,121 @@
+package xregistry_test
+
+import (
+	"context"
+	"errors"
+	"gotu/pkg/xregistry"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	cmdSub   = int32(501)
+	cmdUnsub = int32(502)
+	cmdRoom  = int32(503) // 房间推送
+)
+
+type RoomMsg struct {
+	Num int32
+}
+
+// 推送记录到msgCh: name:num
+func newRoomRegistry(name string, msgCh chan string) *xregistry.Registry {
+	r := xregistry.NewRegistry(name)
+	r.Register(cmdRoom, xregistry.HandleWarp(func(ctx context.Context, state *xregistry.State, msg *RoomMsg) error {
+		msgCh <- name + ":" + string('0'+rune(msg.Num))
+		return nil
+	}))
+	return r
+}
+
+func TestTopic(t *testing.T) {
+	ctx := context.Background()
+	r := xregistry.NewRegistry("topic")
+	tm := xregistry.NewTopicManager(r, xregistry.TopicArgs{
+		SubscribeCmd:   cmdSub,
+		UnsubscribeCmd: cmdUnsub,
+		LastValue:      true,
+		Authorize: func(ctx context.Context, state *xregistry.State, topic string) error {
+			if strings.HasPrefix(topic, "admin") {
+				return errors.New("forbidden")
+			}
+			return nil
+		},
+	})
+	f := newFixture(t, fixtureArgs{Registry: r, TCP: "127.0.0.1:9925"})
+
+	msgCh := make(chan string, 16)
+	newCli := func(name string, topics ...string) *xregistry.Client {
+		cli := f.dial("tcp", xregistry.ClientArgs{Registry: newRoomRegistry(name, msgCh)})
+		for _, topic := range topics {
+			if err := cli.Subscribe(ctx, cmdSub, topic); err != nil {
+				panic(err)
+			}
+		}
+		return cli
+	}
+	a := newCli("a", "room-1")
+	b := newCli("b", "room-1", "room-2")
+
+	if err := a.Subscribe(ctx, cmdSub, "admin-1"); !errors.Is(err, xregistry.ErrTopicRejected) {
+		t.Fatalf("authorize err %v", err)
+	}
+	// 无订阅者的topic不缓存
+	if n, err := tm.Publish(ctx, "room-9", cmdRoom, &RoomMsg{Num: 9}); err != nil || n != 0 {
+		t.Fatalf("publish %v %v", n, err)
+	}
+	if topics := tm.Topics(); !reflect.DeepEqual(topics, []string{"room-1", "room-2"}) {
+		t.Fatalf("topics %v", topics)
+	}
+
+	// 按topic分发
+	if n, err := tm.Publish(ctx, "room-1", cmdRoom, &RoomMsg{Num: 1}); err != nil || n != 2 {
+		t.Fatalf("publish %v %v", n, err)
+	}
+	if n, err := tm.Publish(ctx, "room-2", cmdRoom, &RoomMsg{Num: 2}); err != nil || n != 1 {
+		t.Fatalf("publish %v %v", n, err)
+	}
+	if msgs := recvNotices(t, msgCh, 3); !reflect.DeepEqual(msgs, []string{"a:1", "b:1", "b:2"}) {
+		t.Fatalf("msgs %v", msgs)
+	}
+
+	// 后加入者收到缓存
+	c := newCli("c", "room-1")
+	if msgs := recvNotices(t, msgCh, 1); !reflect.DeepEqual(msgs, []string{"c:1"}) {
+		t.Fatalf("last value %v", msgs)
+	}
+
+	// 取消订阅
+	if err := a.Unsubscribe(ctx, cmdUnsub, "room-1"); err != nil {
+		panic(err)
+	}
+	if n, err := tm.Publish(ctx, "room-1", cmdRoom, &RoomMsg{Num: 3}); err != nil || n != 2 {
+		t.Fatalf("publish %v %v", n, err)
+	}
+	if msgs := recvNotices(t, msgCh, 2); !reflect.DeepEqual(msgs, []string{"b:3", "c:3"}) {
+		t.Fatalf("msgs %v", msgs)
+	}
+
+	// 断开连接自动取消订阅
+	c.Close(ctx)
+	for i := 0; i < 100 && tm.Subscribers("room-1") != 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := tm.Subscribers("room-1"); n != 1 {
+		t.Fatalf("subscribers %v", n)
+	}
+	b.Close(ctx)
+	for i := 0; i < 100 && tm.Subscribers("room-2") != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// 无订阅者仍保留缓存
+	if topics := tm.Topics(); !reflect.DeepEqual(topics, []string{"room-1", "room-2"}) || tm.Subscribers("room-2") != 0 {
+		t.Fatalf("topics %v", topics)
+	}
+	tm.ClearLast("room-2")
+	if topics := tm.Topics(); !reflect.DeepEqual(topics, []string{"room-1"}) {
+		t.Fatalf("topics %v", topics)
+	}
+}